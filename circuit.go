@@ -0,0 +1,234 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dberr"
+	"gorm.io/gorm"
+)
+
+// ErrCircuitOpen is returned instead of running a statement while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// CircuitBreakerConfig configures the circuit breaker enabled by
+// Config.CircuitBreaker
+type CircuitBreakerConfig struct {
+	// Threshold is the number of consecutive connection failures that opens
+	// the circuit. It must be positive.
+	Threshold int
+	// Window bounds how long the run of failures may take: a failure more
+	// than Window after the first one of the run starts a new run. Zero
+	// counts failures however far apart they are.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before a single probe
+	// statement is let through, and how long that probe may take before the
+	// next statement probes in its place. It must be positive.
+	Cooldown time.Duration
+}
+
+// CircuitState is the state of the circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every statement through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every statement with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets one probe statement through and rejects the rest
+	// until the probe has decided whether to close or reopen the circuit
+	CircuitHalfOpen
+)
+
+// String implements fmt.Stringer
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitState returns the state of the circuit breaker, CircuitClosed when
+// none is configured
+func (db *DB) CircuitState() CircuitState {
+	if db.breaker == nil {
+		return CircuitClosed
+	}
+	return db.breaker.currentState()
+}
+
+// circuitProbeKey marks the statement that probes a half-open circuit
+const circuitProbeKey = "db:circuit_probe"
+
+// circuitBreaker counts connection failures of the statements run through
+// the DB. Only failures to reach the database count; query errors such as
+// constraint violations show the database is up and reset the count.
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	first    time.Time
+	openedAt time.Time
+	probing  bool
+	probedAt time.Time
+}
+
+// newCircuitBreaker validates config and returns a closed breaker
+func newCircuitBreaker(config CircuitBreakerConfig) (*circuitBreaker, error) {
+	if config.Threshold <= 0 {
+		return nil, fmt.Errorf("circuit breaker threshold must be positive, got %d", config.Threshold)
+	}
+	if config.Cooldown <= 0 {
+		return nil, fmt.Errorf("circuit breaker cooldown must be positive, got %s", config.Cooldown)
+	}
+	if config.Window < 0 {
+		return nil, fmt.Errorf("circuit breaker window cannot be negative, got %s", config.Window)
+	}
+	return &circuitBreaker{config: config, now: time.Now}, nil
+}
+
+// register adds the callbacks that guard and observe every statement. Writes
+// are checked before GORM begins their default transaction, so a rejected
+// write neither takes a connection nor runs the model's Before hooks.
+func (b *circuitBreaker) register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:begin_transaction").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("db:circuit_record", b.record); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("db:circuit_record", b.record); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:begin_transaction").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("db:circuit_record", b.record); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:begin_transaction").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("db:circuit_record", b.record); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("db:circuit_record", b.record); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("db:circuit_check", b.check); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("db:circuit_record", b.record)
+}
+
+// currentState returns the state, moving an open circuit whose cooldown has
+// passed to half-open
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// advance moves an open circuit to half-open once the cooldown has passed,
+// and gives up on a probe that has not reported back within another cooldown,
+// as when its statement panicked, so that the next statement probes instead.
+// The caller holds mu.
+func (b *circuitBreaker) advance() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.Cooldown {
+		b.state = CircuitHalfOpen
+		b.probing = false
+	}
+	if b.probing && b.now().Sub(b.probedAt) >= b.config.Cooldown {
+		b.probing = false
+	}
+}
+
+// check fails the statement with ErrCircuitOpen unless the circuit lets it through
+func (b *circuitBreaker) check(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case CircuitOpen:
+		db.AddError(ErrCircuitOpen)
+	case CircuitHalfOpen:
+		if b.probing {
+			db.AddError(ErrCircuitOpen)
+			return
+		}
+		b.probing = true
+		b.probedAt = b.now()
+		db.InstanceSet(circuitProbeKey, true)
+	}
+}
+
+// record updates the breaker with the outcome of the statement
+func (b *circuitBreaker) record(db *gorm.DB) {
+	if errors.Is(db.Error, ErrCircuitOpen) {
+		return
+	}
+	_, probe := db.InstanceGet(circuitProbeKey)
+	failed := dberr.IsConnection(db.Error)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.state = CircuitClosed
+			b.failures = 0
+		}
+		return
+	}
+	if b.state != CircuitClosed {
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	now := b.now()
+	if b.failures == 0 || (b.config.Window > 0 && now.Sub(b.first) > b.config.Window) {
+		b.failures = 0
+		b.first = now
+	}
+	b.failures++
+	if b.failures >= b.config.Threshold {
+		b.open()
+	}
+}
+
+// open opens the circuit for a cooldown period. The caller holds mu.
+func (b *circuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+	b.failures = 0
+}
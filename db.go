@@ -2,10 +2,15 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/modsynth/db-module/internal/dialect"
+	"github.com/modsynth/db-module/repository"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -28,12 +33,82 @@ type Config struct {
 	ConnMaxLifetime time.Duration // Maximum lifetime of a connection
 	ConnMaxIdleTime time.Duration // Maximum idle time of a connection
 	LogLevel        logger.LogLevel
+
+	// TimeZone is the location GORM timestamps such as CreatedAt are set in,
+	// UTC when nil. ResolvedDSN passes it to the driver too, as loc on mysql,
+	// TimeZone on postgres and _loc on sqlite, so that zone-less values read
+	// back are interpreted in the same location. See the README for how each
+	// driver treats retrieved times.
+	TimeZone *time.Location
+
+	// Logger replaces GORM's default logger. LogLevel is not applied to a
+	// custom logger; configure its level directly.
+	Logger logger.Interface
+
+	// ApplicationName labels the connections of this service for DBAs. It is
+	// passed to the driver through ResolvedDSN: postgres reports it as
+	// application_name in pg_stat_activity and mysql as the program_name
+	// connection attribute in performance_schema.session_connect_attrs, so it
+	// must not contain commas or colons there. SQLite ignores it.
+	ApplicationName string
+
+	// SessionSettings are applied to every connection as it is opened, e.g.
+	// statement_timeout or application_name. They are passed to the driver
+	// through ResolvedDSN: postgres sends them as startup parameters and
+	// mysql issues SET name=value on connect, with the value inserted as is,
+	// so quote string values yourself ("'TRADITIONAL'"). New fails when the
+	// dialector's DSN lacks any of them, as with a dialector opened with the
+	// bare DSN or an existing connection. SQLite has no such hook and New
+	// rejects session settings there.
+	SessionSettings map[string]string
+
+	// MaskedColumns are redacted as *** from the parameters of logged SQL,
+	// in addition to fields tagged gorm:"mask". See masker for what can and
+	// cannot be attributed to a column.
+	MaskedColumns []string
+
+	// TracerProvider enables an OpenTelemetry client span per statement,
+	// named after the operation and table, e.g. "INSERT users"
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider enables OpenTelemetry metrics: a histogram of statement
+	// durations, a counter of failed statements and a gauge of the pool's
+	// used and idle connections, read from sql.DB.Stats when collected
+	MeterProvider metric.MeterProvider
+
+	// SpanNameFromCaller prefixes span names with the function that issued
+	// the statement, e.g. "CreateOrder -> INSERT users". It walks the call
+	// stack on every statement, costing a few microseconds per query.
+	SpanNameFromCaller bool
+
+	// CircuitBreaker, when set, fails statements fast with ErrCircuitOpen
+	// once the database has been unreachable for Threshold statements in a
+	// row, instead of letting every caller wait for its own connection
+	// failure. After Cooldown one probe statement is let through; its success
+	// closes the circuit and its failure reopens it. Statement errors from a
+	// reachable database, such as constraint violations, do not count.
+	CircuitBreaker *CircuitBreakerConfig
+
+	// PrepareStmt caches a prepared statement per distinct SQL and reuses it
+	// on later executions. Schema changes invalidate the cached statements;
+	// see ResetStatementCache.
+	PrepareStmt bool
+
+	// AllowDestructive lets SafeMigrate apply changes that narrow a column
+	// type, which it otherwise refuses with ErrDestructiveMigration
+	AllowDestructive bool
+
+	// Plugins are registered with gorm.DB.Use in slice order once the
+	// connection is open, so a plugin may rely on callbacks registered by
+	// the plugins before it.
+	Plugins []gorm.Plugin
 }
 
 // DB wraps gorm.DB with additional functionality
 type DB struct {
 	*gorm.DB
-	config *Config
+	config  *Config
+	breaker *circuitBreaker
 }
 
 // New creates a new database connection
@@ -56,14 +131,29 @@ func New(config *Config, dialector gorm.Dialector) (*DB, error) {
 		config.ConnMaxIdleTime = 10 * time.Minute
 	}
 
+	location := time.UTC
+	if config.TimeZone != nil {
+		location = config.TimeZone
+	}
+
+	gormLogger := config.Logger
+	if gormLogger == nil {
+		gormLogger = logger.Default.LogMode(config.LogLevel)
+	}
+
 	// GORM config
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(config.LogLevel),
+		Logger:      gormLogger,
+		PrepareStmt: config.PrepareStmt,
 		NowFunc: func() time.Time {
-			return time.Now().UTC()
+			return time.Now().In(location)
 		},
 	}
 
+	if err := checkSessionSettings(config, dialector); err != nil {
+		return nil, err
+	}
+
 	// Open database connection
 	gormDB, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
@@ -76,6 +166,48 @@ func New(config *Config, dialector gorm.Dialector) (*DB, error) {
 		return nil, fmt.Errorf("failed to get database instance: %w", err)
 	}
 
+	// Redact masked columns from SQL logs
+	if err := registerMasking(gormDB, config.MaskedColumns); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to register log masking: %w", err)
+	}
+
+	// Trace statements
+	if config.TracerProvider != nil {
+		if err := registerTracing(gormDB, sqlDB, config.TracerProvider, config.SpanNameFromCaller); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to register tracing: %w", err)
+		}
+	}
+
+	// Record statement and pool metrics
+	if config.MeterProvider != nil {
+		if err := registerOTelMetrics(gormDB, sqlDB, config.MeterProvider); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+
+	// Guard statements with the circuit breaker
+	var breaker *circuitBreaker
+	if config.CircuitBreaker != nil {
+		if breaker, err = newCircuitBreaker(*config.CircuitBreaker); err == nil {
+			err = breaker.register(gormDB)
+		}
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to register circuit breaker: %w", err)
+		}
+	}
+
+	// Register plugins
+	for _, plugin := range config.Plugins {
+		if err := gormDB.Use(plugin); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("failed to register plugin %s: %w", plugin.Name(), err)
+		}
+	}
+
 	// Set connection pool settings
 	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
@@ -83,18 +215,32 @@ func New(config *Config, dialector gorm.Dialector) (*DB, error) {
 	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
 	return &DB{
-		DB:     gormDB,
-		config: config,
+		DB:      gormDB,
+		config:  config,
+		breaker: breaker,
 	}, nil
 }
 
-// Close closes the database connection
-func (db *DB) Close() error {
+// Dialect returns the normalized name of the database dialect: "postgres",
+// "mysql" or "sqlite", or the dialector's own name for other drivers. It is
+// derived from the dialector, so it does not depend on Config.Driver.
+func (db *DB) Dialect() string {
+	return dialect.Of(db.DB)
+}
+
+// SQLDB returns the underlying connection pool, for libraries that take a
+// *sql.DB such as migration tools or sqlx. The pool is shared with the DB:
+// callers must not close it themselves, and should use DB.Close instead.
+func (db *DB) SQLDB() (*sql.DB, error) {
 	if db.DB == nil {
-		return ErrNotConnected
+		return nil, ErrNotConnected
 	}
+	return db.DB.DB()
+}
 
-	sqlDB, err := db.DB.DB()
+// Close closes the database connection
+func (db *DB) Close() error {
+	sqlDB, err := db.SQLDB()
 	if err != nil {
 		return err
 	}
@@ -104,11 +250,7 @@ func (db *DB) Close() error {
 
 // Ping checks the database connection
 func (db *DB) Ping(ctx context.Context) error {
-	if db.DB == nil {
-		return ErrNotConnected
-	}
-
-	sqlDB, err := db.DB.DB()
+	sqlDB, err := db.SQLDB()
 	if err != nil {
 		return err
 	}
@@ -124,14 +266,27 @@ func (db *DB) Transaction(fn func(*gorm.DB) error) error {
 // WithContext returns a new DB instance with the given context
 func (db *DB) WithContext(ctx context.Context) *DB {
 	return &DB{
-		DB:     db.DB.WithContext(ctx),
-		config: db.config,
+		DB:      db.DB.WithContext(ctx),
+		config:  db.config,
+		breaker: db.breaker,
 	}
 }
 
-// AutoMigrate runs auto migration for the given models
+// NewRepository creates a repository for T backed by the database, with the
+// given options. It is shorthand for repository.New[T](d.DB, opts...): the
+// repository runs its statements through the DB's plugins and callbacks, such
+// as tracing and the circuit breaker, but no Config field sets an option.
+func NewRepository[T any](d *DB, opts ...repository.Option) *repository.Repository[T] {
+	return repository.New[T](d.DB, opts...)
+}
+
+// AutoMigrate runs auto migration for the given models and then resets the
+// prepared statement cache
 func (db *DB) AutoMigrate(models ...interface{}) error {
-	return db.DB.AutoMigrate(models...)
+	if err := db.DB.AutoMigrate(models...); err != nil {
+		return err
+	}
+	return db.ResetStatementCache()
 }
 
 // HealthCheck returns the database health status
@@ -161,11 +316,7 @@ func (db *DB) HealthCheck(ctx context.Context) error {
 
 // Stats returns database connection pool statistics
 func (db *DB) Stats() (map[string]interface{}, error) {
-	if db.DB == nil {
-		return nil, ErrNotConnected
-	}
-
-	sqlDB, err := db.DB.DB()
+	sqlDB, err := db.SQLDB()
 	if err != nil {
 		return nil, err
 	}
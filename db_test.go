@@ -0,0 +1,1527 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/modsynth/db-module/repository"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/plugin/dbresolver"
+)
+
+func setupTestDB(t *testing.T, configure ...func(*Config)) *DB {
+	t.Helper()
+
+	// Every connection to :memory: opens its own database, so keep one
+	config := &Config{Driver: "sqlite", DSN: ":memory:", MaxOpenConns: 1, LogLevel: logger.Silent}
+	for _, fn := range configure {
+		fn(config)
+	}
+
+	database, err := New(config, sqlite.Open(config.DSN))
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	return database
+}
+
+type countingCollector struct {
+	observed atomic.Int64
+}
+
+func (c *countingCollector) ObservePoolStats(PoolStats) {
+	c.observed.Add(1)
+}
+
+func TestStartMetricsExport(t *testing.T) {
+	database := setupTestDB(t)
+
+	t.Run("exports until the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		collector := &countingCollector{}
+
+		if err := database.StartMetricsExport(ctx, 5*time.Millisecond, collector); err != nil {
+			t.Fatalf("StartMetricsExport failed: %v", err)
+		}
+
+		waitUntil(t, "several exports", func() bool {
+			return collector.observed.Load() >= 2
+		})
+		cancel()
+		stopped := collector.observed.Load()
+
+		// An export under way when cancel returns may still finish
+		time.Sleep(20 * time.Millisecond)
+		if after := collector.observed.Load(); after > stopped+1 {
+			t.Errorf("Expected exports to stop after cancel, got %d more", after-stopped)
+		}
+	})
+
+	t.Run("rejects invalid interval", func(t *testing.T) {
+		err := database.StartMetricsExport(context.Background(), 0, &countingCollector{})
+		if err == nil {
+			t.Error("Expected error for zero interval")
+		}
+	})
+}
+
+type rowsCollector struct {
+	countingCollector
+	mu   sync.Mutex
+	rows []string
+}
+
+func (c *rowsCollector) ObserveRows(operation, table string, rows int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rows = append(c.rows, fmt.Sprintf("%s %s %d", operation, table, rows))
+}
+
+func (c *rowsCollector) snapshot() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.rows...)
+}
+
+func TestStartMetricsExportObservesRows(t *testing.T) {
+	database := setupTestDB(t)
+
+	type Note struct {
+		ID   uint `gorm:"primarykey"`
+		Body string
+	}
+	if err := database.AutoMigrate(&Note{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	collector := &rowsCollector{}
+	if err := database.StartMetricsExport(ctx, time.Hour, collector); err != nil {
+		t.Fatalf("StartMetricsExport failed: %v", err)
+	}
+
+	database.Create(&[]Note{{Body: "a"}, {Body: "b"}, {Body: "c"}})
+	database.Model(&Note{}).Where("body <> ?", "a").Update("body", "z")
+	database.Where("body = ?", "z").Delete(&Note{})
+	database.Find(&[]Note{})
+
+	want := []string{"create notes 3", "update notes 2", "delete notes 2"}
+	if got := collector.snapshot(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		cancel()
+		database.Create(&Note{Body: "d"})
+		if got := collector.snapshot(); len(got) != len(want) {
+			t.Errorf("Expected no observations after cancel, got %v", got[len(want):])
+		}
+	})
+
+	t.Run("replaces the observer on a later export", func(t *testing.T) {
+		next := &rowsCollector{}
+		if err := database.StartMetricsExport(context.Background(), time.Hour, next); err != nil {
+			t.Fatalf("StartMetricsExport failed: %v", err)
+		}
+		database.Create(&Note{Body: "e"})
+		if got := next.snapshot(); fmt.Sprint(got) != "[create notes 1]" {
+			t.Errorf("Expected [create notes 1], got %v", got)
+		}
+	})
+}
+
+func TestNewRepository(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	type Note struct {
+		ID   uint `gorm:"primarykey"`
+		Body string
+	}
+	if err := database.AutoMigrate(&Note{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	repo := NewRepository[Note](database)
+	if err := repo.Create(ctx, &Note{Body: "hello"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err := repo.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count notes: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 note, got %d", count)
+	}
+}
+
+func TestMaskedColumns(t *testing.T) {
+	var buf bytes.Buffer
+	database := setupTestDB(t, func(c *Config) {
+		c.Logger = logger.New(log.New(&buf, "", 0), logger.Config{LogLevel: logger.Info})
+		c.MaskedColumns = []string{"email"}
+	})
+	ctx := context.Background()
+
+	type Account struct {
+		ID    uint `gorm:"primarykey"`
+		Name  string
+		Email string
+		Token string `gorm:"mask"`
+	}
+	if err := database.AutoMigrate(&Account{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	repo := NewRepository[Account](database)
+	account := &Account{Name: "Alice", Email: "alice@secret.example", Token: "tok-s3cr3t"}
+	if err := repo.Create(ctx, account); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	var found Account
+	if err := repo.FirstWhere(ctx, &found, "email = ?", "alice@secret.example"); err != nil {
+		t.Fatalf("Failed to find account: %v", err)
+	}
+	database.WithContext(ctx).Model(&found).Update("token", "tok-rotated")
+	database.WithContext(ctx).Where(&Account{Token: "tok-rotated"}).First(&found)
+
+	// A struct of another type than the model, as for a partial update
+	type AccountPatch struct {
+		Email string
+		Token string
+	}
+	database.WithContext(ctx).Model(&found).Updates(AccountPatch{Email: "alice@patched.example", Token: "tok-patched"})
+
+	out := buf.String()
+	for _, secret := range []string{"alice@secret.example", "tok-s3cr3t", "tok-rotated", "alice@patched.example", "tok-patched"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be masked in logs:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "***") {
+		t.Errorf("Expected masked placeholder in logs:\n%s", out)
+	}
+	if !strings.Contains(out, "Alice") {
+		t.Errorf("Expected unmasked values to be logged:\n%s", out)
+	}
+}
+
+func TestResolvedDSN(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"mysql without params", Config{Driver: "mysql", DSN: "user:pass@tcp(localhost:3306)/app", TimeZone: tokyo},
+			"user:pass@tcp(localhost:3306)/app?loc=Asia%2FTokyo"},
+		{"mysql with params", Config{Driver: "mysql", DSN: "user:pass@tcp(localhost:3306)/app?parseTime=true", TimeZone: tokyo},
+			"user:pass@tcp(localhost:3306)/app?parseTime=true&loc=Asia%2FTokyo"},
+		{"mysql keeps explicit loc", Config{Driver: "mysql", DSN: "user:pass@tcp(localhost:3306)/app?loc=UTC", TimeZone: tokyo},
+			"user:pass@tcp(localhost:3306)/app?loc=UTC"},
+		{"postgres keyword", Config{Driver: "postgres", DSN: "host=localhost dbname=app", TimeZone: tokyo},
+			"host=localhost dbname=app TimeZone=Asia/Tokyo"},
+		{"postgres keeps explicit timezone", Config{Driver: "postgres", DSN: "host=localhost timezone=UTC", TimeZone: tokyo},
+			"host=localhost timezone=UTC"},
+		{"postgres url", Config{Driver: "postgres", DSN: "postgres://localhost/app", TimeZone: tokyo},
+			"postgres://localhost/app?TimeZone=Asia%2FTokyo"},
+		{"sqlite", Config{Driver: "sqlite", DSN: "file:app.db", TimeZone: tokyo},
+			"file:app.db?_loc=Asia%2FTokyo"},
+		{"no time zone", Config{Driver: "mysql", DSN: "user:pass@tcp(localhost:3306)/app"},
+			"user:pass@tcp(localhost:3306)/app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.ResolvedDSN(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	t.Run("defaults to UTC", func(t *testing.T) {
+		database := setupTestDB(t)
+		if loc := database.NowFunc().Location(); loc != time.UTC {
+			t.Errorf("Expected UTC, got %v", loc)
+		}
+	})
+
+	t.Run("uses the configured location", func(t *testing.T) {
+		database := setupTestDB(t, func(c *Config) { c.TimeZone = tokyo })
+		if loc := database.NowFunc().Location(); loc != tokyo {
+			t.Errorf("Expected Asia/Tokyo, got %v", loc)
+		}
+	})
+}
+
+func TestDialect(t *testing.T) {
+	database := setupTestDB(t, func(c *Config) { c.Driver = "" })
+
+	if got := database.Dialect(); got != "sqlite" {
+		t.Errorf("Expected sqlite, got %q", got)
+	}
+	if got := NewRepository[struct{ ID uint }](database).Dialect(); got != "sqlite" {
+		t.Errorf("Expected repository dialect sqlite, got %q", got)
+	}
+}
+
+type tracedNote struct {
+	ID   uint `gorm:"primarykey"`
+	Body string
+}
+
+// createTracedNote issues a statement from a named function for span naming
+func createTracedNote(ctx context.Context, database *DB) error {
+	return database.WithContext(ctx).Create(&tracedNote{Body: "hello"}).Error
+}
+
+// holdConnection keeps the only pool connection of database busy until a
+// concurrent statement in run waits for it, and returns once run has
+// completed
+func holdConnection(t *testing.T, database *DB, run func()) {
+	t.Helper()
+	sqlDB, err := database.SQLDB()
+	if err != nil {
+		t.Fatalf("Failed to get sql.DB: %v", err)
+	}
+	waits := sqlDB.Stats().WaitCount
+	tx := database.Begin()
+	if tx.Error != nil {
+		t.Fatalf("Failed to begin: %v", tx.Error)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run()
+	}()
+	waitUntil(t, "a statement waiting for the connection", func() bool {
+		return sqlDB.Stats().WaitCount > waits
+	})
+	tx.Commit()
+	<-done
+}
+
+// waitUntil polls cond until it holds and fails the test when it still does
+// not after a few seconds
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("names spans after operation and table", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		database := setupTestDB(t, func(c *Config) {
+			c.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		})
+		database.AutoMigrate(&tracedNote{})
+
+		if err := createTracedNote(ctx, database); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		database.WithContext(ctx).Exec("DELETE FROM traced_notes WHERE id = ?", 99)
+		database.WithContext(ctx).Table("missing_table").Find(&[]tracedNote{})
+
+		names := map[string]bool{}
+		for _, span := range recorder.Ended() {
+			names[span.Name()] = true
+			if span.Name() == "SELECT missing_table" && span.Status().Code != codes.Error {
+				t.Error("Expected failed query span to have error status")
+			}
+		}
+		for _, want := range []string{"INSERT traced_notes", "DELETE", "SELECT missing_table"} {
+			if !names[want] {
+				t.Errorf("Expected span %q, got %v", want, names)
+			}
+		}
+	})
+
+	t.Run("prefixes span names with the caller", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		database := setupTestDB(t, func(c *Config) {
+			c.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			c.SpanNameFromCaller = true
+		})
+		database.AutoMigrate(&tracedNote{})
+
+		if err := createTracedNote(ctx, database); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+
+		found := false
+		for _, span := range recorder.Ended() {
+			if span.Name() == "createTracedNote -> INSERT traced_notes" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected span named after createTracedNote")
+		}
+	})
+
+	t.Run("records the connection wait", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		database := setupTestDB(t, func(c *Config) {
+			c.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		})
+		database.AutoMigrate(&tracedNote{})
+
+		holdConnection(t, database, func() {
+			database.WithContext(ctx).Find(&[]tracedNote{})
+		})
+
+		var wait float64
+		for _, span := range recorder.Ended() {
+			if span.Name() != "SELECT traced_notes" {
+				continue
+			}
+			for _, attr := range span.Attributes() {
+				if attr.Key == "db.client.connection.wait_time" {
+					wait = attr.Value.AsFloat64()
+				}
+			}
+		}
+		if wait <= 0 {
+			t.Errorf("Expected a positive connection wait, got %v", wait)
+		}
+	})
+}
+
+// meterProvider hands out its meter for every instrumentation name
+type meterProvider struct {
+	noop.MeterProvider
+	meter *meterRecorder
+}
+
+func (p meterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+// meterRecorder is a meter whose instruments record what they get
+type meterRecorder struct {
+	noop.Meter
+
+	mu        sync.Mutex
+	durations []attribute.Set
+	errors    []attribute.Set
+	callback  metric.Callback
+}
+
+func (m *meterRecorder) Float64Histogram(string, ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return recordingHistogram{m: m}, nil
+}
+
+func (m *meterRecorder) Int64Counter(string, ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return recordingCounter{m: m}, nil
+}
+
+func (m *meterRecorder) RegisterCallback(f metric.Callback, _ ...metric.Observable) (metric.Registration, error) {
+	m.callback = f
+	return noop.Registration{}, nil
+}
+
+type recordingHistogram struct {
+	noop.Float64Histogram
+	m *meterRecorder
+}
+
+func (h recordingHistogram) Record(_ context.Context, _ float64, opts ...metric.RecordOption) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.durations = append(h.m.durations, metric.NewRecordConfig(opts).Attributes())
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	m *meterRecorder
+}
+
+func (c recordingCounter) Add(_ context.Context, _ int64, opts ...metric.AddOption) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.errors = append(c.m.errors, metric.NewAddConfig(opts).Attributes())
+}
+
+// gaugeObserver collects the values observed by a metric callback by their
+// connection state. The wait count has no state and is kept under "", and
+// the wait time is the only float value.
+type gaugeObserver struct {
+	embedded.Observer
+	values   map[string]int64
+	waitTime float64
+}
+
+func (o *gaugeObserver) ObserveFloat64(_ metric.Float64Observable, value float64, _ ...metric.ObserveOption) {
+	o.waitTime = value
+}
+
+func (o *gaugeObserver) ObserveInt64(_ metric.Int64Observable, value int64, opts ...metric.ObserveOption) {
+	attrs := metric.NewObserveConfig(opts).Attributes()
+	state, _ := attrs.Value("db.client.connection.state")
+	o.values[state.AsString()] = value
+}
+
+func TestOTelMetrics(t *testing.T) {
+	ctx := context.Background()
+	meter := &meterRecorder{}
+	database := setupTestDB(t, func(c *Config) { c.MeterProvider = meterProvider{meter: meter} })
+	database.AutoMigrate(&tracedNote{})
+
+	t.Run("records statement durations", func(t *testing.T) {
+		if err := database.WithContext(ctx).Create(&tracedNote{Body: "metered"}).Error; err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		database.WithContext(ctx).Exec("DELETE FROM traced_notes WHERE id = ?", 99)
+
+		meter.mu.Lock()
+		defer meter.mu.Unlock()
+		operations := map[string]bool{}
+		for _, attrs := range meter.durations {
+			op, _ := attrs.Value("db.operation")
+			table, _ := attrs.Value("db.sql.table")
+			operations[op.AsString()+" "+table.AsString()] = true
+		}
+		for _, want := range []string{"INSERT traced_notes", "DELETE "} {
+			if !operations[want] {
+				t.Errorf("Expected a duration for %q, got %v", want, operations)
+			}
+		}
+	})
+
+	t.Run("counts errors but not missing records", func(t *testing.T) {
+		meter.mu.Lock()
+		meter.errors = nil
+		meter.mu.Unlock()
+
+		database.WithContext(ctx).Table("missing_table").Find(&[]tracedNote{})
+		database.WithContext(ctx).First(&tracedNote{}, 9999)
+
+		meter.mu.Lock()
+		defer meter.mu.Unlock()
+		if len(meter.errors) != 1 {
+			t.Fatalf("Expected 1 error, got %d", len(meter.errors))
+		}
+		if table, _ := meter.errors[0].Value("db.sql.table"); table.AsString() != "missing_table" {
+			t.Errorf("Expected the error to be attributed to missing_table, got %q", table.AsString())
+		}
+	})
+
+	t.Run("observes the pool", func(t *testing.T) {
+		if meter.callback == nil {
+			t.Fatal("Expected a gauge callback")
+		}
+		observer := &gaugeObserver{values: map[string]int64{}}
+		if err := meter.callback(ctx, observer); err != nil {
+			t.Fatalf("Callback failed: %v", err)
+		}
+		if observer.values["used"] != 0 || observer.values["idle"] != 1 {
+			t.Errorf("Expected 0 used and 1 idle connection, got %v", observer.values)
+		}
+	})
+
+	t.Run("observes connection waits", func(t *testing.T) {
+		holdConnection(t, database, func() {
+			database.WithContext(ctx).Find(&[]tracedNote{})
+		})
+
+		observer := &gaugeObserver{values: map[string]int64{}}
+		if err := meter.callback(ctx, observer); err != nil {
+			t.Fatalf("Callback failed: %v", err)
+		}
+		if observer.values[""] < 1 || observer.waitTime <= 0 {
+			t.Errorf("Expected a recorded wait, got %d waits and %vs", observer.values[""], observer.waitTime)
+		}
+	})
+}
+
+func TestShortFuncName(t *testing.T) {
+	tests := map[string]string{
+		"main.CreateOrder":                         "CreateOrder",
+		"example.com/app/orders.(*Service).Create": "Service.Create",
+		"example.com/app.(*Store[...]).Save":       "Store.Save",
+		"example.com/app.handler.func1":            "handler.func1",
+	}
+	for fn, want := range tests {
+		if got := shortFuncName(fn); got != want {
+			t.Errorf("shortFuncName(%q): expected %q, got %q", fn, want, got)
+		}
+	}
+}
+
+func TestNextSequence(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "sequence.db") + "?_busy_timeout=5000&_txlock=immediate"
+	database := setupTestDB(t, func(c *Config) {
+		c.DSN = dsn
+		c.MaxOpenConns = 8
+	})
+	if err := database.AutoMigrate(&Sequence{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	t.Run("starts at one per name", func(t *testing.T) {
+		for _, name := range []string{"invoice:a", "invoice:b"} {
+			value, err := database.NextSequence(ctx, name)
+			if err != nil {
+				t.Fatalf("NextSequence failed: %v", err)
+			}
+			if value != 1 {
+				t.Errorf("Expected 1 for %s, got %d", name, value)
+			}
+		}
+	})
+
+	t.Run("allocates unique values concurrently", func(t *testing.T) {
+		const workers, perWorker = 8, 10
+
+		var mu sync.Mutex
+		seen := map[int64]bool{}
+		var wg sync.WaitGroup
+		errs := make(chan error, workers*perWorker)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					value, err := database.NextSequence(ctx, "orders")
+					if err != nil {
+						errs <- err
+						return
+					}
+					mu.Lock()
+					if seen[value] {
+						errs <- fmt.Errorf("duplicate value %d", value)
+					}
+					seen[value] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Errorf("Allocation failed: %v", err)
+		}
+		for v := int64(1); v <= workers*perWorker; v++ {
+			if !seen[v] {
+				t.Errorf("Expected value %d to be allocated", v)
+			}
+		}
+	})
+
+	t.Run("rolled back values are reused", func(t *testing.T) {
+		rollback := errors.New("rollback")
+		err := database.Transaction(func(tx *gorm.DB) error {
+			if _, err := NextSequenceTx(tx, "gapless"); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatalf("Expected rollback error, got %v", err)
+		}
+
+		value, err := database.NextSequence(ctx, "gapless")
+		if err != nil {
+			t.Fatalf("NextSequence failed: %v", err)
+		}
+		if value != 1 {
+			t.Errorf("Expected 1 after rollback, got %d", value)
+		}
+	})
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("reads settings", func(t *testing.T) {
+		t.Setenv("APP_DB_DRIVER", "postgres")
+		t.Setenv("APP_DB_DSN", "host=localhost dbname=app")
+		t.Setenv("APP_DB_MAX_OPEN_CONNS", "25")
+		t.Setenv("APP_DB_CONN_MAX_LIFETIME", "30m")
+		t.Setenv("APP_DB_LOG_LEVEL", "warn")
+		t.Setenv("APP_DB_APPLICATION_NAME", "billing-api")
+
+		config, err := ConfigFromEnv("APP_DB")
+		if err != nil {
+			t.Fatalf("ConfigFromEnv failed: %v", err)
+		}
+		if config.Driver != "postgres" || config.DSN != "host=localhost dbname=app" {
+			t.Errorf("Unexpected driver or DSN: %+v", config)
+		}
+		if config.MaxOpenConns != 25 || config.ConnMaxLifetime != 30*time.Minute || config.LogLevel != logger.Warn {
+			t.Errorf("Unexpected pool settings: %+v", config)
+		}
+		if config.ApplicationName != "billing-api" {
+			t.Errorf("Expected application name billing-api, got %q", config.ApplicationName)
+		}
+		if config.MaxIdleConns != 0 {
+			t.Errorf("Expected unset MaxIdleConns to keep the default, got %d", config.MaxIdleConns)
+		}
+	})
+
+	t.Run("reports every invalid value", func(t *testing.T) {
+		t.Setenv("BAD_DRIVER", "oracle")
+		t.Setenv("BAD_MAX_IDLE_CONNS", "many")
+		t.Setenv("BAD_CONN_MAX_IDLE_TIME", "soon")
+
+		_, err := ConfigFromEnv("BAD")
+		if err == nil {
+			t.Fatal("Expected error for invalid environment")
+		}
+		for _, want := range []string{"BAD_DRIVER", "BAD_DSN is required", "BAD_MAX_IDLE_CONNS", "BAD_CONN_MAX_IDLE_TIME"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %q, got %v", want, err)
+			}
+		}
+	})
+}
+
+func TestApplicationName(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"postgres", Config{Driver: "postgres", DSN: "host=localhost", ApplicationName: "billing-api"},
+			"host=localhost application_name=billing-api"},
+		{"postgres keeps explicit name", Config{Driver: "postgres", DSN: "host=localhost application_name=cron", ApplicationName: "billing-api"},
+			"host=localhost application_name=cron"},
+		{"mysql", Config{Driver: "mysql", DSN: "user@tcp(localhost)/app", ApplicationName: "billing-api"},
+			"user@tcp(localhost)/app?connectionAttributes=program_name%3Abilling-api"},
+		{"sqlite", Config{Driver: "sqlite", DSN: "file:app.db", ApplicationName: "billing-api"},
+			"file:app.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.ResolvedDSN(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("appears in pg_stat_activity", func(t *testing.T) {
+		dsn := os.Getenv("DB_TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("DB_TEST_POSTGRES_DSN not set")
+		}
+
+		config := &Config{Driver: "postgres", DSN: dsn, ApplicationName: "db-module-test", LogLevel: logger.Silent}
+		database, err := New(config, postgres.Open(config.ResolvedDSN()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer database.Close()
+
+		var name string
+		err = database.Raw("SELECT application_name FROM pg_stat_activity WHERE pid = pg_backend_pid()").Scan(&name).Error
+		if err != nil {
+			t.Fatalf("Failed to query pg_stat_activity: %v", err)
+		}
+		if name != "db-module-test" {
+			t.Errorf("Expected application_name db-module-test, got %q", name)
+		}
+	})
+}
+
+func TestSessionSettings(t *testing.T) {
+	settings := map[string]string{"statement_timeout": "5s", "application_name": "billing"}
+
+	t.Run("passes settings through the postgres DSN", func(t *testing.T) {
+		config := &Config{Driver: "postgres", DSN: "host=localhost", SessionSettings: settings}
+		want := "host=localhost application_name=billing statement_timeout=5s"
+		if got := config.ResolvedDSN(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("passes settings through the mysql DSN", func(t *testing.T) {
+		config := &Config{
+			Driver:          "mysql",
+			DSN:             "user@tcp(localhost)/app?parseTime=true",
+			SessionSettings: map[string]string{"sql_mode": "'TRADITIONAL'"},
+		}
+		want := "user@tcp(localhost)/app?parseTime=true&sql_mode=%27TRADITIONAL%27"
+		if got := config.ResolvedDSN(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("rejects dialectors opened without the settings", func(t *testing.T) {
+		config := &Config{Driver: "postgres", DSN: "host=localhost", SessionSettings: settings, LogLevel: logger.Silent}
+		if _, err := New(config, postgres.Open(config.DSN)); err == nil || !strings.Contains(err.Error(), "application_name") {
+			t.Errorf("Expected an error naming the missing setting, got %v", err)
+		}
+		if err := checkSessionSettings(config, postgres.Open(config.ResolvedDSN())); err != nil {
+			t.Errorf("Expected the resolved DSN to pass, got %v", err)
+		}
+
+		config = &Config{Driver: "mysql", DSN: "user@tcp(localhost)/app", SessionSettings: map[string]string{"sql_mode": "'TRADITIONAL'"}}
+		if err := checkSessionSettings(config, mysql.Open(config.DSN)); err == nil {
+			t.Error("Expected an error for the bare mysql DSN")
+		}
+		if err := checkSessionSettings(config, mysql.Open(config.ResolvedDSN())); err != nil {
+			t.Errorf("Expected the resolved DSN to pass, got %v", err)
+		}
+		if err := checkSessionSettings(config, mysql.New(mysql.Config{Conn: &sql.DB{}})); err == nil {
+			t.Error("Expected an error for an existing connection")
+		}
+	})
+
+	t.Run("rejects settings on sqlite", func(t *testing.T) {
+		config := &Config{Driver: "sqlite", DSN: ":memory:", SessionSettings: settings, LogLevel: logger.Silent}
+		if _, err := New(config, sqlite.Open(config.ResolvedDSN())); err == nil {
+			t.Error("Expected error for session settings on sqlite")
+		}
+	})
+}
+
+// hooked counts the BeforeCreate calls of hookedNote
+var hooked int
+
+type hookedNote struct {
+	ID   uint `gorm:"primarykey"`
+	Body string
+}
+
+func (n *hookedNote) BeforeCreate(*gorm.DB) error {
+	hooked++
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	database := setupTestDB(t, func(c *Config) {
+		c.CircuitBreaker = &CircuitBreakerConfig{Threshold: 3, Window: time.Minute, Cooldown: 10 * time.Second}
+	})
+
+	type Note struct {
+		ID   uint `gorm:"primarykey"`
+		Body string
+	}
+	if err := database.AutoMigrate(&Note{}, &hookedNote{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Now()
+	database.breaker.now = func() time.Time { return now }
+
+	// Simulate an unreachable database by failing queries with ErrBadConn
+	var down atomic.Bool
+	err := database.Callback().Query().After("db:circuit_check").Before("gorm:query").Register("test:down", func(tx *gorm.DB) {
+		if down.Load() && tx.Error == nil {
+			tx.AddError(driver.ErrBadConn)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to register callback: %v", err)
+	}
+	query := func() error {
+		return database.Find(&[]Note{}).Error
+	}
+
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		if err := query(); !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("Expected ErrBadConn, got %v", err)
+		}
+	}
+	if state := database.CircuitState(); state != CircuitClosed {
+		t.Errorf("Expected closed below the threshold, got %s", state)
+	}
+
+	query()
+	if state := database.CircuitState(); state != CircuitOpen {
+		t.Fatalf("Expected open at the threshold, got %s", state)
+	}
+	if err := query(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	t.Run("reopens after a failed probe", func(t *testing.T) {
+		now = now.Add(11 * time.Second)
+		if state := database.CircuitState(); state != CircuitHalfOpen {
+			t.Fatalf("Expected half-open after the cooldown, got %s", state)
+		}
+		if err := query(); !errors.Is(err, driver.ErrBadConn) {
+			t.Errorf("Expected the probe to reach the database, got %v", err)
+		}
+		if state := database.CircuitState(); state != CircuitOpen {
+			t.Errorf("Expected open after a failed probe, got %s", state)
+		}
+	})
+
+	t.Run("closes after a successful probe", func(t *testing.T) {
+		now = now.Add(11 * time.Second)
+		down.Store(false)
+		if err := query(); err != nil {
+			t.Fatalf("Expected the probe to succeed, got %v", err)
+		}
+		if state := database.CircuitState(); state != CircuitClosed {
+			t.Errorf("Expected closed after a successful probe, got %s", state)
+		}
+	})
+
+	t.Run("releases a probe that panicked", func(t *testing.T) {
+		down.Store(true)
+		for i := 0; i < 3; i++ {
+			query()
+		}
+		now = now.Add(11 * time.Second)
+
+		var panicking atomic.Bool
+		panicking.Store(true)
+		database.Callback().Query().After("test:down").Register("test:panic", func(*gorm.DB) {
+			if panicking.Load() {
+				panic("probe panicked")
+			}
+		})
+		defer database.Callback().Query().Remove("test:panic")
+		func() {
+			defer func() { recover() }()
+			query()
+		}()
+		panicking.Store(false)
+
+		if err := query(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen while the probe is outstanding, got %v", err)
+		}
+		now = now.Add(11 * time.Second)
+		down.Store(false)
+		if err := query(); err != nil {
+			t.Fatalf("Expected a new probe to succeed, got %v", err)
+		}
+		if state := database.CircuitState(); state != CircuitClosed {
+			t.Errorf("Expected closed after the new probe, got %s", state)
+		}
+	})
+
+	t.Run("rejects writes before their hooks run", func(t *testing.T) {
+		down.Store(true)
+		for i := 0; i < 3; i++ {
+			query()
+		}
+		hooked = 0
+		if err := database.Create(&hookedNote{Body: "rejected"}).Error; !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("Expected ErrCircuitOpen, got %v", err)
+		}
+		if hooked != 0 {
+			t.Errorf("Expected BeforeCreate not to run, ran %d times", hooked)
+		}
+		now = now.Add(11 * time.Second)
+		down.Store(false)
+		query()
+	})
+
+	t.Run("ignores failures outside the window", func(t *testing.T) {
+		down.Store(true)
+		query()
+		query()
+		now = now.Add(2 * time.Minute)
+		query()
+		if state := database.CircuitState(); state != CircuitClosed {
+			t.Errorf("Expected closed, got %s", state)
+		}
+	})
+
+	t.Run("ignores statement errors", func(t *testing.T) {
+		down.Store(false)
+		for i := 0; i < 5; i++ {
+			database.Exec("INSERT INTO missing_table VALUES (1)")
+		}
+		if state := database.CircuitState(); state != CircuitClosed {
+			t.Errorf("Expected closed, got %s", state)
+		}
+	})
+
+	t.Run("validates config", func(t *testing.T) {
+		config := &Config{Driver: "sqlite", DSN: ":memory:", LogLevel: logger.Silent, CircuitBreaker: &CircuitBreakerConfig{Threshold: 0, Cooldown: time.Second}}
+		if _, err := New(config, sqlite.Open(config.DSN)); err == nil {
+			t.Error("Expected error for zero threshold")
+		}
+	})
+}
+
+func TestDumpSchema(t *testing.T) {
+	database := setupTestDB(t)
+
+	type Author struct {
+		ID   uint   `gorm:"primarykey"`
+		Name string `gorm:"size:100;index"`
+	}
+	type Book struct {
+		ID       uint   `gorm:"primarykey"`
+		Title    string `gorm:"size:200;uniqueIndex"`
+		AuthorID uint
+		Author   Author
+	}
+
+	ddl, err := database.DumpSchema(context.Background(), &Book{}, &Author{})
+	if err != nil {
+		t.Fatalf("DumpSchema failed: %v", err)
+	}
+
+	want := []string{
+		"CREATE TABLE `authors` (",
+		"CREATE INDEX `idx_authors_name` ON `authors`(`name`);",
+		"CREATE TABLE `books` (",
+		"FOREIGN KEY (`author_id`) REFERENCES `authors`(`id`)",
+		"CREATE UNIQUE INDEX `idx_books_title` ON `books`(`title`);",
+	}
+	last := -1
+	for _, part := range want {
+		i := strings.Index(ddl, part)
+		if i < 0 {
+			t.Fatalf("Expected %q in:\n%s", part, ddl)
+		}
+		if i < last {
+			t.Errorf("Expected %q after the previous statement in:\n%s", part, ddl)
+		}
+		last = i
+	}
+
+	if database.Migrator().HasTable(&Book{}) {
+		t.Error("Expected DumpSchema not to create tables")
+	}
+}
+
+// The note models map the same table at successive versions
+type noteV1 struct {
+	ID    uint   `gorm:"primarykey"`
+	Title string `gorm:"size:200"`
+	Score int64
+}
+
+func (noteV1) TableName() string { return "notes" }
+
+type noteWider struct {
+	ID     uint   `gorm:"primarykey"`
+	Title  string `gorm:"size:300"`
+	Score  int64
+	Rating int
+}
+
+func (noteWider) TableName() string { return "notes" }
+
+type noteRetyped struct {
+	ID    uint `gorm:"primarykey"`
+	Title bool
+	Score int64
+}
+
+func (noteRetyped) TableName() string { return "notes" }
+
+func TestSafeMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reports drift", func(t *testing.T) {
+		database := setupTestDB(t)
+
+		changes, err := database.CheckMigrations(ctx, &noteV1{})
+		if err != nil {
+			t.Fatalf("CheckMigrations failed: %v", err)
+		}
+		if len(changes) != 1 || changes[0].Kind != MigrationCreateTable || changes[0].Destructive {
+			t.Fatalf("Expected a single create table change, got %v", changes)
+		}
+
+		if err := database.SafeMigrate(ctx, &noteV1{}); err != nil {
+			t.Fatalf("SafeMigrate failed: %v", err)
+		}
+		changes, err = database.CheckMigrations(ctx, &noteV1{})
+		if err != nil {
+			t.Fatalf("CheckMigrations failed: %v", err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("Expected no drift after migrating, got %v", changes)
+		}
+	})
+
+	t.Run("applies safe changes", func(t *testing.T) {
+		database := setupTestDB(t)
+		if err := database.SafeMigrate(ctx, &noteV1{}); err != nil {
+			t.Fatalf("SafeMigrate failed: %v", err)
+		}
+
+		changes, err := database.CheckMigrations(ctx, &noteWider{})
+		if err != nil {
+			t.Fatalf("CheckMigrations failed: %v", err)
+		}
+		for _, change := range changes {
+			if change.Destructive {
+				t.Errorf("Expected %s not to be destructive", change)
+			}
+		}
+
+		if err := database.SafeMigrate(ctx, &noteWider{}); err != nil {
+			t.Fatalf("SafeMigrate failed: %v", err)
+		}
+		if !database.Migrator().HasColumn(&noteWider{}, "rating") {
+			t.Error("Expected the rating column to be added")
+		}
+	})
+
+	t.Run("refuses destructive changes", func(t *testing.T) {
+		database := setupTestDB(t)
+		if err := database.SafeMigrate(ctx, &noteV1{}); err != nil {
+			t.Fatalf("SafeMigrate failed: %v", err)
+		}
+
+		err := database.SafeMigrate(ctx, &noteRetyped{})
+		if !errors.Is(err, ErrDestructiveMigration) {
+			t.Fatalf("Expected ErrDestructiveMigration, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "alter column notes.title") {
+			t.Errorf("Expected the error to name the column, got %v", err)
+		}
+
+		changes, err := database.CheckMigrations(ctx, &noteV1{})
+		if err != nil {
+			t.Fatalf("CheckMigrations failed: %v", err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("Expected nothing to be migrated, got drift %v", changes)
+		}
+	})
+
+	t.Run("AllowDestructive overrides", func(t *testing.T) {
+		database := setupTestDB(t, func(config *Config) { config.AllowDestructive = true })
+		if err := database.SafeMigrate(ctx, &noteV1{}); err != nil {
+			t.Fatalf("SafeMigrate failed: %v", err)
+		}
+		if err := database.SafeMigrate(ctx, &noteRetyped{}); err != nil {
+			t.Fatalf("Expected AllowDestructive to migrate, got %v", err)
+		}
+		changes, err := database.CheckMigrations(ctx, &noteRetyped{})
+		if err != nil {
+			t.Fatalf("CheckMigrations failed: %v", err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("Expected no drift after migrating, got %v", changes)
+		}
+	})
+}
+
+func TestColumnChange(t *testing.T) {
+	// SQLite declares no lengths, so narrowing is checked against PostgreSQL
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost user=app dbname=app"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run database: %v", err)
+	}
+
+	type model struct {
+		ID     uint
+		Name   string  `gorm:"size:50"`
+		Code   string  `gorm:"size:20"`
+		Count  int32   `gorm:"type:int"`
+		Total  int64   `gorm:"type:bigint"`
+		Amount float64 `gorm:"type:numeric(8,2);precision:8;scale:2"`
+		Label  string
+	}
+	stmt := &gorm.Statement{DB: gormDB}
+	if err := stmt.Parse(&model{}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	column := func(name, typ string, length, precision, scale int64) gorm.ColumnType {
+		return migrator.ColumnType{
+			NameValue:        sql.NullString{String: name, Valid: true},
+			DataTypeValue:    sql.NullString{String: typ, Valid: true},
+			LengthValue:      sql.NullInt64{Int64: length, Valid: true},
+			DecimalSizeValue: sql.NullInt64{Int64: precision, Valid: true},
+			ScaleValue:       sql.NullInt64{Int64: scale, Valid: true},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		column      gorm.ColumnType
+		changed     bool
+		destructive bool
+	}{
+		{"same string", column("name", "varchar", 50, 0, 0), false, false},
+		{"shorter string", column("name", "varchar", 200, 0, 0), true, true},
+		{"longer string", column("code", "varchar", 10, 0, 0), false, false},
+		{"text to varchar", column("code", "text", 0, 0, 0), true, true},
+		{"bigint to int", column("count", "int8", 0, 0, 0), true, true},
+		{"int to bigint", column("total", "int4", 0, 0, 0), true, false},
+		{"lower precision", column("amount", "numeric", 0, 10, 2), true, true},
+		{"integer to text", column("label", "int4", 0, 0, 0), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := stmt.Schema.LookUpField(tt.column.Name())
+			change, changed := columnChange(gormDB.Migrator(), stmt.Schema, field, tt.column)
+			if changed != tt.changed || change.Destructive != tt.destructive {
+				t.Errorf("Expected changed %v and destructive %v, got %v and %+v", tt.changed, tt.destructive, changed, change)
+			}
+		})
+	}
+}
+
+func TestSQLDB(t *testing.T) {
+	database := setupTestDB(t)
+
+	sqlDB, err := database.SQLDB()
+	if err != nil {
+		t.Fatalf("SQLDB failed: %v", err)
+	}
+	if err := sqlDB.PingContext(context.Background()); err != nil {
+		t.Errorf("Expected the pool to be usable, got %v", err)
+	}
+	if got := sqlDB.Stats().MaxOpenConnections; got != 1 {
+		t.Errorf("Expected the configured pool with 1 max open connection, got %d", got)
+	}
+
+	if _, err := (&DB{}).SQLDB(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+}
+
+func TestResetStatementCache(t *testing.T) {
+	database := setupTestDB(t, func(c *Config) { c.PrepareStmt = true })
+	prepared, ok := database.DB.ConnPool.(*gorm.PreparedStmtDB)
+	if !ok {
+		t.Fatalf("Expected a prepared statement pool, got %T", database.DB.ConnPool)
+	}
+
+	if err := database.AutoMigrate(&noteV1{}); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	if keys := prepared.Stmts.Keys(); len(keys) != 0 {
+		t.Errorf("Expected AutoMigrate to reset the cache, got %d statements", len(keys))
+	}
+
+	var notes []noteV1
+	if err := database.Find(&notes).Error; err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(prepared.Stmts.Keys()) == 0 {
+		t.Fatal("Expected the query to be cached")
+	}
+
+	if err := database.ResetStatementCache(); err != nil {
+		t.Fatalf("ResetStatementCache failed: %v", err)
+	}
+	if keys := prepared.Stmts.Keys(); len(keys) != 0 {
+		t.Errorf("Expected an empty cache, got %v", keys)
+	}
+	if err := database.Find(&notes).Error; err != nil {
+		t.Errorf("Expected queries to work after the reset, got %v", err)
+	}
+
+	if err := (&DB{}).ResetStatementCache(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+}
+
+func TestStartTransactionMonitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	report := func(LongTransaction) {}
+
+	t.Run("requires postgres", func(t *testing.T) {
+		database := setupTestDB(t)
+		if err := database.StartTransactionMonitor(ctx, time.Second, time.Minute, report); err == nil {
+			t.Error("Expected an error on sqlite")
+		}
+	})
+
+	t.Run("validates arguments", func(t *testing.T) {
+		gormDB, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+			DryRun:               true,
+			DisableAutomaticPing: true,
+			Logger:               logger.Discard,
+		})
+		if err != nil {
+			t.Fatalf("Failed to open dry-run database: %v", err)
+		}
+		database := &DB{DB: gormDB}
+
+		if err := database.StartTransactionMonitor(ctx, 0, time.Minute, report); err == nil {
+			t.Error("Expected an error for a zero interval")
+		}
+		if err := database.StartTransactionMonitor(ctx, time.Second, 0, report); err == nil {
+			t.Error("Expected an error for a zero threshold")
+		}
+		if err := database.StartTransactionMonitor(ctx, time.Second, time.Minute, nil); err == nil {
+			t.Error("Expected an error for a nil report")
+		}
+	})
+
+	t.Run("reports idle transactions", func(t *testing.T) {
+		dsn := os.Getenv("DB_TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("DB_TEST_POSTGRES_DSN not set")
+		}
+
+		config := &Config{Driver: "postgres", DSN: dsn, ApplicationName: "db-module-txmonitor-test", LogLevel: logger.Silent}
+		database, err := New(config, postgres.Open(config.ResolvedDSN()))
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer database.Close()
+
+		tx := database.Begin()
+		defer tx.Rollback()
+		var pid int
+		if err := tx.Raw("SELECT pg_backend_pid()").Scan(&pid).Error; err != nil {
+			t.Fatalf("Failed to start transaction: %v", err)
+		}
+
+		reported := make(chan LongTransaction, 10)
+		err = database.StartTransactionMonitor(ctx, 50*time.Millisecond, 100*time.Millisecond, func(lt LongTransaction) {
+			select {
+			case reported <- lt:
+			default:
+			}
+		})
+		if err != nil {
+			t.Fatalf("StartTransactionMonitor failed: %v", err)
+		}
+
+		select {
+		case lt := <-reported:
+			if lt.PID != pid || lt.State != "idle in transaction" || lt.Duration < 100*time.Millisecond {
+				t.Errorf("Expected the idle transaction of pid %d, got %+v", pid, lt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the idle transaction to be reported")
+		}
+	})
+}
+
+type uowAccount struct {
+	ID      uint `gorm:"primarykey"`
+	Balance int
+}
+
+type uowEntry struct {
+	ID        uint `gorm:"primarykey"`
+	AccountID uint
+	Amount    int
+}
+
+func TestUnitOfWork(t *testing.T) {
+	database := setupTestDB(t)
+	if err := database.AutoMigrate(&uowAccount{}, &uowEntry{}); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	ctx := context.Background()
+	account := &uowAccount{Balance: 100}
+	if err := NewRepository[uowAccount](database).Create(ctx, account); err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	withdraw := func(u *UnitOfWork, amount int) error {
+		if err := Repo[uowEntry](u).Create(ctx, &uowEntry{AccountID: account.ID, Amount: -amount}); err != nil {
+			return err
+		}
+		return Repo[uowAccount](u).Decrement(ctx, account.ID, "balance", amount, repository.NonNegative())
+	}
+	counts := func() (int, int64) {
+		var stored uowAccount
+		if err := NewRepository[uowAccount](database).FindByID(ctx, account.ID, &stored); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		entries, err := NewRepository[uowEntry](database).Count(ctx)
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		return stored.Balance, entries
+	}
+
+	t.Run("commits every repository together", func(t *testing.T) {
+		err := NewUnitOfWork(database).Run(ctx, func(u *UnitOfWork) error {
+			return withdraw(u, 30)
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if balance, entries := counts(); balance != 70 || entries != 1 {
+			t.Errorf("Expected balance 70 and 1 entry, got %d and %d", balance, entries)
+		}
+	})
+
+	t.Run("rolls every repository back together", func(t *testing.T) {
+		err := NewUnitOfWork(database).Run(ctx, func(u *UnitOfWork) error {
+			return withdraw(u, 500)
+		})
+		if !errors.Is(err, repository.ErrNegativeCounter) {
+			t.Fatalf("Expected ErrNegativeCounter, got %v", err)
+		}
+		if balance, entries := counts(); balance != 70 || entries != 1 {
+			t.Errorf("Expected the entry to be rolled back with the balance, got %d and %d", balance, entries)
+		}
+	})
+
+	t.Run("nested runs use savepoints", func(t *testing.T) {
+		err := NewUnitOfWork(database).Run(ctx, func(u *UnitOfWork) error {
+			if err := withdraw(u, 10); err != nil {
+				return err
+			}
+			if err := u.Run(ctx, func(inner *UnitOfWork) error { return withdraw(inner, 500) }); err == nil {
+				t.Error("Expected the nested withdrawal to fail")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if balance, entries := counts(); balance != 60 || entries != 2 {
+			t.Errorf("Expected only the outer withdrawal to commit, got %d and %d", balance, entries)
+		}
+	})
+}
+
+func TestReplicaLagChecker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("validates config", func(t *testing.T) {
+		if _, err := NewReplicaLagChecker(ReplicaLagConfig{Interval: time.Second}); err == nil {
+			t.Error("Expected an error for a zero threshold")
+		}
+		if _, err := NewReplicaLagChecker(ReplicaLagConfig{Threshold: time.Second}); err == nil {
+			t.Error("Expected an error for a zero interval")
+		}
+	})
+
+	t.Run("requires postgres", func(t *testing.T) {
+		checker, err := NewReplicaLagChecker(ReplicaLagConfig{Threshold: time.Second, Interval: time.Second})
+		if err != nil {
+			t.Fatalf("NewReplicaLagChecker failed: %v", err)
+		}
+		config := &Config{Driver: "sqlite", DSN: ":memory:", LogLevel: logger.Silent, Plugins: []gorm.Plugin{checker}}
+		if _, err := New(config, sqlite.Open(config.DSN)); err == nil {
+			t.Error("Expected registering on sqlite to fail")
+		}
+		if err := checker.Start(ctx); err == nil {
+			t.Error("Expected Start to fail before registration")
+		}
+	})
+
+	t.Run("reads go to the primary while replicas lag", func(t *testing.T) {
+		type lagRecord struct {
+			ID   uint `gorm:"primarykey"`
+			Name string
+		}
+
+		dir := t.TempDir()
+		primary, replica := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+		for _, path := range []string{primary, replica} {
+			gormDB, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", path, err)
+			}
+			if err := gormDB.AutoMigrate(&lagRecord{}); err != nil {
+				t.Fatalf("AutoMigrate failed: %v", err)
+			}
+			sqlDB, _ := gormDB.DB()
+			sqlDB.Close()
+		}
+
+		gormDB, err := gorm.Open(sqlite.Open(primary), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			t.Fatalf("Failed to open primary: %v", err)
+		}
+		if err := gormDB.Use(dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{sqlite.Open(replica)}})); err != nil {
+			t.Fatalf("Failed to register dbresolver: %v", err)
+		}
+		t.Cleanup(func() {
+			sqlDB, _ := gormDB.DB()
+			sqlDB.Close()
+		})
+
+		checker, err := NewReplicaLagChecker(ReplicaLagConfig{Threshold: time.Second, Interval: time.Hour})
+		if err != nil {
+			t.Fatalf("NewReplicaLagChecker failed: %v", err)
+		}
+		var lag atomic.Int64
+		checker.measure = func(context.Context, gorm.ConnPool) (time.Duration, error) {
+			return time.Duration(lag.Load()), nil
+		}
+		if err := checker.register(gormDB); err != nil {
+			t.Fatalf("register failed: %v", err)
+		}
+
+		if err := gormDB.Create(&lagRecord{Name: "fresh"}).Error; err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		count := func() int64 {
+			var n int64
+			if err := gormDB.Model(&lagRecord{}).Count(&n).Error; err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			return n
+		}
+
+		if n := count(); n != 0 {
+			t.Fatalf("Expected the read to go to the empty replica, got %d rows", n)
+		}
+
+		lag.Store(int64(10 * time.Second))
+		checker.check(ctx)
+		if got := checker.Lag(); got != 10*time.Second {
+			t.Errorf("Expected a lag of 10s, got %s", got)
+		}
+		if n := count(); n != 1 {
+			t.Errorf("Expected the read to go to the primary, got %d rows", n)
+		}
+
+		lag.Store(0)
+		checker.check(ctx)
+		if n := count(); n != 0 {
+			t.Errorf("Expected reads to return to the replica, got %d rows", n)
+		}
+	})
+}
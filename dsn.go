@@ -0,0 +1,126 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+)
+
+// ResolvedDSN returns DSN with the driver parameters implied by the rest of
+// the config appended, for opening the dialector:
+//
+//	db.New(config, mysql.Open(config.ResolvedDSN()))
+//
+// Parameters already present in DSN are kept as they are.
+func (c *Config) ResolvedDSN() string {
+	dsn := c.DSN
+	if c.TimeZone != nil {
+		switch dialect.Normalize(c.Driver) {
+		case dialect.MySQL:
+			dsn = setDSNParam(c.Driver, dsn, "loc", c.TimeZone.String())
+		case dialect.Postgres:
+			dsn = setDSNParam(c.Driver, dsn, "TimeZone", c.TimeZone.String())
+		case dialect.SQLite:
+			dsn = setDSNParam(c.Driver, dsn, "_loc", c.TimeZone.String())
+		}
+	}
+
+	if c.ApplicationName != "" {
+		switch dialect.Normalize(c.Driver) {
+		case dialect.MySQL:
+			dsn = setDSNParam(c.Driver, dsn, "connectionAttributes", "program_name:"+c.ApplicationName)
+		case dialect.Postgres:
+			dsn = setDSNParam(c.Driver, dsn, "application_name", c.ApplicationName)
+		}
+	}
+
+	switch dialect.Normalize(c.Driver) {
+	case dialect.MySQL, dialect.Postgres:
+		names := make([]string, 0, len(c.SessionSettings))
+		for name := range c.SessionSettings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dsn = setDSNParam(c.Driver, dsn, name, c.SessionSettings[name])
+		}
+	}
+	return dsn
+}
+
+// setDSNParam adds key=value to dsn unless it already sets key. Postgres
+// keyword/value DSNs get a space-separated pair; URL and mysql style DSNs get
+// a query parameter.
+func setDSNParam(driver, dsn, key, value string) string {
+	if dialect.Normalize(driver) == dialect.Postgres && !strings.Contains(dsn, "://") {
+		for _, pair := range strings.Fields(dsn) {
+			if k, _, ok := strings.Cut(pair, "="); ok && strings.EqualFold(k, key) {
+				return dsn
+			}
+		}
+		if strings.ContainsAny(value, ` '\`) {
+			value = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+		}
+		if dsn == "" {
+			return key + "=" + value
+		}
+		return dsn + " " + key + "=" + value
+	}
+
+	sep := "?"
+	if i := strings.LastIndex(dsn, "?"); i >= 0 {
+		sep = "&"
+		if query, err := url.ParseQuery(dsn[i+1:]); err == nil && query.Has(key) {
+			return dsn
+		}
+	}
+	return dsn + sep + url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+// checkSessionSettings fails unless the DSN the dialector was opened with
+// carries every session setting, which only ResolvedDSN puts there. Without
+// the check a dialector opened with the bare DSN or a custom connection would
+// run with none of the settings and no sign of it.
+func checkSessionSettings(c *Config, dialector gorm.Dialector) error {
+	if len(c.SessionSettings) == 0 {
+		return nil
+	}
+	driver := dialect.Normalize(dialector.Name())
+	if driver == dialect.SQLite {
+		return errors.New("session settings are not supported on sqlite")
+	}
+
+	dsn := dialectorDSN(dialector)
+	names := make([]string, 0, len(c.SessionSettings))
+	for name := range c.SessionSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if setDSNParam(driver, dsn, name, c.SessionSettings[name]) != dsn {
+			return fmt.Errorf("session setting %q is missing from the DSN; open the dialector with Config.ResolvedDSN()", name)
+		}
+	}
+	return nil
+}
+
+// dialectorDSN returns the DSN of the postgres and mysql dialectors, which
+// both keep it in a DSN field of their config, or "" for a dialector opened
+// with an existing connection or of another driver
+func dialectorDSN(dialector gorm.Dialector) string {
+	v := reflect.Indirect(reflect.ValueOf(dialector))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	field := v.FieldByName("DSN")
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return ""
+	}
+	return field.String()
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm/logger"
+)
+
+// ConfigFromEnv builds a Config from environment variables named prefix plus
+// an underscore plus the setting, e.g. DB_DSN for prefix "DB":
+//
+//	DRIVER              mysql, postgres or sqlite (required)
+//	DSN                 data source name (required)
+//	MAX_OPEN_CONNS      integer
+//	MAX_IDLE_CONNS      integer
+//	CONN_MAX_LIFETIME   duration such as 1h or 30m
+//	CONN_MAX_IDLE_TIME  duration
+//	LOG_LEVEL           silent, error, warn or info
+//	TIME_ZONE           IANA location name such as Europe/Berlin
+//	APPLICATION_NAME    connection label such as billing-api
+//
+// Unset variables keep the defaults applied by New. Every invalid or missing
+// value is reported, joined into one error.
+func ConfigFromEnv(prefix string) (*Config, error) {
+	env := func(name string) (string, string) {
+		key := name
+		if prefix != "" {
+			key = prefix + "_" + name
+		}
+		return key, strings.TrimSpace(os.Getenv(key))
+	}
+
+	config := &Config{}
+	var errs []error
+
+	if key, value := env("DRIVER"); value == "" {
+		errs = append(errs, fmt.Errorf("%s is required", key))
+	} else if driver := dialect.Normalize(value); driver != dialect.MySQL && driver != dialect.Postgres && driver != dialect.SQLite {
+		errs = append(errs, fmt.Errorf("%s: unsupported driver %q", key, value))
+	} else {
+		config.Driver = driver
+	}
+
+	if key, value := env("DSN"); value == "" {
+		errs = append(errs, fmt.Errorf("%s is required", key))
+	} else {
+		config.DSN = value
+	}
+
+	for _, setting := range []struct {
+		name   string
+		target *int
+	}{
+		{"MAX_OPEN_CONNS", &config.MaxOpenConns},
+		{"MAX_IDLE_CONNS", &config.MaxIdleConns},
+	} {
+		key, value := env(setting.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid non-negative integer %q", key, value))
+			continue
+		}
+		*setting.target = n
+	}
+
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"CONN_MAX_LIFETIME", &config.ConnMaxLifetime},
+		{"CONN_MAX_IDLE_TIME", &config.ConnMaxIdleTime},
+	} {
+		key, value := env(setting.name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid duration %q", key, value))
+			continue
+		}
+		*setting.target = d
+	}
+
+	if key, value := env("LOG_LEVEL"); value != "" {
+		level, ok := map[string]logger.LogLevel{
+			"silent": logger.Silent,
+			"error":  logger.Error,
+			"warn":   logger.Warn,
+			"info":   logger.Info,
+		}[strings.ToLower(value)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: invalid log level %q", key, value))
+		}
+		config.LogLevel = level
+	}
+
+	if key, value := env("TIME_ZONE"); value != "" {
+		loc, err := time.LoadLocation(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+		config.TimeZone = loc
+	}
+
+	if _, value := env("APPLICATION_NAME"); value != "" {
+		config.ApplicationName = value
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return config, nil
+}
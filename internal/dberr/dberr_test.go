@@ -0,0 +1,31 @@
+package dberr
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestIsConnection(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{&net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{gorm.ErrRecordNotFound, false},
+		{errors.New("duplicate key"), false},
+	}
+	for _, tt := range tests {
+		if got := IsConnection(tt.err); got != tt.want {
+			t.Errorf("IsConnection(%v) = %v, expected %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Package dialect normalizes the names GORM dialectors report so the db and
+// repository packages can branch on them consistently.
+package dialect
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Normalized dialect names
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// Of returns the normalized dialect name of db's dialector: "postgres",
+// "mysql" or "sqlite", or the dialector's own name in lower case for other
+// drivers. It returns "" when db has no dialector.
+func Of(db *gorm.DB) string {
+	if db == nil || db.Dialector == nil {
+		return ""
+	}
+	return Normalize(db.Dialector.Name())
+}
+
+// Normalize maps a driver or dialector name to its normalized dialect name
+func Normalize(name string) string {
+	name = strings.ToLower(name)
+	switch name {
+	case "postgres", "postgresql", "pgx":
+		return Postgres
+	case "mysql", "mariadb":
+		return MySQL
+	case "sqlite", "sqlite3":
+		return SQLite
+	}
+	return name
+}
+
+// MaxParams returns the number of bind parameters a single statement may
+// carry on the dialect. SQLite is assumed to use its historical limit of 999,
+// since builds differ, and unknown dialects get the same conservative value.
+func MaxParams(name string) int {
+	switch Normalize(name) {
+	case Postgres, MySQL:
+		return 65535
+	}
+	return 999
+}
@@ -0,0 +1,50 @@
+package dialect
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"postgres":  Postgres,
+		"pgx":       Postgres,
+		"mysql":     MySQL,
+		"MariaDB":   MySQL,
+		"sqlite":    SQLite,
+		"sqlite3":   SQLite,
+		"sqlserver": "sqlserver",
+	}
+	for name, want := range tests {
+		if got := Normalize(name); got != want {
+			t.Errorf("Normalize(%q): expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if got := Of(db); got != SQLite {
+		t.Errorf("Expected %q, got %q", SQLite, got)
+	}
+	if got := Of(nil); got != "" {
+		t.Errorf("Expected empty name for nil db, got %q", got)
+	}
+}
+
+func TestMaxParams(t *testing.T) {
+	if got := MaxParams("postgres"); got != 65535 {
+		t.Errorf("Expected 65535 for postgres, got %d", got)
+	}
+	if got := MaxParams("sqlite3"); got != 999 {
+		t.Errorf("Expected 999 for sqlite, got %d", got)
+	}
+	if got := MaxParams("unknown"); got != 999 {
+		t.Errorf("Expected 999 for unknown dialects, got %d", got)
+	}
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// maskedPlaceholder replaces masked parameter values in SQL logs
+const maskedPlaceholder = "***"
+
+type maskedValuesKey struct{}
+
+// masker collects the values of masked columns bound by a statement so the
+// logger can redact them. A column is masked when it is listed in
+// Config.MaskedColumns or its field is tagged gorm:"mask".
+//
+// Values are collected from the model being written, from struct and map
+// updates and from where conditions. A raw condition such as
+// "email = ? AND name = ?" that mentions a masked column has all of its
+// parameters masked. Parameters of Raw and Exec statements cannot be
+// attributed to a column and are logged as is.
+type masker struct {
+	columns map[string]bool
+}
+
+// registerMasking wraps the logger and registers the callbacks that record
+// masked values on every statement
+func registerMasking(db *gorm.DB, columns []string) error {
+	m := &masker{columns: make(map[string]bool, len(columns))}
+	for _, col := range columns {
+		m.columns[col] = true
+	}
+
+	db.Logger = maskingLogger{Interface: db.Logger}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("db:mask", m.collect); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("db:mask", m.collect); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("db:mask", m.collect); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("db:mask", m.collect); err != nil {
+		return err
+	}
+	return cb.Row().Before("gorm:row").Register("db:mask", m.collect)
+}
+
+// collect records the masked values of the statement in its context
+func (m *masker) collect(db *gorm.DB) {
+	stmt := db.Statement
+
+	masked := map[string]bool{}
+	for col := range m.columns {
+		masked[col] = true
+	}
+	addTagged(masked, stmt.Schema)
+
+	// Updates with a struct of another type than the model, or another value
+	// of it, writes the values of that struct
+	destSchema, dest := structDest(stmt)
+	addTagged(masked, destSchema)
+	if len(masked) == 0 {
+		return
+	}
+
+	var values []interface{}
+	if stmt.Schema != nil && stmt.ReflectValue.IsValid() {
+		values = append(values, modelValues(stmt.Context, stmt.Schema, stmt.ReflectValue, masked)...)
+	}
+	if destSchema != nil {
+		values = append(values, modelValues(stmt.Context, destSchema, dest, masked)...)
+	}
+	if updates, ok := stmt.Dest.(map[string]interface{}); ok {
+		for col, value := range updates {
+			if masked[col] {
+				values = append(values, value)
+			}
+		}
+	}
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		values = append(values, conditionValues(where.Exprs, masked)...)
+	}
+
+	if len(values) > 0 {
+		stmt.Context = context.WithValue(stmt.Context, maskedValuesKey{}, values)
+	}
+}
+
+// addTagged adds the columns of s tagged gorm:"mask" to masked
+func addTagged(masked map[string]bool, s *schema.Schema) {
+	if s == nil {
+		return
+	}
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings["MASK"]; ok && field.DBName != "" {
+			masked[field.DBName] = true
+		}
+	}
+}
+
+// structDest returns the schema and value of a struct Dest that is not the
+// statement's model, or nil when Dest is the model or no struct
+func structDest(stmt *gorm.Statement) (*schema.Schema, reflect.Value) {
+	if stmt.Dest == nil {
+		return nil, reflect.Value{}
+	}
+	dv := reflect.ValueOf(stmt.Dest)
+	if dv.Kind() == reflect.Pointer && stmt.Dest == stmt.Model {
+		return nil, reflect.Value{}
+	}
+	rv := reflect.Indirect(dv)
+	if rv.Kind() != reflect.Struct {
+		return nil, reflect.Value{}
+	}
+
+	parsed := &gorm.Statement{DB: stmt.DB}
+	if err := parsed.Parse(stmt.Dest); err != nil {
+		return nil, reflect.Value{}
+	}
+	return parsed.Schema, rv
+}
+
+// modelValues returns the masked field values of rv, a struct or slice of
+// structs of schema s
+func modelValues(ctx context.Context, s *schema.Schema, rv reflect.Value, masked map[string]bool) []interface{} {
+	var fields []*schema.Field
+	for _, field := range s.Fields {
+		if masked[field.DBName] {
+			fields = append(fields, field)
+		}
+	}
+
+	var values []interface{}
+	collect := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		for _, field := range fields {
+			if value, zero := field.ValueOf(ctx, rv); !zero {
+				values = append(values, value)
+			}
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collect(rv.Index(i))
+		}
+	case reflect.Struct:
+		collect(rv)
+	}
+	return values
+}
+
+// conditionValues returns the parameters of conditions on masked columns
+func conditionValues(exprs []clause.Expression, masked map[string]bool) []interface{} {
+	var values []interface{}
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case clause.Eq:
+			if masked[columnName(e.Column)] {
+				values = append(values, e.Value)
+			}
+		case clause.Neq:
+			if masked[columnName(e.Column)] {
+				values = append(values, e.Value)
+			}
+		case clause.Like:
+			if masked[columnName(e.Column)] {
+				values = append(values, e.Value)
+			}
+		case clause.IN:
+			if masked[columnName(e.Column)] {
+				values = append(values, e.Values...)
+			}
+		case clause.Expr:
+			if mentionsColumn(e.SQL, masked) {
+				values = append(values, flattenVars(e.Vars)...)
+			}
+		case clause.NamedExpr:
+			if mentionsColumn(e.SQL, masked) {
+				values = append(values, flattenVars(e.Vars)...)
+			}
+		case clause.AndConditions:
+			values = append(values, conditionValues(e.Exprs, masked)...)
+		case clause.OrConditions:
+			values = append(values, conditionValues(e.Exprs, masked)...)
+		case clause.NotConditions:
+			values = append(values, conditionValues(e.Exprs, masked)...)
+		}
+	}
+	return values
+}
+
+// columnName returns the column name of an expression column
+func columnName(col interface{}) string {
+	switch c := col.(type) {
+	case string:
+		return c
+	case clause.Column:
+		return c.Name
+	}
+	return ""
+}
+
+// mentionsColumn reports whether raw SQL refers to one of the columns as a
+// whole identifier
+func mentionsColumn(sql string, columns map[string]bool) bool {
+	lower := strings.ToLower(sql)
+	for col := range columns {
+		col = strings.ToLower(col)
+		for i := strings.Index(lower, col); i >= 0; {
+			end := i + len(col)
+			if (i == 0 || !isIdentByte(lower[i-1])) && (end == len(lower) || !isIdentByte(lower[end])) {
+				return true
+			}
+			next := strings.Index(lower[i+1:], col)
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	return false
+}
+
+// isIdentByte reports whether b can be part of an unquoted SQL identifier
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9'
+}
+
+// flattenVars expands slice parameters, as used with IN (?), into their elements
+func flattenVars(vars []interface{}) []interface{} {
+	var values []interface{}
+	for _, v := range vars {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < rv.Len(); i++ {
+				values = append(values, rv.Index(i).Interface())
+			}
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// maskingLogger redacts the masked values recorded in the statement context
+// from the parameters of logged SQL
+type maskingLogger struct {
+	logger.Interface
+}
+
+// LogMode implements logger.Interface
+func (l maskingLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return maskingLogger{Interface: l.Interface.LogMode(level)}
+}
+
+// ParamsFilter implements gorm.ParamsFilter
+func (l maskingLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if filter, ok := l.Interface.(gorm.ParamsFilter); ok {
+		sql, params = filter.ParamsFilter(ctx, sql, params...)
+	}
+
+	masked, _ := ctx.Value(maskedValuesKey{}).([]interface{})
+	if len(masked) == 0 || len(params) == 0 {
+		return sql, params
+	}
+
+	redacted := make([]interface{}, len(params))
+	for i, param := range params {
+		redacted[i] = param
+		for _, value := range masked {
+			if value != nil && reflect.DeepEqual(param, value) {
+				redacted[i] = maskedPlaceholder
+				break
+			}
+		}
+	}
+	return sql, redacted
+}
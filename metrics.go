@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MetricsCollector receives database metrics
+type MetricsCollector interface {
+	// ObservePoolStats records a snapshot of the connection pool statistics
+	ObservePoolStats(stats PoolStats)
+}
+
+// RowsObserver is implemented by a MetricsCollector that also tracks mutation
+// volume, e.g. for rows written per second per table
+type RowsObserver interface {
+	// ObserveRows records the rows affected by a successful statement.
+	// operation is "create", "update" or "delete".
+	ObserveRows(operation, table string, rows int64)
+}
+
+// StartMetricsExport pushes the pool statistics to collector right away and
+// then every interval, from a background goroutine that stops when ctx is done.
+//
+// When collector also implements RowsObserver it is told the rows affected by
+// every create, update and delete until ctx is done. Only one observer is
+// active per DB; a later StartMetricsExport replaces it.
+func (db *DB) StartMetricsExport(ctx context.Context, interval time.Duration, collector MetricsCollector) error {
+	if db.DB == nil {
+		return ErrNotConnected
+	}
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if collector == nil {
+		return errors.New("collector cannot be nil")
+	}
+
+	if observer, ok := collector.(RowsObserver); ok {
+		if err := registerRowsObserver(db.DB, ctx, observer); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// select picks at random when a tick is due as ctx is done, so
+		// check ctx again before each export
+		for ctx.Err() == nil {
+			if stats, err := db.PoolStats(); err == nil {
+				collector.ObservePoolStats(stats)
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// rowsObserverName names the callbacks registered for a RowsObserver
+const rowsObserverName = "db:observe_rows"
+
+// registerRowsObserver registers, or replaces, the callbacks passing the rows
+// affected by each mutation to observer while ctx is not done
+func registerRowsObserver(db *gorm.DB, ctx context.Context, observer RowsObserver) error {
+	observe := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if ctx.Err() != nil || tx.Error != nil || tx.Statement.Table == "" {
+				return
+			}
+			observer.ObserveRows(operation, tx.Statement.Table, tx.RowsAffected)
+		}
+	}
+
+	cb := db.Callback()
+	if cb.Create().Get(rowsObserverName) != nil {
+		if err := cb.Create().Replace(rowsObserverName, observe("create")); err != nil {
+			return err
+		}
+		if err := cb.Update().Replace(rowsObserverName, observe("update")); err != nil {
+			return err
+		}
+		return cb.Delete().Replace(rowsObserverName, observe("delete"))
+	}
+
+	if err := cb.Create().After("gorm:create").Register(rowsObserverName, observe("create")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register(rowsObserverName, observe("update")); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register(rowsObserverName, observe("delete"))
+}
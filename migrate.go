@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrDestructiveMigration is returned by SafeMigrate when migrating would
+// narrow the type of a column holding data
+var ErrDestructiveMigration = errors.New("destructive migration")
+
+// MigrationChangeKind classifies a MigrationChange
+type MigrationChangeKind string
+
+const (
+	// MigrationCreateTable is a table of a model that does not exist yet
+	MigrationCreateTable MigrationChangeKind = "create table"
+	// MigrationAddColumn is a field without a column
+	MigrationAddColumn MigrationChangeKind = "add column"
+	// MigrationAlterColumn is a column whose type differs from its field's
+	MigrationAlterColumn MigrationChangeKind = "alter column"
+	// MigrationUnmappedColumn is a column without a field. AutoMigrate
+	// leaves such columns in place.
+	MigrationUnmappedColumn MigrationChangeKind = "unmapped column"
+)
+
+// MigrationChange is one difference between the models and the database
+type MigrationChange struct {
+	Table  string
+	Column string
+	Kind   MigrationChangeKind
+	// From and To are the current and the wanted column type of an
+	// MigrationAlterColumn change
+	From, To string
+	// Destructive is set when applying the change can lose or truncate
+	// data, i.e. when an existing column would get a narrower type
+	Destructive bool
+}
+
+// String implements fmt.Stringer
+func (c MigrationChange) String() string {
+	if c.Column == "" {
+		return fmt.Sprintf("%s %s", c.Kind, c.Table)
+	}
+	s := fmt.Sprintf("%s %s.%s", c.Kind, c.Table, c.Column)
+	if c.Kind == MigrationAlterColumn {
+		s += fmt.Sprintf(" from %s to %s", c.From, c.To)
+	}
+	return s
+}
+
+// CheckMigrations compares models with the database and returns what
+// AutoMigrate would change: missing tables and columns, and columns whose
+// type differs from their field's. Columns the models no longer map are
+// reported as well, although AutoMigrate never drops them.
+//
+// The type comparison follows GORM's own: a column keeps its type when the
+// type GORM generates for the field starts with the database's type name or
+// one of its aliases. A change is destructive when it shortens a string
+// column, lowers a decimal's precision or scale, moves an integer column to a
+// smaller integer type, or moves a column to another type family, such as
+// text to integer. Changes the heuristics cannot classify count as
+// destructive, to err on the side of keeping data. SQLite declares no string
+// lengths, so shortening a size tag is never a change there.
+func (db *DB) CheckMigrations(ctx context.Context, models ...interface{}) ([]MigrationChange, error) {
+	if db.DB == nil {
+		return nil, ErrNotConnected
+	}
+
+	tx := db.DB.WithContext(ctx)
+	migrator := tx.Migrator()
+
+	var changes []MigrationChange
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		s := stmt.Schema
+
+		if !migrator.HasTable(model) {
+			changes = append(changes, MigrationChange{Table: s.Table, Kind: MigrationCreateTable})
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		existing := make(map[string]gorm.ColumnType, len(columnTypes))
+		for _, ct := range columnTypes {
+			existing[ct.Name()] = ct
+			if s.LookUpField(ct.Name()) == nil {
+				changes = append(changes, MigrationChange{Table: s.Table, Column: ct.Name(), Kind: MigrationUnmappedColumn})
+			}
+		}
+
+		for _, name := range s.DBNames {
+			field := s.FieldsByDBName[name]
+			if field.IgnoreMigration {
+				continue
+			}
+			ct, ok := existing[name]
+			if !ok {
+				changes = append(changes, MigrationChange{Table: s.Table, Column: name, Kind: MigrationAddColumn})
+				continue
+			}
+			if change, ok := columnChange(migrator, s, field, ct); ok {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// SafeMigrate runs AutoMigrate for models unless CheckMigrations finds a
+// destructive change, in which case nothing is migrated and an error wrapping
+// ErrDestructiveMigration lists every such change. Set
+// Config.AllowDestructive to migrate anyway, e.g. for one
+// deployment that shortens a column after its data has been cleaned up.
+// AutoMigrate never drops columns, so neither does SafeMigrate.
+func (db *DB) SafeMigrate(ctx context.Context, models ...interface{}) error {
+	if db.DB == nil {
+		return ErrNotConnected
+	}
+
+	if db.config == nil || !db.config.AllowDestructive {
+		changes, err := db.CheckMigrations(ctx, models...)
+		if err != nil {
+			return err
+		}
+		var destructive []string
+		for _, change := range changes {
+			if change.Destructive {
+				destructive = append(destructive, change.String())
+			}
+		}
+		if len(destructive) > 0 {
+			return fmt.Errorf("%w: %s", ErrDestructiveMigration, strings.Join(destructive, "; "))
+		}
+	}
+
+	if err := db.DB.WithContext(ctx).AutoMigrate(models...); err != nil {
+		return err
+	}
+	return db.ResetStatementCache()
+}
+
+// columnChange compares a field with its existing column
+func columnChange(migrator gorm.Migrator, s *schema.Schema, field *schema.Field, ct gorm.ColumnType) (MigrationChange, bool) {
+	want := strings.ToLower(strings.TrimSpace(migrator.FullDataTypeOf(field).SQL))
+	have := strings.ToLower(ct.DatabaseTypeName())
+
+	change := MigrationChange{Table: s.Table, Column: field.DBName, Kind: MigrationAlterColumn, From: have, To: want}
+	if length, ok := ct.Length(); ok && length > 0 {
+		change.From = fmt.Sprintf("%s(%d)", have, length)
+	}
+
+	sameType := field.PrimaryKey || strings.HasPrefix(want, have)
+	for _, alias := range migrator.GetTypeAliases(have) {
+		sameType = sameType || strings.HasPrefix(want, alias)
+	}
+
+	haveFamily, wantFamily := typeFamily(have), typeFamily(baseType(want))
+	switch {
+	case !sameType && (haveFamily == "" || haveFamily != wantFamily):
+		change.Destructive = true
+		return change, true
+	case !sameType && haveFamily == "integer":
+		change.Destructive = integerRank(baseType(want)) < integerRank(have)
+		return change, true
+	}
+
+	if haveFamily == "string" && field.Size > 0 && strings.Contains(want, "(") {
+		// Unbounded columns such as text report no length
+		if length, ok := ct.Length(); !ok || length <= 0 || int64(field.Size) < length {
+			change.Destructive = true
+			return change, true
+		}
+	}
+	if haveFamily == "decimal" && field.Precision > 0 {
+		if precision, scale, ok := ct.DecimalSize(); ok && (int64(field.Precision) < precision || int64(field.Scale) < scale) {
+			change.From = fmt.Sprintf("%s(%d,%d)", have, precision, scale)
+			change.Destructive = true
+			return change, true
+		}
+	}
+	if !sameType {
+		return change, true
+	}
+	return MigrationChange{}, false
+}
+
+// baseType strips the length and column options from a generated data type,
+// so "varchar(100) NOT NULL" becomes "varchar"
+func baseType(dataType string) string {
+	dataType, _, _ = strings.Cut(dataType, "(")
+	words := strings.Fields(dataType)
+	for i, word := range words {
+		switch word {
+		case "primary", "not", "null", "default", "unique", "unsigned", "auto_increment", "autoincrement", "comment":
+			return strings.Join(words[:i], " ")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// typeFamily groups database type names whose values convert into each other
+// without loss when widened, or returns "" for types it does not know
+func typeFamily(typ string) string {
+	switch {
+	case strings.Contains(typ, "int") && !strings.Contains(typ, "interval") && !strings.Contains(typ, "point"):
+		return "integer"
+	case strings.Contains(typ, "char"), strings.Contains(typ, "text"), strings.Contains(typ, "clob"), typ == "string":
+		return "string"
+	case strings.Contains(typ, "decimal"), strings.Contains(typ, "numeric"):
+		return "decimal"
+	case strings.Contains(typ, "float"), strings.Contains(typ, "double"), strings.Contains(typ, "real"):
+		return "float"
+	case strings.Contains(typ, "time"), typ == "date":
+		return "time"
+	case strings.Contains(typ, "bool"):
+		return "bool"
+	case strings.Contains(typ, "blob"), strings.Contains(typ, "binary"), typ == "bytea":
+		return "binary"
+	}
+	return ""
+}
+
+// integerRank orders integer types by width
+func integerRank(typ string) int {
+	switch {
+	case strings.Contains(typ, "tiny"), typ == "int1":
+		return 1
+	case strings.Contains(typ, "small"), typ == "int2":
+		return 2
+	case strings.Contains(typ, "medium"), typ == "int3":
+		return 3
+	case strings.Contains(typ, "big"), typ == "int8":
+		return 5
+	}
+	return 4
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"gorm.io/gorm"
+)
+
+// metricsStartKey stores the start time of the statement
+const metricsStartKey = "db:metrics_start"
+
+// otelMetrics records the duration and errors of every statement and observes
+// the connection pool, as OpenTelemetry instruments:
+//
+//	db.client.operation.duration    histogram of statement durations in seconds
+//	db.client.errors                counter of failed statements
+//	db.client.connection.count      gauge of open connections by state, used or idle
+//	db.client.connection.wait_time  counter of seconds spent waiting for a connection
+//	db.client.connection.waits      counter of statements that had to wait for one
+//
+// The wait counters come from sql.DBStats and grow only while the pool is
+// exhausted, so a rising wait time next to flat statement durations points at
+// pool contention rather than slow queries.
+// Statements are attributed with db.system, db.operation and db.sql.table.
+type otelMetrics struct {
+	system   string
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+// registerOTelMetrics creates the instruments with provider and registers the
+// callbacks that record statements and the callback that observes sqlDB
+func registerOTelMetrics(db *gorm.DB, sqlDB *sql.DB, provider metric.MeterProvider) error {
+	meter := provider.Meter(tracerName)
+
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of database statements"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	errorCount, err := meter.Int64Counter("db.client.errors",
+		metric.WithDescription("Number of failed database statements"),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return err
+	}
+	connections, err := meter.Int64ObservableGauge("db.client.connection.count",
+		metric.WithDescription("Number of open connections by state"),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+
+	waitTime, err := meter.Float64ObservableCounter("db.client.connection.wait_time",
+		metric.WithDescription("Time spent waiting for an open connection"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	waits, err := meter.Int64ObservableCounter("db.client.connection.waits",
+		metric.WithDescription("Number of waits for an open connection"),
+		metric.WithUnit("{wait}"))
+	if err != nil {
+		return err
+	}
+
+	used := metric.WithAttributes(attribute.String("db.client.connection.state", "used"))
+	idle := metric.WithAttributes(attribute.String("db.client.connection.state", "idle"))
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		stats := sqlDB.Stats()
+		o.ObserveInt64(connections, int64(stats.InUse), used)
+		o.ObserveInt64(connections, int64(stats.Idle), idle)
+		o.ObserveFloat64(waitTime, stats.WaitDuration.Seconds())
+		o.ObserveInt64(waits, stats.WaitCount)
+		return nil
+	}, connections, waitTime, waits)
+	if err != nil {
+		return err
+	}
+
+	m := &otelMetrics{system: dialect.Of(db), duration: duration, errors: errorCount}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("db:metrics_end", m.ender("INSERT")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("db:metrics_end", m.ender("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("db:metrics_end", m.ender("UPDATE")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("db:metrics_end", m.ender("DELETE")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("db:metrics_end", m.ender("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("db:metrics_start", m.start); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("db:metrics_end", m.ender(""))
+}
+
+// start notes when the statement began
+func (m *otelMetrics) start(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+// ender returns the callback that records a finished statement. An empty
+// operation is taken from the SQL, as for tracing.
+func (m *otelMetrics) ender(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		m.end(db, operation)
+	}
+}
+
+// end records the duration and the outcome of the statement
+func (m *otelMetrics) end(db *gorm.DB, operation string) {
+	value, ok := db.InstanceGet(metricsStartKey)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(value.(time.Time))
+
+	stmt := db.Statement
+	attrs := metric.WithAttributes(
+		attribute.String("db.system", m.system),
+		attribute.String("db.operation", statementOperation(stmt, operation)),
+		attribute.String("db.sql.table", stmt.Table),
+	)
+	m.duration.Record(stmt.Context, elapsed.Seconds(), attrs)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		m.errors.Add(stmt.Context, 1, attrs)
+	}
+}
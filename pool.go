@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PoolStats is a snapshot of the connection pool statistics
+type PoolStats struct {
+	MaxOpenConnections int
+	OpenConnections    int
+	InUse              int
+	Idle               int
+	WaitCount          int64
+	WaitDuration       time.Duration
+	MaxIdleClosed      int64
+	MaxIdleTimeClosed  int64
+	MaxLifetimeClosed  int64
+}
+
+// PoolStats returns a typed snapshot of the connection pool statistics
+func (db *DB) PoolStats() (PoolStats, error) {
+	sqlDB, err := db.SQLDB()
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	stats := sqlDB.Stats()
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}, nil
+}
+
+// StatsDelta returns the change in pool statistics since prev. Every field
+// except MaxOpenConnections holds the current value minus the value in prev,
+// so a positive InUse that keeps growing across calls points to a leak.
+func (db *DB) StatsDelta(prev PoolStats) (PoolStats, error) {
+	cur, err := db.PoolStats()
+	if err != nil {
+		return PoolStats{}, err
+	}
+
+	return PoolStats{
+		MaxOpenConnections: cur.MaxOpenConnections,
+		OpenConnections:    cur.OpenConnections - prev.OpenConnections,
+		InUse:              cur.InUse - prev.InUse,
+		Idle:               cur.Idle - prev.Idle,
+		WaitCount:          cur.WaitCount - prev.WaitCount,
+		WaitDuration:       cur.WaitDuration - prev.WaitDuration,
+		MaxIdleClosed:      cur.MaxIdleClosed - prev.MaxIdleClosed,
+		MaxIdleTimeClosed:  cur.MaxIdleTimeClosed - prev.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  cur.MaxLifetimeClosed - prev.MaxLifetimeClosed,
+	}, nil
+}
+
+// OpenConnsTrend samples the number of open connections samples times, waiting
+// interval between samples. It is meant for diagnosing connection leaks and
+// blocks until all samples are taken or ctx is done, in which case the samples
+// collected so far are returned along with the context error.
+func (db *DB) OpenConnsTrend(ctx context.Context, samples int, interval time.Duration) ([]int, error) {
+	if samples <= 0 {
+		return nil, errors.New("samples must be positive")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+
+	trend := make([]int, 0, samples)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := db.PoolStats()
+		if err != nil {
+			return trend, err
+		}
+		trend = append(trend, stats.OpenConnections)
+		if len(trend) == samples {
+			return trend, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return trend, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
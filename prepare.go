@@ -0,0 +1,39 @@
+package db
+
+import (
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+)
+
+// ResetStatementCache drops the prepared statements cached by
+// Config.PrepareStmt and by sessions opened with gorm.Session{PrepareStmt:
+// true}, closing them on every connection, so that the next statements are
+// prepared again against the current schema. Without it postgres fails
+// cached statements whose result columns changed in a migration with
+// "cached plan must not change result type".
+//
+// On postgres the idle connections are closed as well, dropping the
+// statements the driver caches per connection. Connections in use keep
+// theirs until they are closed. AutoMigrate and SafeMigrate call it after
+// migrating; call it after running migrations through other tools.
+func (db *DB) ResetStatementCache() error {
+	sqlDB, err := db.SQLDB()
+	if err != nil {
+		return err
+	}
+
+	// A prepared session shares the statement store of the DB
+	if prepared, ok := db.DB.Session(&gorm.Session{PrepareStmt: true}).Statement.ConnPool.(*gorm.PreparedStmtDB); ok {
+		prepared.Close()
+	}
+
+	if dialect.Of(db.DB) == dialect.Postgres {
+		maxIdle := 10
+		if db.config != nil {
+			maxIdle = db.config.MaxIdleConns
+		}
+		sqlDB.SetMaxIdleConns(0)
+		sqlDB.SetMaxIdleConns(maxIdle)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+)
+
+// ReplicaLagConfig configures a ReplicaLagChecker
+type ReplicaLagConfig struct {
+	// Threshold is the replication lag above which a replica counts as too
+	// stale to read from. It must be positive.
+	Threshold time.Duration
+	// Interval is how often Start measures the lag. It must be positive.
+	Interval time.Duration
+}
+
+// ReplicaLagChecker is a plugin that sends reads to the primary while every
+// postgres read replica of a dbresolver split lags behind it by more than the
+// threshold, so users are not shown very stale data during replication
+// hiccups. Register it after dbresolver and start it once the DB is open:
+//
+//	checker, err := db.NewReplicaLagChecker(db.ReplicaLagConfig{Threshold: 5 * time.Second, Interval: time.Second})
+//	config.Plugins = []gorm.Plugin{dbresolver.Register(resolverConfig), checker}
+//	database, err := db.New(config, postgres.Open(dsn))
+//	err = checker.Start(ctx)
+//
+// A replica is measured once it has served a read, so the first reads after
+// start go to the replicas whatever their lag. Reads explicitly sent to a
+// replica with dbresolver.Read are rerouted too. Lag is measured as the age of
+// the last transaction replayed while the replica has WAL left to replay, so
+// an idle but caught-up replica has no lag.
+type ReplicaLagChecker struct {
+	config ReplicaLagConfig
+	// measure returns the replication lag of a replica
+	measure func(ctx context.Context, pool gorm.ConnPool) (time.Duration, error)
+
+	mu       sync.Mutex
+	db       *gorm.DB
+	primary  gorm.ConnPool
+	replicas map[gorm.ConnPool]time.Duration
+}
+
+// replicationLagQuery returns the replication lag of a postgres replica in
+// seconds, zero on a primary or on a replica that has replayed all WAL it
+// received
+const replicationLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// NewReplicaLagChecker validates config and returns a checker to register as
+// a plugin
+func NewReplicaLagChecker(config ReplicaLagConfig) (*ReplicaLagChecker, error) {
+	if config.Threshold <= 0 {
+		return nil, fmt.Errorf("replica lag threshold must be positive, got %s", config.Threshold)
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("replica lag interval must be positive, got %s", config.Interval)
+	}
+	return &ReplicaLagChecker{
+		config:   config,
+		measure:  postgresReplicationLag,
+		replicas: make(map[gorm.ConnPool]time.Duration),
+	}, nil
+}
+
+// Name implements gorm.Plugin
+func (c *ReplicaLagChecker) Name() string {
+	return "db:replica_lag"
+}
+
+// Initialize implements gorm.Plugin. It requires postgres.
+func (c *ReplicaLagChecker) Initialize(db *gorm.DB) error {
+	if dialect.Of(db) != dialect.Postgres {
+		return errors.New("replica lag checking requires postgres")
+	}
+	return c.register(db)
+}
+
+// register adds the callbacks that reroute reads, after those of dbresolver
+func (c *ReplicaLagChecker) register(db *gorm.DB) error {
+	c.mu.Lock()
+	c.db, c.primary = db, db.ConnPool
+	c.mu.Unlock()
+
+	cb := db.Callback()
+	if err := cb.Query().After("gorm:db_resolver").Before("gorm:query").Register(c.Name(), c.route); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:db_resolver").Before("gorm:row").Register(c.Name(), c.route); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:db_resolver").Before("gorm:raw").Register(c.Name(), c.route)
+}
+
+// Start measures the lag of the replicas right away and then every interval,
+// from a background goroutine that stops when ctx is done. Failed
+// measurements leave the previous value in place.
+func (c *ReplicaLagChecker) Start(ctx context.Context) error {
+	c.mu.Lock()
+	initialized := c.db != nil
+	c.mu.Unlock()
+	if !initialized {
+		return errors.New("replica lag checker is not registered as a plugin")
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			c.check(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Lag returns the smallest replication lag last measured among the replicas,
+// which is how far behind the freshest replica reads can be. It is zero until
+// a replica has been measured.
+func (c *ReplicaLagChecker) Lag() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lag, _ := c.minLag()
+	return lag
+}
+
+// check measures the lag of every replica seen so far
+func (c *ReplicaLagChecker) check(ctx context.Context) {
+	c.mu.Lock()
+	pools := make([]gorm.ConnPool, 0, len(c.replicas))
+	for pool := range c.replicas {
+		pools = append(pools, pool)
+	}
+	c.mu.Unlock()
+
+	for _, pool := range pools {
+		lag, err := c.measure(ctx, pool)
+		if err != nil {
+			continue
+		}
+		c.mu.Lock()
+		c.replicas[pool] = lag
+		c.mu.Unlock()
+	}
+}
+
+// minLag returns the smallest measured lag and whether a replica is known.
+// The caller holds mu.
+func (c *ReplicaLagChecker) minLag() (time.Duration, bool) {
+	first := true
+	var lag time.Duration
+	for _, replicaLag := range c.replicas {
+		if first || replicaLag < lag {
+			lag, first = replicaLag, false
+		}
+	}
+	return lag, !first
+}
+
+// route records the replica dbresolver picked for a read and sends the read
+// to the primary instead while every replica lags past the threshold
+func (c *ReplicaLagChecker) route(db *gorm.DB) {
+	pool := db.Statement.ConnPool
+	if prepared, ok := pool.(*gorm.PreparedStmtDB); ok {
+		pool = prepared.ConnPool
+	}
+	if _, ok := pool.(gorm.TxCommitter); ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool == c.primary {
+		return
+	}
+	if _, ok := c.replicas[pool]; !ok {
+		c.replicas[pool] = 0
+	}
+	if lag, ok := c.minLag(); ok && lag > c.config.Threshold {
+		db.Statement.ConnPool = c.primary
+	}
+}
+
+// postgresReplicationLag measures the replication lag of a postgres replica
+func postgresReplicationLag(ctx context.Context, pool gorm.ConnPool) (time.Duration, error) {
+	rows, err := pool.QueryContext(ctx, replicationLagQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var seconds float64
+	if rows.Next() {
+		if err := rows.Scan(&seconds); err != nil {
+			return 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
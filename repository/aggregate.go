@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// groupCountRow is one group of a GroupCount query
+type groupCountRow struct {
+	GroupKey   sql.NullString
+	GroupCount int64
+}
+
+// GroupCount counts the records per distinct value of column, keyed by the
+// value as a string. NULL values are counted under the empty string.
+// Soft-deleted rows are excluded unless called on WithDeleted().
+func (r *Repository[T]) GroupCount(ctx context.Context, column string) (map[string]int64, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.groupCount(column, r.conn(ctx))
+}
+
+// GroupCountWhere is GroupCount restricted to the records matching the
+// condition. An empty condition returns ErrMissingCondition; use GroupCount
+// to count every record.
+func (r *Repository[T]) GroupCountWhere(ctx context.Context, column string, query interface{}, args ...interface{}) (map[string]int64, error) {
+	ctx = r.checkDeadline(ctx)
+	if isEmptyCondition(query) {
+		return nil, ErrMissingCondition
+	}
+	return r.groupCount(column, r.conn(ctx).Where(query, args...))
+}
+
+// groupCount runs the grouped count on tx
+func (r *Repository[T]) groupCount(column string, tx *gorm.DB) (map[string]int64, error) {
+	col, err := r.column(column)
+	if err != nil {
+		return nil, err
+	}
+	key := clause.Column{Table: clause.CurrentTable, Name: col}
+
+	var rows []groupCountRow
+	err = tx.Model(new(T)).
+		Select("? AS group_key, COUNT(*) AS group_count", key).
+		Clauses(clause.GroupBy{Columns: []clause.Column{key}}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.GroupKey.String] += row.GroupCount
+	}
+	return counts, nil
+}
+
+// countCache memoizes the result of Count for CachedCount
+type countCache struct {
+	mu      sync.Mutex
+	value   int64
+	expires time.Time
+}
+
+// CachedCount returns Count, memoized for ttl. Once ttl has passed the next
+// call counts again; concurrent callers wait for that one query instead of
+// each issuing their own. Create, Delete, DeleteByID, SoftDeleteByIDs and
+// CreateManyResults on the same repository value reset the cache, but writes
+// through other repositories or processes are only seen once ttl expires, so
+// the count can be up to ttl stale. Repositories derived with scopes, such as
+// WithDeleted(), keep a cache of their own. It is safe for concurrent use.
+func (r *Repository[T]) CachedCount(ctx context.Context, ttl time.Duration) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	c := r.countCache
+	if c == nil || r.err != nil {
+		return r.Count(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expires) {
+		return c.value, nil
+	}
+
+	count, err := r.Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.value, c.expires = count, time.Now().Add(ttl)
+	return count, nil
+}
+
+// invalidateCount drops the value memoized by CachedCount
+func (r *Repository[T]) invalidateCount() {
+	if c := r.countCache; c != nil {
+		c.mu.Lock()
+		c.expires = time.Time{}
+		c.mu.Unlock()
+	}
+}
+
+// ScanScalar runs SELECT selectExpr over the records of repo matching the
+// condition and scans the single value into R, for projections such as
+//
+//	latest, err := repository.ScanScalar[int64](ctx, repo, "MAX(version)", "tenant_id = ?", tenant)
+//
+// An empty condition matches every record. When no row comes back or the
+// value is NULL, as for MAX over no rows, the zero value of R is returned
+// with a nil error. selectExpr is inserted into the query as is, so it must
+// come from trusted code.
+//
+// R receives whatever the driver returns for the expression. SQLite gives an
+// aggregate over a datetime column no declared type and returns it as text,
+// so scan such values into string there rather than time.Time.
+func ScanScalar[R any, T any](ctx context.Context, repo *Repository[T], selectExpr string, query interface{}, args ...interface{}) (R, error) {
+	ctx = repo.checkDeadline(ctx)
+	var zero R
+
+	tx := repo.conn(ctx).Model(new(T)).Select(selectExpr)
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+	rows, err := tx.Rows()
+	if err != nil {
+		return zero, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return zero, rows.Err()
+	}
+	var value *R
+	if err := rows.Scan(&value); err != nil {
+		return zero, err
+	}
+	if value == nil {
+		return zero, rows.Err()
+	}
+	return *value, rows.Err()
+}
+
+// Reduce folds every record of repo into an accumulator, starting from init,
+// for aggregates SQL cannot express such as percentiles or custom rollups:
+//
+//	hist, err := repository.Reduce(ctx, repo, 1000, map[int]int{}, func(h map[int]int, o Order) map[int]int {
+//		h[o.Items]++
+//		return h
+//	})
+//
+// Records are streamed in primary key order batchSize at a time, as by
+// FindEach, so memory stays bounded by one batch plus the accumulator. A
+// cancelled ctx stops the fold and returns its error along with the
+// accumulator so far.
+func Reduce[R any, T any](ctx context.Context, repo *Repository[T], batchSize int, init R, fn func(acc R, row T) R) (R, error) {
+	ctx = repo.checkDeadline(ctx)
+	acc := init
+	err := repo.FindEach(ctx, batchSize, func(row T) error {
+		acc = fn(acc, row)
+		return nil
+	})
+	return acc, err
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestGroupCount(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	repo := New[TestSoftUser](db)
+	ctx := context.Background()
+
+	users := []TestSoftUser{
+		{Name: "active", Email: "alice@example.com"},
+		{Name: "active", Email: "bob@example.com"},
+		{Name: "pending", Email: "carol@example.com"},
+		{Name: "pending", Email: "dave@example.com"},
+	}
+	for i := range users {
+		repo.Create(ctx, &users[i])
+	}
+	repo.Delete(ctx, &users[3])
+
+	t.Run("counts per value", func(t *testing.T) {
+		counts, err := repo.GroupCount(ctx, "name")
+		if err != nil {
+			t.Fatalf("GroupCount failed: %v", err)
+		}
+		if counts["active"] != 2 || counts["pending"] != 1 {
+			t.Errorf("Expected active:2 and pending:1, got %v", counts)
+		}
+	})
+
+	t.Run("includes deleted rows with WithDeleted", func(t *testing.T) {
+		counts, err := repo.WithDeleted().GroupCount(ctx, "Name")
+		if err != nil {
+			t.Fatalf("GroupCount failed: %v", err)
+		}
+		if counts["pending"] != 2 {
+			t.Errorf("Expected pending:2, got %v", counts)
+		}
+	})
+
+	t.Run("filters with a condition", func(t *testing.T) {
+		counts, err := repo.GroupCountWhere(ctx, "name", "email <> ?", "alice@example.com")
+		if err != nil {
+			t.Fatalf("GroupCountWhere failed: %v", err)
+		}
+		if counts["active"] != 1 || counts["pending"] != 1 {
+			t.Errorf("Expected active:1 and pending:1, got %v", counts)
+		}
+	})
+
+	t.Run("validates column and condition", func(t *testing.T) {
+		if _, err := repo.GroupCount(ctx, "missing"); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.GroupCountWhere(ctx, "name", ""); !errors.Is(err, ErrMissingCondition) {
+			t.Errorf("Expected ErrMissingCondition, got %v", err)
+		}
+	})
+}
+
+func TestCachedCount(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 2)
+
+	count, err := repo.CachedCount(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("CachedCount failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2, got %d", count)
+	}
+
+	t.Run("serves writes from elsewhere stale", func(t *testing.T) {
+		db.Create(&TestUser{Name: "Direct", Email: "direct@example.com"})
+		count, _ := repo.CachedCount(ctx, time.Minute)
+		if count != 2 {
+			t.Errorf("Expected cached 2, got %d", count)
+		}
+	})
+
+	t.Run("invalidates on create and delete", func(t *testing.T) {
+		user := &TestUser{Name: "Repo", Email: "repo@example.com"}
+		repo.Create(ctx, user)
+		count, _ := repo.CachedCount(ctx, time.Minute)
+		if count != 4 {
+			t.Errorf("Expected 4 after create, got %d", count)
+		}
+
+		repo.Delete(ctx, user)
+		count, _ = repo.CachedCount(ctx, time.Minute)
+		if count != 3 {
+			t.Errorf("Expected 3 after delete, got %d", count)
+		}
+	})
+
+	t.Run("invalidates on upserts", func(t *testing.T) {
+		repo.Upsert(ctx, &TestUser{Name: "Upserted", Email: "upserted@example.com"}, []string{"email"}, nil)
+		count, _ := repo.CachedCount(ctx, time.Minute)
+		if count != 4 {
+			t.Errorf("Expected 4 after Upsert, got %d", count)
+		}
+
+		repo.UpsertExpr(ctx, &TestUser{Name: "Expr", Email: "expr@example.com"}, []string{"email"},
+			map[string]clause.Expression{"age": Increment("age", 1)})
+		count, _ = repo.CachedCount(ctx, time.Minute)
+		if count != 5 {
+			t.Errorf("Expected 5 after UpsertExpr, got %d", count)
+		}
+
+		repo.UpsertIfNewer(ctx, &TestUser{Name: "Newer", Email: "newer@example.com", Age: 1}, []string{"email"}, "age", nil)
+		count, _ = repo.CachedCount(ctx, time.Minute)
+		if count != 6 {
+			t.Errorf("Expected 6 after UpsertIfNewer, got %d", count)
+		}
+	})
+
+	t.Run("refreshes after ttl", func(t *testing.T) {
+		fresh := New[TestUser](db)
+		before, _ := fresh.CachedCount(ctx, time.Millisecond)
+		db.Create(&TestUser{Name: "Later", Email: "later@example.com"})
+		time.Sleep(5 * time.Millisecond)
+
+		count, _ := fresh.CachedCount(ctx, time.Millisecond)
+		if count != before+1 {
+			t.Errorf("Expected %d after ttl, got %d", before+1, count)
+		}
+	})
+
+	t.Run("keeps a separate cache per scope", func(t *testing.T) {
+		scoped := repo.withScope(func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("name = ?", "Direct")
+		})
+		count, err := scoped.CachedCount(ctx, time.Minute)
+		if err != nil {
+			t.Fatalf("CachedCount failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 for the scoped repository, got %d", count)
+		}
+	})
+
+	t.Run("returns repository errors", func(t *testing.T) {
+		if _, err := repo.OnlyDeleted().CachedCount(ctx, time.Minute); !errors.Is(err, ErrSoftDeleteUnsupported) {
+			t.Errorf("Expected ErrSoftDeleteUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestScanScalar(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	for i, name := range []string{"Ann", "Bob", "Cid"} {
+		repo.Create(ctx, &TestUser{Name: name, Email: name + "@example.com", Age: 30 + i})
+	}
+
+	t.Run("scans an aggregate", func(t *testing.T) {
+		oldest, err := ScanScalar[int](ctx, repo, "MAX(age)", nil)
+		if err != nil {
+			t.Fatalf("ScanScalar failed: %v", err)
+		}
+		if oldest != 32 {
+			t.Errorf("Expected 32, got %d", oldest)
+		}
+	})
+
+	t.Run("applies the condition", func(t *testing.T) {
+		name, err := ScanScalar[string](ctx, repo, "MIN(name)", "age > ?", 30)
+		if err != nil {
+			t.Fatalf("ScanScalar failed: %v", err)
+		}
+		if name != "Bob" {
+			t.Errorf("Expected Bob, got %s", name)
+		}
+	})
+
+	t.Run("returns zero for NULL", func(t *testing.T) {
+		oldest, err := ScanScalar[int](ctx, repo, "MAX(age)", "age > ?", 100)
+		if err != nil || oldest != 0 {
+			t.Errorf("Expected 0 and no error, got %d and %v", oldest, err)
+		}
+	})
+
+	t.Run("returns zero for no rows", func(t *testing.T) {
+		name, err := ScanScalar[string](ctx, repo, "name", "age > ?", 100)
+		if err != nil || name != "" {
+			t.Errorf("Expected empty name and no error, got %q and %v", name, err)
+		}
+	})
+
+	t.Run("reports scan errors", func(t *testing.T) {
+		if _, err := ScanScalar[int](ctx, repo, "name", "age = ?", 30); err == nil {
+			t.Error("Expected error scanning a name into int")
+		}
+	})
+}
+
+func TestReduce(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	for i, age := range []int{20, 35, 35, 50, 65} {
+		repo.Create(ctx, &TestUser{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i), Age: age})
+	}
+
+	t.Run("folds every row across batches", func(t *testing.T) {
+		byDecade, err := Reduce(ctx, repo, 2, map[int]int{}, func(acc map[int]int, u TestUser) map[int]int {
+			acc[u.Age/10*10]++
+			return acc
+		})
+		if err != nil {
+			t.Fatalf("Reduce failed: %v", err)
+		}
+		want := map[int]int{20: 1, 30: 2, 50: 1, 60: 1}
+		if !reflect.DeepEqual(byDecade, want) {
+			t.Errorf("Expected %v, got %v", want, byDecade)
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		seen, err := Reduce(cancelled, repo, 2, 0, func(acc int, u TestUser) int {
+			cancel()
+			return acc + 1
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if seen != 1 {
+			t.Errorf("Expected the fold to stop after 1 row, got %d", seen)
+		}
+	})
+}
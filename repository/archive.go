@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// EnableDeleteArchive creates the <table>_archive table used by
+// WithDeleteArchive, or adds the columns it is missing when T has gained
+// fields since it was created. The archive table has the columns of T but
+// none of its keys or indexes, so a value can be archived more than once.
+func (r *Repository[T]) EnableDeleteArchive(ctx context.Context) error {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	archive := archiveTable(s)
+
+	tx := r.db.WithContext(ctx)
+	if !tx.Migrator().HasTable(archive) {
+		return createShadowTable(tx, archive, s.Table)
+	}
+
+	migrator := tx.Table(archive).Migrator()
+	for _, name := range s.DBNames {
+		if !migrator.HasColumn(new(T), name) {
+			if err := migrator.AddColumn(new(T), name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archiveDelete deletes like gorm's Delete(entity, conds...) after copying
+// the rows that are about to be removed into the archive table. Both steps run
+// in one transaction, so a row is never deleted without being archived. The
+// copy costs an extra SELECT and INSERT per delete. Soft deletes keep the row
+// in place and skip the archive. It returns the number of rows deleted.
+func (r *Repository[T]) archiveDelete(ctx context.Context, entity *T, conds ...interface{}) (int64, error) {
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+	if softDeleteField(s) != nil && !r.unscoped {
+		result := r.conn(ctx).Delete(entity, conds...)
+		return result.RowsAffected, result.Error
+	}
+
+	archive := archiveTable(s)
+
+	var deleted int64
+	err = r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		find := tx.Unscoped()
+		if len(conds) == 0 {
+			pk, err := primaryKey(s)
+			if err != nil {
+				return err
+			}
+			value, zero := pk.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+			if zero {
+				return gorm.ErrMissingWhereClause
+			}
+			conds = []interface{}{value}
+		}
+
+		var rows []T
+		if err := find.Find(&rows, conds...).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		err := sideTable(tx, archive).
+			Session(&gorm.Session{SkipHooks: true}).
+			Omit(clause.Associations).
+			Create(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Delete(entity, conds...)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// createShadowTable creates table name with the columns of source but none of
+// its keys, indexes or constraints
+func createShadowTable(tx *gorm.DB, name, source string) error {
+	if dialect.Of(tx) != dialect.SQLite {
+		return tx.Exec("CREATE TABLE ? AS SELECT * FROM ? WHERE 1 = 0",
+			clause.Table{Name: name}, clause.Table{Name: source}).Error
+	}
+
+	// SQLite types CREATE TABLE AS columns by affinity, turning datetime into
+	// NUM so times no longer scan back, so copy the declared types instead
+	columnTypes, err := tx.Migrator().ColumnTypes(source)
+	if err != nil {
+		return err
+	}
+	defs := make([]clause.Expression, len(columnTypes))
+	for i, ct := range columnTypes {
+		defs[i] = clause.Expr{SQL: "? " + ct.DatabaseTypeName(), Vars: []interface{}{clause.Column{Name: ct.Name()}}}
+	}
+	return tx.Exec("CREATE TABLE ? (?)", clause.Table{Name: name}, clause.CommaExpression{Exprs: defs}).Error
+}
+
+// archiveTable returns the name of the delete archive table for a schema
+func archiveTable(s *schema.Schema) string {
+	return s.Table + "_archive"
+}
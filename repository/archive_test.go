@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeleteArchive(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	ctx := context.Background()
+
+	users := New[TestUser](db, WithDeleteArchive())
+	if err := users.EnableDeleteArchive(ctx); err != nil {
+		t.Fatalf("EnableDeleteArchive failed: %v", err)
+	}
+
+	t.Run("creates the archive table", func(t *testing.T) {
+		if !db.Migrator().HasTable("test_users_archive") {
+			t.Error("Expected test_users_archive table to exist")
+		}
+		if err := users.EnableDeleteArchive(ctx); err != nil {
+			t.Errorf("Expected EnableDeleteArchive to be idempotent, got %v", err)
+		}
+	})
+
+	t.Run("archives rows on delete", func(t *testing.T) {
+		user := &TestUser{Name: "Archived", Email: "archived@example.com", Age: 40}
+		users.Create(ctx, user)
+
+		if err := users.Delete(ctx, user); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+
+		var archived []TestUser
+		db.Table("test_users_archive").Where("id = ?", user.ID).Find(&archived)
+		if len(archived) != 1 || archived[0].Email != "archived@example.com" {
+			t.Errorf("Expected deleted user in archive, got %+v", archived)
+		}
+	})
+
+	t.Run("archives rows on delete by ID", func(t *testing.T) {
+		user := &TestUser{Name: "Archived By ID", Email: "archived-id@example.com", Age: 41}
+		users.Create(ctx, user)
+
+		if err := users.DeleteByID(ctx, user.ID); err != nil {
+			t.Fatalf("DeleteByID failed: %v", err)
+		}
+
+		var count int64
+		db.Table("test_users_archive").Where("id = ?", user.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("Expected 1 archived row, got %d", count)
+		}
+
+		var found TestUser
+		if err := users.FindByID(ctx, user.ID, &found); err == nil {
+			t.Error("Expected user to be deleted from the main table")
+		}
+	})
+
+	t.Run("soft deletes skip the archive", func(t *testing.T) {
+		soft := New[TestSoftUser](db, WithDeleteArchive())
+		if err := soft.EnableDeleteArchive(ctx); err != nil {
+			t.Fatalf("EnableDeleteArchive failed: %v", err)
+		}
+
+		user := &TestSoftUser{Name: "Soft", Email: "soft@example.com"}
+		soft.Create(ctx, user)
+		soft.Delete(ctx, user)
+
+		var count int64
+		db.Table("test_soft_users_archive").Count(&count)
+		if count != 0 {
+			t.Errorf("Expected soft delete not to archive, got %d rows", count)
+		}
+
+		soft.WithDeleted().Delete(ctx, user)
+		db.Table("test_soft_users_archive").Count(&count)
+		if count != 1 {
+			t.Errorf("Expected hard delete to archive, got %d rows", count)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// RowResult is the outcome of one row of a best-effort batch operation
+type RowResult struct {
+	// Index is the position of the row in the input slice
+	Index int
+	// Err is the error the row failed with, or nil if it succeeded
+	Err error
+}
+
+// CreateManyResults inserts entities one at a time and reports the outcome of
+// each, so a best-effort import can skip and report bad rows instead of
+// aborting. Successful rows get their generated IDs set in entities.
+//
+// Rows are not atomic in this mode: each insert commits on its own and earlier
+// rows stay in place when later ones fail. Running it inside a transaction
+// does not help on postgres, where the first failed statement aborts the
+// whole transaction. The returned error is only set when ctx is cancelled, in
+// which case the results cover the rows attempted so far.
+func (r *Repository[T]) CreateManyResults(ctx context.Context, entities []T) ([]RowResult, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	results := make([]RowResult, 0, len(entities))
+	for i := range entities {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		results = append(results, RowResult{
+			Index: i,
+			Err:   r.conn(ctx).Create(&entities[i]).Error,
+		})
+	}
+	return results, nil
+}
+
+// CreateBatch inserts entities with one INSERT per batchSize rows, setting
+// the generated IDs in entities where the driver returns them. A batchSize of
+// zero or less uses the repository's WithDefaultBatchSize, or 100.
+//
+// Each batch commits on its own: the first failing batch stops the insert and
+// its error is returned, while the batches before it stay in place. Run it
+// inside Transaction to insert all or nothing.
+func (r *Repository[T]) CreateBatch(ctx context.Context, entities []T, batchSize int) error {
+	ctx = r.checkDeadline(ctx)
+	if len(entities) == 0 {
+		return r.err
+	}
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	return r.conn(ctx).Session(&gorm.Session{SkipDefaultTransaction: true}).
+		CreateInBatches(entities, r.batchSize(batchSize)).Error
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCreateManyResults(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	repo.Create(ctx, &TestUser{Name: "Existing", Email: "taken@example.com", Age: 40})
+
+	users := []TestUser{
+		{Name: "New 1", Email: "new1@example.com", Age: 20},
+		{Name: "Duplicate", Email: "taken@example.com", Age: 21},
+		{Name: "New 2", Email: "new2@example.com", Age: 22},
+	}
+
+	results, err := repo.CreateManyResults(ctx, users)
+	if err != nil {
+		t.Fatalf("CreateManyResults failed: %v", err)
+	}
+
+	t.Run("reports a result per row", func(t *testing.T) {
+		if len(results) != 3 {
+			t.Fatalf("Expected 3 results, got %d", len(results))
+		}
+		for i, result := range results {
+			if result.Index != i {
+				t.Errorf("Expected index %d, got %d", i, result.Index)
+			}
+		}
+		if results[0].Err != nil || results[2].Err != nil {
+			t.Errorf("Expected rows 0 and 2 to succeed, got %v and %v", results[0].Err, results[2].Err)
+		}
+		if results[1].Err == nil {
+			t.Error("Expected duplicate row to fail")
+		}
+	})
+
+	t.Run("keeps successful rows and their IDs", func(t *testing.T) {
+		if users[0].ID == 0 || users[2].ID == 0 {
+			t.Error("Expected IDs to be set on inserted rows")
+		}
+
+		count, _ := repo.Count(ctx)
+		if count != 3 {
+			t.Errorf("Expected 3 users in total, got %d", count)
+		}
+	})
+}
+
+func TestCreateBatch(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	t.Run("inserts all rows and sets their IDs", func(t *testing.T) {
+		users := make([]TestUser, 5)
+		for i := range users {
+			users[i] = TestUser{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i), Age: 20 + i}
+		}
+
+		if err := repo.CreateBatch(ctx, users, 2); err != nil {
+			t.Fatalf("CreateBatch failed: %v", err)
+		}
+		for i, u := range users {
+			if u.ID == 0 {
+				t.Errorf("Expected ID to be set on user %d", i)
+			}
+		}
+		count, _ := repo.Count(ctx)
+		if count != 5 {
+			t.Errorf("Expected 5 users, got %d", count)
+		}
+	})
+
+	t.Run("keeps the batches before a failing one", func(t *testing.T) {
+		users := []TestUser{
+			{Name: "Batch 1a", Email: "batch1a@example.com"},
+			{Name: "Batch 1b", Email: "batch1b@example.com"},
+			{Name: "Batch 2a", Email: "batch2a@example.com"},
+			{Name: "Batch 2b", Email: "user0@example.com"},
+			{Name: "Batch 3", Email: "batch3@example.com"},
+		}
+
+		if err := repo.CreateBatch(ctx, users, 2); err == nil {
+			t.Fatal("Expected duplicate email to fail")
+		}
+		for _, email := range []string{"batch1a@example.com", "batch1b@example.com"} {
+			if exists, _ := repo.ExistsActive(ctx, "email = ?", email); !exists {
+				t.Errorf("Expected %s from the committed batch to be kept", email)
+			}
+		}
+		for _, email := range []string{"batch2a@example.com", "batch3@example.com"} {
+			if exists, _ := repo.ExistsActive(ctx, "email = ?", email); exists {
+				t.Errorf("Expected %s not to be inserted", email)
+			}
+		}
+	})
+
+	t.Run("accepts an empty slice", func(t *testing.T) {
+		if err := repo.CreateBatch(ctx, nil, 0); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if err := repo.WithPartition("missing").CreateBatch(ctx, nil, 0); !errors.Is(err, ErrUnknownPartition) {
+			t.Errorf("Expected ErrUnknownPartition, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChangeHandler receives the changes of an Update tracked with
+// WithChangeTracking. tx is the transaction of the update, table and id
+// identify the row and changes lists the columns whose values differ.
+type ChangeHandler func(tx *gorm.DB, table string, id interface{}, changes []ChangeSet) error
+
+// trackedUpdate saves entity like Update and passes the columns it changed to
+// the change handler, in one transaction. The prior row is locked while it is
+// compared, so the old values are the ones the update replaced.
+func (r *Repository[T]) trackedUpdate(ctx context.Context, entity *T) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+
+	bound := *r
+	bound.opts.onChange = nil
+	id, zero := pk.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		// Save inserts rows without a primary key, so nothing changes
+		return bound.Update(ctx, entity)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		bound.db = tx
+
+		var prior T
+		err := bound.conn(ctx).Unscoped().
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+			Take(&prior, pkCondition(pk, id)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return bound.Update(ctx, entity)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := bound.Update(ctx, entity); err != nil {
+			return err
+		}
+		changes := diffFields(ctx, s, &prior, entity)
+		if len(changes) == 0 {
+			return nil
+		}
+		return r.opts.onChange(tx, s.Table, id, changes)
+	})
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestWithChangeTracking(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	ctx := context.Background()
+
+	type event struct {
+		table   string
+		id      interface{}
+		changes []ChangeSet
+	}
+	var events []event
+	var fail error
+	repo := New[TestArticle](db, WithChangeTracking(func(tx *gorm.DB, table string, id interface{}, changes []ChangeSet) error {
+		events = append(events, event{table, id, changes})
+		return fail
+	}))
+
+	article := &TestArticle{Title: "Draft"}
+	if err := repo.Create(ctx, article); err != nil {
+		t.Fatalf("Failed to create article: %v", err)
+	}
+
+	t.Run("reports changed columns", func(t *testing.T) {
+		article.Title = "Final"
+		if err := repo.Update(ctx, article); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if len(events) != 1 {
+			t.Fatalf("Expected 1 event, got %d", len(events))
+		}
+		got := events[0]
+		if got.table != "test_articles" || got.id != article.ID {
+			t.Errorf("Expected test_articles #%d, got %s #%v", article.ID, got.table, got.id)
+		}
+		want := []ChangeSet{{Column: "title", Old: "Draft", New: "Final"}}
+		if len(got.changes) != 1 || got.changes[0] != want[0] {
+			t.Errorf("Expected %v, got %v", want, got.changes)
+		}
+	})
+
+	t.Run("skips updates without changes", func(t *testing.T) {
+		events = nil
+		if err := repo.Update(ctx, article); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if len(events) != 0 {
+			t.Errorf("Expected no event, got %v", events)
+		}
+	})
+
+	t.Run("handler errors roll the update back", func(t *testing.T) {
+		fail = errors.New("audit log unavailable")
+		defer func() { fail = nil }()
+
+		changed := *article
+		changed.Title = "Rejected"
+		if err := repo.Update(ctx, &changed); !errors.Is(err, fail) {
+			t.Fatalf("Expected the handler error, got %v", err)
+		}
+
+		var stored TestArticle
+		repo.FindByID(ctx, article.ID, &stored)
+		if stored.Title != "Final" {
+			t.Errorf("Expected the title to stay Final, got %q", stored.Title)
+		}
+	})
+
+	t.Run("rejects a nil handler", func(t *testing.T) {
+		if err := New[TestArticle](db, WithChangeTracking(nil)).Update(ctx, article); err == nil {
+			t.Error("Expected an error for a nil handler")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm/clause"
+)
+
+// inParamsMargin leaves room for the parameters of scopes and other
+// conditions next to a chunked IN list
+const inParamsMargin = 100
+
+// inChunkSize returns how many values one IN list may hold on the dialect
+func (r *Repository[T]) inChunkSize() int {
+	return dialect.MaxParams(r.Dialect()) - inParamsMargin
+}
+
+// findIn finds the records whose column is one of values. Long value lists
+// are split into several queries that each stay under the driver's
+// parameter limit, and their results are concatenated.
+func (r *Repository[T]) findIn(ctx context.Context, column string, values []interface{}) ([]T, error) {
+	var entities []T
+	for _, chunk := range chunkValues(uniqueValues(values), r.inChunkSize()) {
+		var found []T
+		err := r.conn(ctx).Where(clause.IN{
+			Column: clause.Column{Table: clause.CurrentTable, Name: column},
+			Values: chunk,
+		}).Find(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, found...)
+	}
+	return entities, nil
+}
+
+// uniqueValues drops repeated values, which would otherwise match the same
+// row once per chunk they land in
+func uniqueValues(values []interface{}) []interface{} {
+	seen := make(map[string]bool, len(values))
+	unique := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		key := fmt.Sprintf("%T:%v", v, v)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+// chunkValues splits values into consecutive slices of at most size elements
+func chunkValues(values []interface{}, size int) [][]interface{} {
+	var chunks [][]interface{}
+	for len(values) > size {
+		chunks = append(chunks, values[:size:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestChunkedIDLists(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	ctx := context.Background()
+
+	const n = 2000
+	users := make([]TestUser, n)
+	softUsers := make([]TestSoftUser, n)
+	for i := range users {
+		users[i] = TestUser{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+		softUsers[i] = TestSoftUser{Name: users[i].Name, Email: users[i].Email}
+	}
+	db.CreateInBatches(&users, 500)
+	db.CreateInBatches(&softUsers, 500)
+
+	var queries int
+	db.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) {
+		queries++
+	})
+
+	ids := make([]uint, n)
+	values := make([]interface{}, n)
+	for i := range ids {
+		ids[i] = uint(n - i)
+		values[i] = uint(i + 1)
+	}
+
+	t.Run("FindByIDsOrdered", func(t *testing.T) {
+		queries = 0
+		found, err := New[TestUser](db).FindByIDsOrdered(ctx, ids)
+		if err != nil {
+			t.Fatalf("FindByIDsOrdered failed: %v", err)
+		}
+		if len(found) != n || found[0].ID != n || found[n-1].ID != 1 {
+			t.Errorf("Expected %d users in requested order, got %d", n, len(found))
+		}
+		if queries < 2 {
+			t.Errorf("Expected the ID list to be chunked, got %d queries", queries)
+		}
+	})
+
+	t.Run("FindWhereIn", func(t *testing.T) {
+		found, err := New[TestUser](db).FindWhereIn(ctx, "id", append(values, values[0]))
+		if err != nil {
+			t.Fatalf("FindWhereIn failed: %v", err)
+		}
+		if len(found) != n {
+			t.Errorf("Expected %d users, got %d", n, len(found))
+		}
+	})
+
+	t.Run("SoftDeleteByIDs", func(t *testing.T) {
+		repo := New[TestSoftUser](db)
+		deleted, err := repo.SoftDeleteByIDs(ctx, values)
+		if err != nil {
+			t.Fatalf("SoftDeleteByIDs failed: %v", err)
+		}
+		if deleted != n {
+			t.Errorf("Expected %d rows deleted, got %d", n, deleted)
+		}
+		if live, _ := repo.Count(ctx); live != 0 {
+			t.Errorf("Expected no live rows, got %d", live)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotInTransaction is returned when an operation that only makes
+	// sense inside a transaction is called on a repository bound to a pool
+	ErrNotInTransaction = errors.New("not in a transaction")
+	// ErrDeferredConstraintsUnsupported is returned by SetConstraintsDeferred
+	// on databases other than postgres
+	ErrDeferredConstraintsUnsupported = errors.New("deferred constraints require postgres")
+)
+
+// SetConstraintsDeferred issues SET CONSTRAINTS ALL DEFERRED so that
+// constraint checks run at commit instead of after each statement, allowing
+// rows with circular foreign keys to be inserted in any order. Call it on a
+// repository bound to the transaction:
+//
+//	db.Transaction(func(tx *gorm.DB) error {
+//		if err := repository.New[Node](tx).SetConstraintsDeferred(ctx); err != nil {
+//			return err
+//		}
+//		...
+//	})
+//
+// It only affects constraints declared DEFERRABLE and is postgres-only; other
+// databases get ErrDeferredConstraintsUnsupported. Outside a transaction it
+// returns ErrNotInTransaction, since the setting would end with the statement.
+func (r *Repository[T]) SetConstraintsDeferred(ctx context.Context) error {
+	ctx = r.checkDeadline(ctx)
+	if r.err != nil {
+		return r.err
+	}
+	if _, ok := r.db.Statement.ConnPool.(gorm.TxCommitter); !ok {
+		return ErrNotInTransaction
+	}
+	if r.Dialect() != dialect.Postgres {
+		return ErrDeferredConstraintsUnsupported
+	}
+	return r.db.WithContext(ctx).Exec("SET CONSTRAINTS ALL DEFERRED").Error
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestSetConstraintsDeferred(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	t.Run("requires a transaction", func(t *testing.T) {
+		err := New[TestUser](db).SetConstraintsDeferred(ctx)
+		if !errors.Is(err, ErrNotInTransaction) {
+			t.Errorf("Expected ErrNotInTransaction, got %v", err)
+		}
+	})
+
+	t.Run("requires postgres", func(t *testing.T) {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return New[TestUser](tx).SetConstraintsDeferred(ctx)
+		})
+		if !errors.Is(err, ErrDeferredConstraintsUnsupported) {
+			t.Errorf("Expected ErrDeferredConstraintsUnsupported, got %v", err)
+		}
+	})
+}
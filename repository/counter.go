@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrNonNumericColumn is returned when Increment or Decrement targets a
+	// column that does not hold numbers
+	ErrNonNumericColumn = errors.New("column is not numeric")
+	// ErrNegativeCounter is returned when a NonNegative Increment or
+	// Decrement would take the column below zero
+	ErrNegativeCounter = errors.New("counter would become negative")
+)
+
+// CounterOption configures Increment and Decrement
+type CounterOption func(*counterOptions)
+
+type counterOptions struct {
+	nonNegative bool
+}
+
+// NonNegative makes Increment and Decrement leave the row untouched and fail
+// with ErrNegativeCounter when the change would take the column below zero,
+// for values such as stock levels that must not go negative. The guard
+// compares the column with the delta, so it also works on unsigned columns.
+// A change the guard allows but that leaves the row as it was, like a zero
+// delta, succeeds.
+func NonNegative() CounterOption {
+	return func(o *counterOptions) {
+		o.nonNegative = true
+	}
+}
+
+// Increment atomically adds delta to column on the record with the given ID
+// in a single UPDATE ... SET column = column + delta, so concurrent callers
+// never lose each other's changes the way a read-modify-write would:
+//
+//	repo.Increment(ctx, article.ID, "views", 1)
+//
+// The column must be a numeric field of T and delta a number. The
+// auto-updated timestamp is bumped like UpdateColumns does. It returns
+// gorm.ErrRecordNotFound when there is no record with the ID.
+func (r *Repository[T]) Increment(ctx context.Context, id interface{}, column string, delta interface{}, opts ...CounterOption) error {
+	ctx = r.checkDeadline(ctx)
+	return r.addToColumn(ctx, id, column, "+", delta, opts)
+}
+
+// Decrement atomically subtracts delta from column on the record with the
+// given ID, like Increment. Combine it with NonNegative to reserve stock:
+//
+//	err := repo.Decrement(ctx, product.ID, "stock", qty, repository.NonNegative())
+//	if errors.Is(err, repository.ErrNegativeCounter) {
+//		// not enough stock left
+//	}
+func (r *Repository[T]) Decrement(ctx context.Context, id interface{}, column string, delta interface{}, opts ...CounterOption) error {
+	ctx = r.checkDeadline(ctx)
+	return r.addToColumn(ctx, id, column, "-", delta, opts)
+}
+
+// addToColumn applies column = column <op> delta to the record with the ID
+func (r *Repository[T]) addToColumn(ctx context.Context, id interface{}, column, op string, delta interface{}, opts []CounterOption) error {
+	defer r.forget(ctx)
+	var o counterOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+	field := s.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidColumn, column)
+	}
+	if !isNumericField(field) {
+		return fmt.Errorf("%w: %q", ErrNonNumericColumn, column)
+	}
+	if !isNumber(delta) {
+		return fmt.Errorf("counter delta must be a number, got %T", delta)
+	}
+
+	col := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	value := gorm.Expr("? "+op+" ?", col, delta)
+
+	// The guard compares instead of computing column - delta, which overflows
+	// on mysql's unsigned columns before it could be compared with zero
+	var guard clause.Expression
+	if o.nonNegative {
+		floor := delta
+		if op == "+" {
+			floor = negate(delta)
+		}
+		guard = gorm.Expr("? >= ?", col, floor)
+	}
+
+	tx := r.conn(ctx).Where(pkCondition(pk, id))
+	if guard != nil {
+		tx = tx.Where(guard)
+	}
+	rows, err := r.updateColumns(tx, s, map[string]interface{}{field.DBName: value})
+	if err != nil || rows > 0 {
+		return err
+	}
+
+	// No row changed: either there is no such record, the guard held it back,
+	// or the driver does not count rows whose values stayed the same, as
+	// mysql does for a zero delta on a model without an updated_at
+	found, err := r.exists(r.conn(ctx), pkCondition(pk, id), nil)
+	if err != nil || !found {
+		if err == nil {
+			err = gorm.ErrRecordNotFound
+		}
+		return err
+	}
+	if guard == nil {
+		return nil
+	}
+	allowed, err := r.exists(r.conn(ctx).Where(guard), pkCondition(pk, id), nil)
+	if err != nil || allowed {
+		return err
+	}
+	return ErrNegativeCounter
+}
+
+// negate returns -v for a Go number, as an int64 for unsigned values
+func negate(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		return -rv.Int()
+	case rv.CanUint():
+		return -int64(rv.Uint())
+	}
+	return -rv.Float()
+}
+
+// isNumericField reports whether field maps to a numeric column
+func isNumericField(field *schema.Field) bool {
+	switch field.DataType {
+	case schema.Int, schema.Uint, schema.Float:
+		return true
+	}
+	typ := strings.ToLower(string(field.DataType))
+	return strings.HasPrefix(typ, "decimal") || strings.HasPrefix(typ, "numeric")
+}
+
+// isNumber reports whether v is a Go number
+func isNumber(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
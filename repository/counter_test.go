@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestIncrement(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Alice", Email: "alice@example.com", Age: 10}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	age := func() int {
+		var found TestUser
+		if err := repo.FindByID(ctx, user.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		return found.Age
+	}
+
+	t.Run("adds and subtracts", func(t *testing.T) {
+		if err := repo.Increment(ctx, user.ID, "Age", 5); err != nil {
+			t.Fatalf("Increment failed: %v", err)
+		}
+		if err := repo.Decrement(ctx, user.ID, "age", 3); err != nil {
+			t.Fatalf("Decrement failed: %v", err)
+		}
+		if got := age(); got != 12 {
+			t.Errorf("Expected age 12, got %d", got)
+		}
+	})
+
+	t.Run("NonNegative guards the column", func(t *testing.T) {
+		before := age()
+		err := repo.Decrement(ctx, user.ID, "age", before+1, NonNegative())
+		if !errors.Is(err, ErrNegativeCounter) {
+			t.Fatalf("Expected ErrNegativeCounter, got %v", err)
+		}
+		if got := age(); got != before {
+			t.Errorf("Expected age to stay %d, got %d", before, got)
+		}
+
+		if err := repo.Decrement(ctx, user.ID, "age", before, NonNegative()); err != nil {
+			t.Fatalf("Expected decrement to zero to pass, got %v", err)
+		}
+		if got := age(); got != 0 {
+			t.Errorf("Expected age 0, got %d", got)
+		}
+	})
+
+	t.Run("NonNegative guards negative increments", func(t *testing.T) {
+		repo.Increment(ctx, user.ID, "age", 2)
+		if err := repo.Increment(ctx, user.ID, "age", -3, NonNegative()); !errors.Is(err, ErrNegativeCounter) {
+			t.Errorf("Expected ErrNegativeCounter, got %v", err)
+		}
+		if err := repo.Increment(ctx, user.ID, "age", -2, NonNegative()); err != nil {
+			t.Errorf("Expected increment to zero to pass, got %v", err)
+		}
+		if err := repo.Decrement(ctx, user.ID, "age", 0, NonNegative()); err != nil {
+			t.Errorf("Expected a zero delta to pass, got %v", err)
+		}
+	})
+
+	t.Run("NonNegative compares without subtracting", func(t *testing.T) {
+		// Subtracting first overflows unsigned columns on mysql
+		var sql []string
+		my := setupDryRunDB(t, mysql.New(mysql.Config{DSN: "user@tcp(localhost)/db", SkipInitializeWithVersion: true}), func(s string) { sql = append(sql, s) })
+		my = my.Session(&gorm.Session{SkipDefaultTransaction: true})
+		New[TestUser](my).Decrement(ctx, 1, "age", 3, NonNegative())
+
+		want := "UPDATE `test_users` SET `age`=`test_users`.`age` - 3 WHERE `test_users`.`id` = 1 AND `test_users`.`age` >= 3"
+		if len(sql) == 0 || sql[0] != want {
+			t.Errorf("Expected %s, got %v", want, sql)
+		}
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		err := repo.Increment(ctx, user.ID+100, "age", 1)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid columns and deltas", func(t *testing.T) {
+		if err := repo.Increment(ctx, user.ID, "name", 1); !errors.Is(err, ErrNonNumericColumn) {
+			t.Errorf("Expected ErrNonNumericColumn, got %v", err)
+		}
+		if err := repo.Increment(ctx, user.ID, "unknown", 1); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if err := repo.Increment(ctx, user.ID, "age", "1; DROP TABLE test_users"); err == nil {
+			t.Error("Expected a non-numeric delta to be rejected")
+		}
+	})
+}
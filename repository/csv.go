@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ExportCSV writes the records matching the condition to w as CSV, with a
+// header row of column names followed by one line per record. An empty
+// columns exports every column of T and an empty condition exports the whole
+// table. Rows are loaded in batches of the repository's default batch size,
+// so memory stays bounded regardless of the table size.
+//
+// NULL becomes an empty field, times are written in RFC 3339 format and
+// other values in their fmt default format.
+func (r *Repository[T]) ExportCSV(ctx context.Context, w io.Writer, columns []string, query interface{}, args ...interface{}) error {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		columns = s.DBNames
+	}
+	cols, err := r.columns(columns)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(cols); err != nil {
+		return err
+	}
+
+	tx := r.conn(ctx)
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+
+	var batch []T
+	record := make([]string, len(cols))
+	err = tx.FindInBatches(&batch, r.batchSize(0), func(*gorm.DB, int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for i := range batch {
+			rv := reflect.ValueOf(&batch[i]).Elem()
+			for j, col := range cols {
+				value, _ := s.LookUpField(col).ValueOf(ctx, rv)
+				record[j] = formatCSVValue(value)
+			}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+
+		out.Flush()
+		return out.Error()
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// ImportCSV reads CSV from r, whose first row is a header of column names,
+// and inserts one record per following row, returning the number inserted.
+// Every header column must be a column of T. A non-empty columns imports only
+// those columns, which must all appear in the header; the rest are ignored.
+// Empty fields leave the field at its zero value.
+//
+// The import runs in a single transaction and inserts in batches of the
+// repository's default batch size. Rows that fail to parse don't stop the
+// scan: all of them are reported in one error, by line number, and nothing is
+// imported.
+func (r *Repository[T]) ImportCSV(ctx context.Context, reader io.Reader, columns []string) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+
+	in := csv.NewReader(reader)
+	header, err := in.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	fields := make([]*schema.Field, len(header))
+	for i, name := range header {
+		col, err := r.column(name)
+		if err != nil {
+			return 0, err
+		}
+		fields[i] = s.LookUpField(col)
+	}
+
+	if len(columns) > 0 {
+		cols, err := r.columns(columns)
+		if err != nil {
+			return 0, err
+		}
+		selected := make(map[string]bool, len(cols))
+		for _, col := range cols {
+			selected[col] = true
+		}
+		for i, field := range fields {
+			if selected[field.DBName] {
+				delete(selected, field.DBName)
+			} else {
+				fields[i] = nil
+			}
+		}
+		for col := range selected {
+			return 0, fmt.Errorf("column %q is missing from the CSV header", col)
+		}
+	}
+
+	var imported int64
+	err = r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var rowErrs []error
+		batch := make([]T, 0, r.batchSize(0))
+
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			// After a row error nothing is committed, so only drop the batch
+			if len(rowErrs) > 0 {
+				batch = batch[:0]
+				return nil
+			}
+			if err := tx.Create(&batch).Error; err != nil {
+				return err
+			}
+			imported += int64(len(batch))
+			batch = batch[:0]
+			return nil
+		}
+
+		for {
+			record, err := in.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					err = fmt.Errorf("line %d: %w", parseErr.Line, parseErr.Err)
+				}
+				rowErrs = append(rowErrs, err)
+				continue
+			}
+			line, _ := in.FieldPos(0)
+
+			var entity T
+			rv := reflect.ValueOf(&entity).Elem()
+			for i, field := range fields {
+				if field == nil || record[i] == "" {
+					continue
+				}
+				if err := setCSVValue(ctx, field, rv, record[i]); err != nil {
+					rowErrs = append(rowErrs, fmt.Errorf("line %d: column %s: %w", line, field.DBName, err))
+				}
+			}
+
+			batch = append(batch, entity)
+			if len(batch) == cap(batch) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(rowErrs) > 0 {
+			imported = 0
+			return fmt.Errorf("failed to import CSV: %w", errors.Join(rowErrs...))
+		}
+		return flush()
+	})
+	return imported, err
+}
+
+// csvTimeFormats are the layouts ImportCSV accepts for time columns
+var csvTimeFormats = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// setCSVValue parses a CSV field into the entity's field
+func setCSVValue(ctx context.Context, field *schema.Field, rv reflect.Value, value string) error {
+	if field.DataType != schema.Time {
+		return field.Set(ctx, rv, value)
+	}
+
+	for _, layout := range csvTimeFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return field.Set(ctx, rv, t)
+		}
+	}
+	return fmt.Errorf("invalid time %q", value)
+}
+
+// formatCSVValue renders a column value as a CSV field
+func formatCSVValue(value interface{}) string {
+	value = indirectValue(value)
+	if valuer, ok := value.(driver.Valuer); ok {
+		stored, err := valuer.Value()
+		if err == nil {
+			value = indirectValue(stored)
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db, WithDefaultBatchSize(2))
+	ctx := context.Background()
+
+	users := []TestUser{
+		{Name: "Alice", Email: "alice@example.com", Age: 25},
+		{Name: "Bob, Jr.", Email: "bob@example.com", Age: 30},
+		{Name: "Charlie", Email: "charlie@example.com", Age: 35},
+	}
+	for i := range users {
+		repo.Create(ctx, &users[i])
+	}
+
+	t.Run("exports selected columns of matching rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := repo.ExportCSV(ctx, &buf, []string{"name", "age"}, "age >= ?", 30)
+		if err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+
+		want := "name,age\n\"Bob, Jr.\",30\nCharlie,35\n"
+		if buf.String() != want {
+			t.Errorf("Expected CSV %q, got %q", want, buf.String())
+		}
+	})
+
+	t.Run("exports every column by default", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := repo.ExportCSV(ctx, &buf, nil, nil); err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if lines[0] != "id,name,email,age" {
+			t.Errorf("Expected header id,name,email,age, got %s", lines[0])
+		}
+		if len(lines) != 4 {
+			t.Errorf("Expected header and 3 rows, got %d lines", len(lines))
+		}
+	})
+
+	t.Run("rejects unknown columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := repo.ExportCSV(ctx, &buf, []string{"password"}, nil)
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("imports rows and reports the count", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t), WithDefaultBatchSize(2))
+		input := "name,email,age\nAlice,alice@example.com,25\nBob,bob@example.com,30\nCarol,carol@example.com,\n"
+
+		imported, err := repo.ImportCSV(ctx, strings.NewReader(input), nil)
+		if err != nil {
+			t.Fatalf("ImportCSV failed: %v", err)
+		}
+		if imported != 3 {
+			t.Errorf("Expected 3 rows imported, got %d", imported)
+		}
+
+		var bob TestUser
+		repo.FirstWhere(ctx, &bob, "email = ?", "bob@example.com")
+		if bob.Age != 30 {
+			t.Errorf("Expected Bob's age 30, got %d", bob.Age)
+		}
+	})
+
+	t.Run("imports only selected columns", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t))
+		input := "name,email,age\nAlice,alice@example.com,25\n"
+
+		if _, err := repo.ImportCSV(ctx, strings.NewReader(input), []string{"email"}); err != nil {
+			t.Fatalf("ImportCSV failed: %v", err)
+		}
+
+		users, _ := repo.FindAll(ctx)
+		if len(users) != 1 || users[0].Name != "" || users[0].Email != "alice@example.com" {
+			t.Errorf("Expected only the email to be imported, got %+v", users)
+		}
+	})
+
+	t.Run("round-trips an export with times", func(t *testing.T) {
+		db := setupTestDB(t, &TestArticle{})
+		source := New[TestArticle](db)
+		source.Create(ctx, &TestArticle{Title: "Exported"})
+
+		var buf bytes.Buffer
+		if err := source.ExportCSV(ctx, &buf, []string{"title", "created_at"}, nil); err != nil {
+			t.Fatalf("ExportCSV failed: %v", err)
+		}
+		db.Exec("DELETE FROM test_articles")
+
+		imported, err := source.ImportCSV(ctx, &buf, nil)
+		if err != nil {
+			t.Fatalf("ImportCSV failed: %v", err)
+		}
+		if imported != 1 {
+			t.Errorf("Expected 1 row imported, got %d", imported)
+		}
+	})
+
+	t.Run("reports every bad row and rolls back", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t), WithDefaultBatchSize(1))
+		input := "name,email,age\nAlice,alice@example.com,25\nBob,bob@example.com,old\nCarol,carol@example.com,x\n"
+
+		imported, err := repo.ImportCSV(ctx, strings.NewReader(input), nil)
+		if err == nil {
+			t.Fatal("Expected import error")
+		}
+		if !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "line 4") {
+			t.Errorf("Expected errors for lines 3 and 4, got %v", err)
+		}
+		if imported != 0 {
+			t.Errorf("Expected 0 rows imported, got %d", imported)
+		}
+
+		count, _ := repo.Count(ctx)
+		if count != 0 {
+			t.Errorf("Expected import to be rolled back, got %d rows", count)
+		}
+	})
+
+	t.Run("reports malformed rows", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t))
+		input := "name,email,age\nAlice,alice@example.com,25\nB\"ob,bob@example.com,30\nCarol,carol@example.com\n"
+
+		_, err := repo.ImportCSV(ctx, strings.NewReader(input), nil)
+		if err == nil {
+			t.Fatal("Expected import error")
+		}
+		if !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "line 4") {
+			t.Errorf("Expected errors for lines 3 and 4, got %v", err)
+		}
+		if count, _ := repo.Count(ctx); count != 0 {
+			t.Errorf("Expected import to be rolled back, got %d rows", count)
+		}
+	})
+
+	t.Run("rejects unknown header columns", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t))
+		_, err := repo.ImportCSV(ctx, strings.NewReader("name,password\nAlice,secret\n"), nil)
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
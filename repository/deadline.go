@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// packageDir is the directory of this package's source files, to tell its
+// frames apart from those of the caller
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// WithMissingDeadlineWarning makes the repository log a warning through the
+// GORM logger whenever it is called with a context that has no deadline, so
+// code that forgets to bound its queries shows up during development and
+// testing. Each call warns once and the warning names the first caller outside
+// this package. It is meant for development builds and is off by default.
+//
+// Deadlines the repository derives itself count: the transaction of
+// TransactionWithDeadline, and repositories bound to it, never warn.
+func WithMissingDeadlineWarning() Option {
+	return func(o *options) {
+		o.warnMissingDeadline = true
+	}
+}
+
+// deadlineChecked marks a context that checkDeadline has already seen, so a
+// call that runs several statements or calls other methods warns only once
+type deadlineChecked struct{}
+
+// checkDeadline logs a warning for a ctx without deadline when enabled with
+// WithMissingDeadlineWarning. Every exported method calls it on entry and
+// continues with the returned ctx, which is marked as checked.
+func (r *Repository[T]) checkDeadline(ctx context.Context) context.Context {
+	if !r.opts.warnMissingDeadline || ctx.Value(deadlineChecked{}) != nil {
+		return ctx
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx
+	}
+	// A repository bound to a transaction runs on the context the
+	// transaction was started with
+	if bound := r.db.Statement.Context; bound != nil {
+		if _, ok := bound.Deadline(); ok {
+			return ctx
+		}
+	}
+	var model T
+	r.db.Logger.Warn(ctx, "repository of %T called from %s with a context without deadline", model, externalCaller())
+	return context.WithValue(ctx, deadlineChecked{}, true)
+}
+
+// externalCaller returns the file and line of the first caller outside the
+// non-test code of this package
+func externalCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
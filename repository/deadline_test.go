@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestWithMissingDeadlineWarning(t *testing.T) {
+	db := setupTestDB(t)
+	warnings := &warnRecorder{Interface: logger.Discard}
+	repo := New[TestUser](db.Session(&gorm.Session{Logger: warnings}), WithMissingDeadlineWarning())
+
+	t.Run("warns without deadline", func(t *testing.T) {
+		warnings.messages = nil
+		if _, err := repo.FindAll(context.Background()); err != nil {
+			t.Fatalf("FindAll failed: %v", err)
+		}
+		if len(warnings.messages) != 1 {
+			t.Fatalf("Expected 1 warning, got %v", warnings.messages)
+		}
+		if !strings.Contains(warnings.messages[0], "deadline_test.go") {
+			t.Errorf("Expected the warning to name the caller, got %q", warnings.messages[0])
+		}
+	})
+
+	t.Run("is quiet with a deadline", func(t *testing.T) {
+		warnings.messages = nil
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := repo.FindAll(ctx); err != nil {
+			t.Fatalf("FindAll failed: %v", err)
+		}
+		if len(warnings.messages) != 0 {
+			t.Errorf("Expected no warning, got %v", warnings.messages)
+		}
+	})
+
+	t.Run("is quiet inside TransactionWithDeadline", func(t *testing.T) {
+		warnings.messages = nil
+		err := repo.TransactionWithDeadline(context.Background(), time.Minute, func(tx *gorm.DB) error {
+			bound := New[TestUser](tx, WithMissingDeadlineWarning())
+			return bound.Create(context.Background(), &TestUser{Name: "Bound", Email: "bound@example.com"})
+		})
+		if err != nil {
+			t.Fatalf("TransactionWithDeadline failed: %v", err)
+		}
+		if len(warnings.messages) != 0 {
+			t.Errorf("Expected no warning, got %v", warnings.messages)
+		}
+	})
+
+	t.Run("warns once per call", func(t *testing.T) {
+		soft := New[TestSoftUser](setupTestDB(t, &TestSoftUser{}).Session(&gorm.Session{Logger: warnings}), WithMissingDeadlineWarning())
+		warnings.messages = nil
+		if _, _, err := repo.Paginate(context.Background(), 1, 10); err != nil {
+			t.Fatalf("Paginate failed: %v", err)
+		}
+		if err := soft.Restore(context.Background(), 9999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("Expected ErrRecordNotFound, got %v", err)
+		}
+		if len(warnings.messages) != 2 {
+			t.Errorf("Expected 1 warning per call, got %v", warnings.messages)
+		}
+	})
+
+	t.Run("is off by default", func(t *testing.T) {
+		warnings.messages = nil
+		New[TestUser](db.Session(&gorm.Session{Logger: warnings})).FindAll(context.Background())
+		if len(warnings.messages) != 0 {
+			t.Errorf("Expected no warning, got %v", warnings.messages)
+		}
+	})
+}
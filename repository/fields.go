@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// colCache holds the field-to-column maps built by Col, per model type
+var colCache sync.Map // reflect.Type -> map[string]string
+
+// Col returns the column of T's Go field fieldName, for callers that build
+// conditions, orderings or selections from Go field names and want a typo to
+// fail instead of reaching the database:
+//
+//	status, err := repository.Col[Order]("Status")
+//	orders, err := repo.FindWhere(ctx, status+" = ?", "paid")
+//
+// Unlike the column arguments of Repository methods, only Go field names are
+// accepted, not column names. Embedded fields are found by their own names.
+// Columns are named with GORM's default naming strategy; models relying on a
+// custom NamingStrategy of their *gorm.DB need column tags for Col to agree
+// with it. It fails with ErrInvalidColumn for fields that are not columns.
+// The map of each type is built once and cached.
+func Col[T any](fieldName string) (string, error) {
+	columns, err := fieldColumns(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return "", err
+	}
+	column, ok := columns[fieldName]
+	if !ok {
+		return "", fmt.Errorf("%w: field %q", ErrInvalidColumn, fieldName)
+	}
+	return column, nil
+}
+
+// fieldColumns returns the field-to-column map of a model type
+func fieldColumns(typ reflect.Type) (map[string]string, error) {
+	if columns, ok := colCache.Load(typ); ok {
+		return columns.(map[string]string), nil
+	}
+
+	s, err := schema.Parse(reflect.New(typ).Interface(), &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]string, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.DBName != "" {
+			columns[field.Name] = field.DBName
+		}
+	}
+
+	actual, _ := colCache.LoadOrStore(typ, columns)
+	return actual.(map[string]string), nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCol(t *testing.T) {
+	t.Run("maps field names to columns", func(t *testing.T) {
+		tests := map[string]string{"ID": "id", "Name": "name", "Email": "email"}
+		for field, want := range tests {
+			got, err := Col[TestUser](field)
+			if err != nil {
+				t.Fatalf("Col(%s) failed: %v", field, err)
+			}
+			if got != want {
+				t.Errorf("Col(%s): expected %s, got %s", field, want, got)
+			}
+		}
+	})
+
+	t.Run("rejects unknown fields and column names", func(t *testing.T) {
+		for _, field := range []string{"Nmae", "email"} {
+			if _, err := Col[TestUser](field); !errors.Is(err, ErrInvalidColumn) {
+				t.Errorf("Col(%s): expected ErrInvalidColumn, got %v", field, err)
+			}
+		}
+	})
+
+	t.Run("builds conditions", func(t *testing.T) {
+		db := setupTestDB(t)
+		repo := New[TestUser](db)
+		ctx := context.Background()
+		repo.Create(ctx, &TestUser{Name: "Alice", Email: "alice@example.com", Age: 30})
+
+		age, err := Col[TestUser]("Age")
+		if err != nil {
+			t.Fatalf("Col failed: %v", err)
+		}
+		users, err := repo.FindWhere(ctx, age+" = ?", 30)
+		if err != nil {
+			t.Fatalf("FindWhere failed: %v", err)
+		}
+		if len(users) != 1 {
+			t.Errorf("Expected 1 user, got %d", len(users))
+		}
+	})
+}
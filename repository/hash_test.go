@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestRowHash(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+
+	user := &TestUser{ID: 1, Name: "Alice", Email: "alice@example.com", Age: 30}
+
+	t.Run("is stable and order independent", func(t *testing.T) {
+		a, err := repo.RowHash(user, "name", "email")
+		if err != nil {
+			t.Fatalf("RowHash failed: %v", err)
+		}
+		b, _ := repo.RowHash(user, "Email", "Name")
+		if a != b {
+			t.Errorf("Expected the same hash regardless of column order, got %s and %s", a, b)
+		}
+		if len(a) != 64 {
+			t.Errorf("Expected a SHA-256 hex digest, got %q", a)
+		}
+	})
+
+	t.Run("changes with the hashed columns only", func(t *testing.T) {
+		before, _ := repo.RowHash(user, "name", "email")
+		changed := *user
+		changed.Age = 31
+		after, _ := repo.RowHash(&changed, "name", "email")
+		if before != after {
+			t.Error("Expected unhashed columns not to affect the hash")
+		}
+
+		changed.Name = "Alicia"
+		after, _ = repo.RowHash(&changed, "name", "email")
+		if before == after {
+			t.Error("Expected a hashed column to change the hash")
+		}
+	})
+
+	t.Run("hashes all columns by default", func(t *testing.T) {
+		all, _ := repo.RowHash(user)
+		listed, _ := repo.RowHash(user, "id", "name", "email", "age")
+		if all != listed {
+			t.Errorf("Expected default hash over all columns, got %s and %s", all, listed)
+		}
+	})
+
+	t.Run("rejects unknown columns", func(t *testing.T) {
+		if _, err := repo.RowHash(user, "missing"); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestHashValue(t *testing.T) {
+	name := "x"
+	var nilName *string
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("", 3600))
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "n"},
+		{nilName, "n"},
+		{&name, "sx"},
+		{"", "s"},
+		{0, "i0"},
+		{uint8(7), "u7"},
+		{1.5, "f1.5"},
+		{true, "b1"},
+		{[]byte{0xab}, "xab"},
+		{at, "t2024-01-02T02:04:05.000000006Z"},
+		{gorm.DeletedAt{}, "n"},
+		{map[string]int{"a": 1}, `j{"a":1}`},
+	}
+	for _, tt := range tests {
+		got, err := hashValue(tt.value)
+		if err != nil {
+			t.Fatalf("hashValue(%#v) failed: %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("hashValue(%#v): expected %q, got %q", tt.value, tt.want, got)
+		}
+	}
+}
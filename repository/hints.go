@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownIndex is returned when an index hint names an index the table
+// does not have
+var ErrUnknownIndex = errors.New("unknown index")
+
+// WithIndexHint returns a repository whose reads ask the database to use the
+// named index of T's table. It is an escape hatch for specific cases where
+// the planner picks the wrong index on a large table, not a tuning knob;
+// measure before and after using it.
+//
+// Support varies by driver:
+//
+//	mysql     FROM t USE INDEX (index), a hint the optimizer may still ignore
+//	sqlite    FROM t INDEXED BY index, which fails the query if the index cannot be used
+//	postgres  a /*+ IndexScan(t index) */ comment, honoured only when the
+//	          pg_hint_plan extension is loaded and ignored otherwise
+//
+// Other drivers get no hint. The hint is placed after the FROM clause, so it
+// does not combine with joins. Every call on the returned repository fails
+// with ErrUnknownIndex when the index does not exist.
+func (r *Repository[T]) WithIndexHint(index string) *Repository[T] {
+	if !r.db.Migrator().HasIndex(new(T), index) {
+		return r.withError(fmt.Errorf("%w: %q", ErrUnknownIndex, index))
+	}
+	hint := indexHint{dialect: dialect.Of(r.db), index: index}
+	return r.withScope(func(tx *gorm.DB) *gorm.DB {
+		return tx.Clauses(hint)
+	})
+}
+
+// indexHint renders a dialect-specific index hint into SELECT statements
+type indexHint struct {
+	dialect string
+	index   string
+}
+
+// ModifyStatement implements gorm.StatementModifier, attaching the hint to
+// the clause it has to follow or precede
+func (h indexHint) ModifyStatement(stmt *gorm.Statement) {
+	name, c := "FROM", stmt.Clauses["FROM"]
+	if h.dialect == dialect.Postgres {
+		// pg_hint_plan only reads hints from a comment at the start of the query
+		name, c = "SELECT", stmt.Clauses["SELECT"]
+		c.BeforeExpression = h
+	} else {
+		c.AfterExpression = h
+	}
+	c.Name = name
+	stmt.Clauses[name] = c
+}
+
+// Build implements clause.Expression
+func (h indexHint) Build(builder clause.Builder) {
+	stmt, ok := builder.(*gorm.Statement)
+	if !ok || len(stmt.BuildClauses) == 0 || stmt.BuildClauses[0] != "SELECT" {
+		// Index hints are not valid in single-table DELETE statements
+		return
+	}
+
+	switch h.dialect {
+	case dialect.MySQL:
+		builder.WriteString("USE INDEX (")
+		builder.WriteQuoted(h.index)
+		builder.WriteString(")")
+	case dialect.SQLite:
+		builder.WriteString("INDEXED BY ")
+		builder.WriteQuoted(h.index)
+	case dialect.Postgres:
+		// Hint comments take bare identifiers
+		builder.WriteString("/*+ IndexScan(" + stmt.Table + " " + h.index + ") */")
+	}
+}
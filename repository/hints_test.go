@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestWithIndexHint(t *testing.T) {
+	var statements []string
+	db := setupTestDB(t, &TestMember{})
+	db.Logger = sqlRecorder{Interface: db.Logger, record: func(sql string) { statements = append(statements, sql) }}
+	repo := New[TestMember](db)
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &TestMember{Email: "a@example.com"}); err != nil {
+		t.Fatalf("Failed to create member: %v", err)
+	}
+
+	t.Run("hints reads", func(t *testing.T) {
+		hinted := repo.WithIndexHint("idx_test_members_email")
+		statements = nil
+		members, err := hinted.FindWhere(ctx, "email = ?", "a@example.com")
+		if err != nil {
+			t.Fatalf("FindWhere failed: %v", err)
+		}
+		if len(members) != 1 {
+			t.Errorf("Expected 1 member, got %d", len(members))
+		}
+		if len(statements) != 1 || !strings.Contains(statements[0], "FROM `test_members` INDEXED BY `idx_test_members_email`") {
+			t.Errorf("Expected INDEXED BY hint, got %v", statements)
+		}
+	})
+
+	t.Run("leaves deletes alone", func(t *testing.T) {
+		statements = nil
+		m := TestMember{Email: "b@example.com"}
+		if err := repo.Create(ctx, &m); err != nil {
+			t.Fatalf("Failed to create member: %v", err)
+		}
+		if err := repo.WithIndexHint("idx_test_members_email").Delete(ctx, &m); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		for _, sql := range statements {
+			if strings.Contains(sql, "INDEXED BY") {
+				t.Errorf("Expected no hint, got %s", sql)
+			}
+		}
+	})
+
+	t.Run("validates index", func(t *testing.T) {
+		if _, err := repo.WithIndexHint("idx_missing").FindAll(ctx); !errors.Is(err, ErrUnknownIndex) {
+			t.Errorf("Expected ErrUnknownIndex, got %v", err)
+		}
+	})
+}
+
+func TestIndexHintDialects(t *testing.T) {
+	tests := []struct {
+		dialector gorm.Dialector
+		want      string
+	}{
+		{
+			mysql.New(mysql.Config{DSN: "user@tcp(localhost)/db", SkipInitializeWithVersion: true}),
+			"SELECT * FROM `test_members` USE INDEX (`idx_test_members_email`) WHERE `test_members`.`deleted_at` IS NULL",
+		},
+		{
+			postgres.Open("host=localhost"),
+			`/*+ IndexScan(test_members idx_test_members_email) */ SELECT * FROM "test_members" WHERE "test_members"."deleted_at" IS NULL`,
+		},
+	}
+
+	for _, tt := range tests {
+		var sql string
+		db := setupDryRunDB(t, tt.dialector, func(s string) { sql = s })
+		hint := indexHint{dialect: dialect.Of(db), index: "idx_test_members_email"}
+
+		var members []TestMember
+		db.Clauses(hint).Find(&members)
+		if sql != tt.want {
+			t.Errorf("%s: expected %s, got %s", dialect.Of(db), tt.want, sql)
+		}
+	}
+}
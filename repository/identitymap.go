@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// identityMapKey is the context key of the identity map
+type identityMapKey struct{}
+
+// identityMap holds the records loaded by FindByID within one request
+type identityMap struct {
+	mu      sync.Mutex
+	entries map[identityKey]interface{}
+}
+
+// identityKey identifies a record by its model type and primary key
+type identityKey struct {
+	model reflect.Type
+	id    string
+}
+
+// WithIdentityMap returns a context whose FindByID calls remember the records
+// they load, so that later FindByID calls for the same model and ID with the
+// context, from any repository, return the remembered record without
+// querying. Create it once per request, such as in an HTTP middleware:
+//
+//	next.ServeHTTP(w, req.WithContext(repository.WithIdentityMap(req.Context())))
+//
+// It is a request-scoped identity map, not a cache shared between requests,
+// and trades freshness for fewer queries:
+//
+//   - Writes through a repository with the context forget every remembered
+//     record of the model, but writes made elsewhere, by other requests or
+//     through a repository called with another context, are not seen for as
+//     long as the context lives.
+//   - Records read inside a transaction that rolls back stay remembered.
+//   - Repositories with scopes, such as WithDeleted, WithPrimary or default
+//     preloads, neither use nor fill the map.
+//   - A hit returns a shallow copy of the remembered record, so slices and
+//     pointers in it are shared between callers.
+func WithIdentityMap(ctx context.Context) context.Context {
+	return context.WithValue(ctx, identityMapKey{}, &identityMap{entries: make(map[identityKey]interface{})})
+}
+
+// identityMap returns the identity map of ctx, or nil when ctx has none or
+// the repository's reads differ from a plain FindByID
+func (r *Repository[T]) identityMap(ctx context.Context) *identityMap {
+	if r.err != nil || r.unscoped || len(r.scopes) > 0 || len(r.preloads) > 0 {
+		return nil
+	}
+	m, _ := ctx.Value(identityMapKey{}).(*identityMap)
+	return m
+}
+
+// identityKey returns the identity map key of the record of T with the ID
+func (r *Repository[T]) identityKey(id interface{}) identityKey {
+	return identityKey{model: reflect.TypeOf((*T)(nil)).Elem(), id: fmt.Sprint(id)}
+}
+
+// recall copies the remembered record with the ID into entity
+func (r *Repository[T]) recall(ctx context.Context, id interface{}, entity *T) bool {
+	m := r.identityMap(ctx)
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.entries[r.identityKey(id)].(T)
+	if ok {
+		*entity = stored
+	}
+	return ok
+}
+
+// remember stores a copy of entity as the record with the ID
+func (r *Repository[T]) remember(ctx context.Context, id interface{}, entity *T) {
+	if m := r.identityMap(ctx); m != nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.entries[r.identityKey(id)] = *entity
+	}
+}
+
+// forget drops every remembered record of T. Writes call it whatever the
+// repository's scopes, since a scoped write still changes the rows.
+func (r *Repository[T]) forget(ctx context.Context) {
+	m, _ := ctx.Value(identityMapKey{}).(*identityMap)
+	if m == nil {
+		return
+	}
+	model := reflect.TypeOf((*T)(nil)).Elem()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if key.model == model {
+			delete(m.entries, key)
+		}
+	}
+}
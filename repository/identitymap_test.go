@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestWithIdentityMap(t *testing.T) {
+	db := setupTestDB(t)
+	var selects int
+	db = db.Session(&gorm.Session{Logger: sqlRecorder{Interface: logger.Discard, record: func(sql string) {
+		if strings.HasPrefix(sql, "SELECT") {
+			selects++
+		}
+	}}})
+	repo := New[TestUser](db)
+
+	user := &TestUser{Name: "Alice", Email: "alice@example.com", Age: 30}
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	find := func(ctx context.Context, r *Repository[TestUser]) TestUser {
+		t.Helper()
+		var found TestUser
+		if err := r.FindByID(ctx, user.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		return found
+	}
+
+	t.Run("repeated reads hit the map", func(t *testing.T) {
+		ctx := WithIdentityMap(context.Background())
+		selects = 0
+		first := find(ctx, repo)
+		second := find(ctx, New[TestUser](db))
+		if selects != 1 {
+			t.Errorf("Expected 1 query, got %d", selects)
+		}
+		if first != second {
+			t.Errorf("Expected the same record, got %+v and %+v", first, second)
+		}
+
+		second.Name = "Changed"
+		if third := find(ctx, repo); third.Name != "Alice" {
+			t.Errorf("Expected callers to get copies, got name %q", third.Name)
+		}
+	})
+
+	t.Run("writes invalidate", func(t *testing.T) {
+		ctx := WithIdentityMap(context.Background())
+		find(ctx, repo)
+		if err := repo.UpdateColumns(ctx, user.ID, map[string]interface{}{"name": "Alicia"}); err != nil {
+			t.Fatalf("UpdateColumns failed: %v", err)
+		}
+		selects = 0
+		if found := find(ctx, repo); found.Name != "Alicia" {
+			t.Errorf("Expected the updated name, got %q", found.Name)
+		}
+		if selects != 1 {
+			t.Errorf("Expected the write to force a query, got %d", selects)
+		}
+	})
+
+	t.Run("disabled without the context or with scopes", func(t *testing.T) {
+		selects = 0
+		find(context.Background(), repo)
+		find(context.Background(), repo)
+		if selects != 2 {
+			t.Errorf("Expected 2 queries without an identity map, got %d", selects)
+		}
+
+		ctx := WithIdentityMap(context.Background())
+		selects = 0
+		find(ctx, repo.WithDeleted())
+		find(ctx, repo.WithDeleted())
+		if selects != 2 {
+			t.Errorf("Expected 2 queries with scopes, got %d", selects)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+)
+
+// PageIterator walks a table page by page in primary key order, fetching
+// each page with a keyset query only when Next is called, so memory stays
+// bounded by one page however large the table is:
+//
+//	it := repo.Iterator(ctx, 500)
+//	for it.Next() {
+//		for _, user := range it.Page() {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Rows inserted behind the current position while iterating are not seen.
+// A PageIterator is not safe for concurrent use.
+type PageIterator[T any] struct {
+	ctx    context.Context
+	repo   *Repository[T]
+	keyset Keyset
+	size   int
+	page   []T
+	done   bool
+	err    error
+}
+
+// Iterator returns a PageIterator over all records with up to pageSize rows
+// per page. A pageSize of zero or less uses the repository's default batch
+// size (see WithDefaultBatchSize).
+func (r *Repository[T]) Iterator(ctx context.Context, pageSize int) *PageIterator[T] {
+	ctx = r.checkDeadline(ctx)
+	it := &PageIterator[T]{ctx: ctx, repo: r, size: r.batchSize(pageSize)}
+
+	s, err := r.schema()
+	if err != nil {
+		it.err = err
+		return it
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		it.err = err
+		return it
+	}
+	it.keyset = Keyset{Columns: []string{pk.DBName}}
+	return it
+}
+
+// Next fetches the next page and reports whether there is one. It returns
+// false once the records are exhausted or a query fails; check Err to tell
+// the two apart.
+func (it *PageIterator[T]) Next() bool {
+	if it.done || it.err != nil {
+		it.page = nil
+		return false
+	}
+
+	page, next, err := it.repo.PaginateKeyset(it.ctx, it.keyset, it.size)
+	if err != nil {
+		it.err = err
+		it.page = nil
+		return false
+	}
+	if next == nil {
+		it.done = true
+	}
+	it.keyset.After = next
+	it.page = page
+	return len(page) > 0
+}
+
+// Page returns the page fetched by the last call to Next
+func (it *PageIterator[T]) Page() []T {
+	return it.page
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 25)
+
+	t.Run("walks every page", func(t *testing.T) {
+		it := repo.Iterator(ctx, 10)
+
+		var sizes []int
+		var last uint
+		for it.Next() {
+			sizes = append(sizes, len(it.Page()))
+			for _, user := range it.Page() {
+				if user.ID <= last {
+					t.Fatalf("Expected ascending IDs, got %d after %d", user.ID, last)
+				}
+				last = user.ID
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("Iterator failed: %v", err)
+		}
+		if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+			t.Errorf("Expected pages of 10, 10 and 5, got %v", sizes)
+		}
+		if it.Next() {
+			t.Error("Expected Next to stay false after the last page")
+		}
+	})
+
+	t.Run("ends on an exact multiple", func(t *testing.T) {
+		it := repo.Iterator(ctx, 5)
+
+		pages := 0
+		for it.Next() {
+			pages++
+		}
+		if it.Err() != nil || pages != 5 {
+			t.Errorf("Expected 5 pages without error, got %d and %v", pages, it.Err())
+		}
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		it := repo.Iterator(cancelled, 10)
+		if it.Next() {
+			t.Error("Expected Next to fail")
+		}
+		if it.Err() == nil {
+			t.Error("Expected an error")
+		}
+		if it.Page() != nil {
+			t.Errorf("Expected no page, got %d rows", len(it.Page()))
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLockTimeout is returned when a row lock could not be acquired within the
+// lock timeout. It wraps the driver's error.
+var ErrLockTimeout = errors.New("lock timeout")
+
+// LockOption configures FindByIDForUpdate
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	timeout    time.Duration
+	hasTimeout bool
+}
+
+// LockTimeout bounds how long FindByIDForUpdate waits for a row locked by
+// another transaction before failing with ErrLockTimeout. A d of zero or less
+// fails at once, with FOR UPDATE NOWAIT, on postgres and mysql 8.
+//
+// The timeout is set for the lock query only and reset afterwards:
+//
+//	postgres  SET LOCAL lock_timeout, through set_config, in milliseconds
+//	mysql     SET SESSION innodb_lock_wait_timeout, in whole seconds rounded
+//	          up, since mysql has no FOR UPDATE WAIT n
+//	sqlite    no row locks, so the option has no effect
+func LockTimeout(d time.Duration) LockOption {
+	return func(o *lockOptions) {
+		o.timeout = d
+		o.hasTimeout = true
+	}
+}
+
+// FindByIDForUpdate finds a record by ID like FindByID and locks its row with
+// FOR UPDATE. The lock lasts until the surrounding transaction ends, so call
+// it on a repository bound to the transaction that changes the row; see
+// ClaimNext. Without LockTimeout it waits as long as the database does.
+func (r *Repository[T]) FindByIDForUpdate(ctx context.Context, id interface{}, entity *T, opts ...LockOption) error {
+	ctx = r.checkDeadline(ctx)
+	var o lockOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		locking := clause.Locking{Strength: clause.LockingStrengthUpdate}
+		restore := func(err error) error { return err }
+		switch {
+		case o.hasTimeout && o.timeout <= 0:
+			locking.Options = clause.LockingOptionsNoWait
+		case o.hasTimeout:
+			var err error
+			if restore, err = setLockTimeout(tx, o.timeout); err != nil {
+				return err
+			}
+		}
+
+		err := r.preload(tx).Clauses(locking).First(entity, id).Error
+		return restore(lockError(err))
+	})
+}
+
+// setLockTimeout sets the lock wait timeout of the connection tx runs on and
+// returns a function that restores the previous value after a statement,
+// passing through the statement's error
+func setLockTimeout(tx *gorm.DB, d time.Duration) (func(error) error, error) {
+	switch dialect.Of(tx) {
+	case dialect.Postgres:
+		var prev string
+		if err := tx.Raw("SELECT current_setting('lock_timeout')").Scan(&prev).Error; err != nil {
+			return nil, err
+		}
+		ms := (d + time.Millisecond - 1) / time.Millisecond
+		if err := tx.Exec("SELECT set_config('lock_timeout', ?, true)", fmt.Sprintf("%dms", ms)).Error; err != nil {
+			return nil, err
+		}
+		return func(err error) error {
+			// A failed statement aborts the transaction, and rolling it or
+			// the savepoint back discards the local setting anyway
+			if err != nil {
+				return err
+			}
+			return tx.Exec("SELECT set_config('lock_timeout', ?, true)", prev).Error
+		}, nil
+
+	case dialect.MySQL:
+		var prev int
+		if err := tx.Raw("SELECT @@SESSION.innodb_lock_wait_timeout").Scan(&prev).Error; err != nil {
+			return nil, err
+		}
+		secs := (d + time.Second - 1) / time.Second
+		if err := tx.Exec("SET SESSION innodb_lock_wait_timeout = ?", int64(secs)).Error; err != nil {
+			return nil, err
+		}
+		return func(err error) error {
+			// The setting outlives the transaction on the pooled connection
+			if restoreErr := tx.Exec("SET SESSION innodb_lock_wait_timeout = ?", prev).Error; err == nil {
+				err = restoreErr
+			}
+			return err
+		}, nil
+	}
+	return func(err error) error { return err }, nil
+}
+
+// lockError wraps err with ErrLockTimeout when the driver reports a lock
+// wait timeout or a NOWAIT conflict
+func lockError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// pgconn.PgError exposes its SQLSTATE code through this method
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) && pgErr.SQLState() == "55P03" {
+		return fmt.Errorf("%w: %w", ErrLockTimeout, err)
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && (myErr.Number == 1205 || myErr.Number == 3572) {
+		return fmt.Errorf("%w: %w", ErrLockTimeout, err)
+	}
+	return err
+}
+
+// ClaimNext selects the first row matching the condition, in primary key
+// order, with FOR UPDATE SKIP LOCKED, so concurrent workers each claim a
+// different row instead of blocking on one another. It returns nil when no
+// unlocked row matches.
+//
+// The row lock lasts until the surrounding transaction ends, so ClaimNext must
+// run on a repository bound to the transaction that also marks the row as
+// taken. Called outside a transaction it runs in one of its own, which
+// commits and releases the lock as soon as it returns, so another worker can
+// claim the same row before the caller has marked it:
+//
+//	db.Transaction(func(tx *gorm.DB) error {
+//		job, err := repository.New[Job](tx).ClaimNext(ctx, "status = ?", "pending")
+//		if err != nil || job == nil {
+//			return err
+//		}
+//		return tx.Model(job).Update("status", "running").Error
+//	})
+//
+// SKIP LOCKED requires postgres or mysql 8. SQLite has no row locks and GORM
+// drops the locking clause there, so claims are only safe because SQLite
+// serializes write transactions.
+func (r *Repository[T]) ClaimNext(ctx context.Context, query interface{}, args ...interface{}) (*T, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	var entity T
+	err := r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.Locking{
+			Strength: clause.LockingStrengthUpdate,
+			Options:  clause.LockingOptionsSkipLocked,
+		}).Where(query, args...).First(&entity).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// isRetryableTxError reports whether err aborted a transaction as a deadlock
+// victim or for a serialization failure, so that running it again may succeed
+func isRetryableTxError(err error) bool {
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		switch pgErr.SQLState() {
+		case "40P01", "40001":
+			return true
+		}
+	}
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1213
+}
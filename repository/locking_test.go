@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestJob is a test entity used as a queue item
+type TestJob struct {
+	ID     uint   `gorm:"primarykey"`
+	Status string `gorm:"size:20;index"`
+}
+
+func TestClaimNext(t *testing.T) {
+	db := setupTestDB(t, &TestJob{})
+	ctx := context.Background()
+
+	// SQLite serializes writers and has no row locks; a single connection
+	// keeps the in-memory database shared between workers. This only checks
+	// that claims are exclusive, TestClaimNextSkipLocked covers SKIP LOCKED.
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	const jobs = 20
+	for i := 0; i < jobs; i++ {
+		db.Create(&TestJob{Status: "pending"})
+	}
+
+	t.Run("workers never claim the same row", func(t *testing.T) {
+		var mu sync.Mutex
+		claimed := map[uint]int{}
+
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					var job *TestJob
+					err := db.Transaction(func(tx *gorm.DB) error {
+						var err error
+						job, err = New[TestJob](tx).ClaimNext(ctx, "status = ?", "pending")
+						if err != nil || job == nil {
+							return err
+						}
+						return tx.Model(job).Update("status", "running").Error
+					})
+					if err != nil {
+						t.Errorf("Failed to claim job: %v", err)
+						return
+					}
+					if job == nil {
+						return
+					}
+
+					mu.Lock()
+					claimed[job.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(claimed) != jobs {
+			t.Errorf("Expected %d distinct jobs claimed, got %d", jobs, len(claimed))
+		}
+		for id, n := range claimed {
+			if n != 1 {
+				t.Errorf("Job %d claimed %d times", id, n)
+			}
+		}
+	})
+
+	t.Run("returns nil when nothing matches", func(t *testing.T) {
+		job, err := New[TestJob](db).ClaimNext(ctx, "status = ?", "pending")
+		if err != nil {
+			t.Fatalf("ClaimNext failed: %v", err)
+		}
+		if job != nil {
+			t.Errorf("Expected no job, got %+v", job)
+		}
+	})
+}
+
+// TestClaimNextSkipLocked has workers on postgres each hold a claimed row
+// until all of them have claimed one, which only succeeds when claims skip
+// the rows locked by the others instead of waiting for them
+func TestClaimNextSkipLocked(t *testing.T) {
+	dsn := os.Getenv("DB_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("DB_TEST_POSTGRES_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err := db.Migrator().DropTable(&TestJob{}); err != nil {
+		t.Fatalf("DropTable failed: %v", err)
+	}
+	if err := db.AutoMigrate(&TestJob{}); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	t.Cleanup(func() { db.Migrator().DropTable(&TestJob{}) })
+
+	const workers = 4
+	for i := 0; i < workers; i++ {
+		db.Create(&TestJob{Status: "pending"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var held sync.WaitGroup
+	held.Add(workers)
+	allHeld := make(chan struct{})
+	go func() {
+		held.Wait()
+		close(allHeld)
+	}()
+
+	ids := make(chan uint, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				job, err := New[TestJob](tx).ClaimNext(ctx, "status = ?", "pending")
+				if err != nil {
+					return err
+				}
+				if job == nil {
+					return errors.New("no job left to claim")
+				}
+				held.Done()
+				select {
+				case <-allHeld:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				ids <- job.ID
+				return tx.Model(job).Update("status", "running").Error
+			})
+			if err != nil {
+				t.Errorf("Failed to claim job: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	claimed := map[uint]bool{}
+	for id := range ids {
+		if claimed[id] {
+			t.Errorf("Job %d claimed twice", id)
+		}
+		claimed[id] = true
+	}
+	if len(claimed) != workers {
+		t.Errorf("Expected %d distinct jobs claimed, got %d", workers, len(claimed))
+	}
+}
+
+// sqlStateError mimics pgconn.PgError for lockError
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pg error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestFindByIDForUpdate(t *testing.T) {
+	db := setupTestDB(t, &TestJob{})
+	repo := New[TestJob](db)
+	ctx := context.Background()
+
+	job := &TestJob{Status: "pending"}
+	repo.Create(ctx, job)
+
+	for _, opts := range [][]LockOption{nil, {LockTimeout(time.Second)}, {LockTimeout(0)}} {
+		var found TestJob
+		if err := repo.FindByIDForUpdate(ctx, job.ID, &found, opts...); err != nil {
+			t.Fatalf("FindByIDForUpdate failed: %v", err)
+		}
+		if found.ID != job.ID {
+			t.Errorf("Expected job %d, got %d", job.ID, found.ID)
+		}
+	}
+
+	var missing TestJob
+	if err := repo.FindByIDForUpdate(ctx, 999, &missing); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestLockError(t *testing.T) {
+	tests := []struct {
+		err     error
+		timeout bool
+	}{
+		{sqlStateError("55P03"), true},
+		{fmt.Errorf("query: %w", sqlStateError("55P03")), true},
+		{sqlStateError("23505"), false},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{&mysql.MySQLError{Number: 3572, Message: "Statement aborted because lock(s) could not be acquired immediately and NOWAIT is set"}, true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{gorm.ErrRecordNotFound, false},
+	}
+
+	for _, tt := range tests {
+		err := lockError(tt.err)
+		if errors.Is(err, ErrLockTimeout) != tt.timeout {
+			t.Errorf("lockError(%v) = %v, expected timeout %v", tt.err, err, tt.timeout)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Expected %v to wrap %v", err, tt.err)
+		}
+	}
+	if lockError(nil) != nil {
+		t.Error("Expected nil for nil")
+	}
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// WithLogLevel returns a repository whose statements are logged at level,
+// leaving the log level of the underlying *gorm.DB and of other repositories
+// unchanged. Use it to trace one code path, for example logger.Info to see
+// every SQL statement it issues while the service runs at logger.Warn.
+func (r *Repository[T]) WithLogLevel(level logger.LogLevel) *Repository[T] {
+	clone := *r
+	clone.db = r.db.Session(&gorm.Session{Logger: r.db.Logger.LogMode(level)})
+	return &clone
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+)
+
+// levelRecorder is a logger that records the SQL of the statements it would
+// print at its level
+type levelRecorder struct {
+	logger.Interface
+	level logger.LogLevel
+	mu    *sync.Mutex
+	sql   *[]string
+}
+
+func (l levelRecorder) LogMode(level logger.LogLevel) logger.Interface {
+	l.level = level
+	return l
+}
+
+func (l levelRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level < logger.Info {
+		return
+	}
+	sql, _ := fc()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.sql = append(*l.sql, sql)
+}
+
+func TestWithLogLevel(t *testing.T) {
+	db := setupTestDB(t)
+	var statements []string
+	db.Logger = levelRecorder{Interface: logger.Discard, level: logger.Warn, mu: &sync.Mutex{}, sql: &statements}
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	if _, err := repo.FindAll(ctx); err != nil {
+		t.Fatalf("FindAll failed: %v", err)
+	}
+	if len(statements) != 0 {
+		t.Fatalf("Expected nothing logged at Warn, got %v", statements)
+	}
+
+	if _, err := repo.WithLogLevel(logger.Info).FindAll(ctx); err != nil {
+		t.Fatalf("FindAll failed: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("Expected the query to be logged at Info, got %v", statements)
+	}
+
+	if _, err := repo.FindAll(ctx); err != nil {
+		t.Fatalf("FindAll failed: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Errorf("Expected the original repository to keep logging at Warn, got %v", statements)
+	}
+}
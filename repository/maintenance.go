@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm/clause"
+)
+
+// Analyze refreshes the planner statistics of the repository's table, which
+// is worth doing after bulk imports so query plans match the new data. It runs
+// ANALYZE on postgres and sqlite and ANALYZE TABLE on mysql. It is best-effort
+// and does nothing on other drivers.
+func (r *Repository[T]) Analyze(ctx context.Context) error {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+
+	table := clause.Table{Name: s.Table}
+	switch r.Dialect() {
+	case dialect.Postgres, dialect.SQLite:
+		return r.conn(ctx).Exec("ANALYZE ?", table).Error
+	case dialect.MySQL:
+		return r.conn(ctx).Exec("ANALYZE TABLE ?", table).Error
+	}
+	return r.err
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	if err := repo.Analyze(ctx); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var count int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = ?", "test_users").Scan(&count)
+	if count == 0 {
+		t.Error("Expected statistics for test_users")
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Option configures a Repository. An invalid option makes every call on the
+// repository fail with the validation error.
+type Option func(*options)
+
+type options struct {
+	batchSize     int
+	deleteArchive bool
+	versioning    bool
+	keepUpdatedAt bool
+	err           error
+
+	largeResultThreshold int
+	onLargeResult        func(ctx context.Context, table string, rows int)
+
+	onChange ChangeHandler
+
+	warnMissingDeadline bool
+}
+
+// WithDeleteArchive makes Delete and DeleteByID copy every row they remove
+// physically into the <table>_archive table, in the same transaction as the
+// delete. Create the archive table with EnableDeleteArchive first.
+func WithDeleteArchive() Option {
+	return func(o *options) {
+		o.deleteArchive = true
+	}
+}
+
+// WithVersioning makes Update, and the methods built on it such as
+// UpdateIfChanged, copy the row being replaced into the <table>_history table
+// as a new version, in the same transaction as the update. Create the history
+// table with EnableVersioning first. Every update stores a full copy of the
+// prior row, so the history table grows by one row per update and is never
+// pruned automatically.
+func WithVersioning() Option {
+	return func(o *options) {
+		o.versioning = true
+	}
+}
+
+// WithChangeTracking makes Update, and the methods built on it such as
+// UpdateIfChanged, pass the columns it changes with their old and new values
+// to handler, inside the transaction of the update, e.g. to write a detailed
+// audit log. Capturing the old values costs an extra SELECT, with FOR UPDATE,
+// of the stored row before every update. An error from handler rolls the
+// update back. Updates that change nothing but the auto-updated timestamp,
+// and Updates that insert a new row, do not call handler.
+func WithChangeTracking(handler ChangeHandler) Option {
+	return func(o *options) {
+		if handler == nil {
+			o.err = errors.New("change handler cannot be nil")
+			return
+		}
+		o.onChange = handler
+	}
+}
+
+// WithoutAutoUpdatedAt makes UpdateColumns and UpdateWhere leave the
+// auto-updated timestamp alone unless their values set it, as GORM's own
+// UpdateColumns does, e.g. for backfills that must not look like edits
+func WithoutAutoUpdatedAt() Option {
+	return func(o *options) {
+		o.keepUpdatedAt = true
+	}
+}
+
+// WithDefaultBatchSize sets the batch size used by batch operations such as
+// FindEach when a call passes zero, instead of the package default of 100.
+// Calls that pass a positive batch size keep it. n must be positive.
+func WithDefaultBatchSize(n int) Option {
+	return func(o *options) {
+		if n <= 0 {
+			o.err = fmt.Errorf("default batch size must be positive, got %d", n)
+			return
+		}
+		o.batchSize = n
+	}
+}
+
+// WithLargeResultWarning makes FindAll, FindAllOrdered and
+// FindAllWithPreloads log a warning through the GORM logger whenever they
+// return more than threshold rows, so unbounded reads that would eventually
+// load a whole table show up in production logs before they run out of
+// memory. The rows are still returned. onExceed, when not nil, is called
+// too, for example to increment a metric. threshold must be positive.
+func WithLargeResultWarning(threshold int, onExceed func(ctx context.Context, table string, rows int)) Option {
+	return func(o *options) {
+		if threshold <= 0 {
+			o.err = fmt.Errorf("large result threshold must be positive, got %d", threshold)
+			return
+		}
+		o.largeResultThreshold = threshold
+		o.onLargeResult = onExceed
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// Order sorts results by one column of T. Pass it to FindWhere among the
+// condition arguments:
+//
+//	repo.FindWhere(ctx, "status = ?", "active", repository.Order{Column: "CreatedAt", Desc: true})
+//
+// Column is the only part of an Order that reaches the SQL, and only after it
+// has been resolved against T's schema: it must name a field or column of T,
+// or the query fails with ErrInvalidColumn, and the resolved column name is
+// quoted as an identifier by the dialect. Desc is a boolean and cannot
+// inject anything. It is therefore safe to fill Column from user input such
+// as a sort query parameter, although an allow-list of sortable columns in
+// the caller still avoids sorting by unindexed columns.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+// orderBy resolves orders to an ORDER BY of quoted columns of T
+func (r *Repository[T]) orderBy(orders []Order) (clause.OrderBy, error) {
+	columns := make([]clause.OrderByColumn, len(orders))
+	for i, o := range orders {
+		col, err := r.column(o.Column)
+		if err != nil {
+			return clause.OrderBy{}, err
+		}
+		columns[i] = clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: col}, Desc: o.Desc}
+	}
+	return clause.OrderBy{Columns: columns}, nil
+}
+
+// splitOrders separates the Order values in args from the condition arguments
+func splitOrders(args []interface{}) ([]interface{}, []Order) {
+	var orders []Order
+	conds := args[:0:0]
+	for _, arg := range args {
+		if o, ok := arg.(Order); ok {
+			orders = append(orders, o)
+		} else {
+			conds = append(conds, arg)
+		}
+	}
+	return conds, orders
+}
+
+// FindAllOrdered finds all records sorted by orderBy, a comma-separated list
+// of field or column names of T, each optionally followed by ASC or DESC,
+// such as "last_name, created_at DESC". Names are validated and quoted like
+// Order columns; anything else in orderBy fails with ErrInvalidColumn or an
+// invalid sort direction error rather than reaching the SQL. Large results
+// are reported like those of FindAll.
+func (r *Repository[T]) FindAllOrdered(ctx context.Context, orderBy string) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	order, err := r.orderList(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findAll(ctx, r.conn(ctx).Order(order))
+}
+
+// orderList resolves a comma-separated orderBy of FindAllOrdered
+func (r *Repository[T]) orderList(orderBy string) (clause.OrderBy, error) {
+	parts := strings.Split(orderBy, ",")
+	columns := make([]clause.OrderByColumn, len(parts))
+	for i, part := range parts {
+		order, err := r.orderByColumn(part)
+		if err != nil {
+			return clause.OrderBy{}, err
+		}
+		columns[i] = order
+	}
+	return clause.OrderBy{Columns: columns}, nil
+}
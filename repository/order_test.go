@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestOrder(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	for _, u := range []TestUser{
+		{Name: "Bea", Email: "bea@example.com", Age: 30},
+		{Name: "Al", Email: "al@example.com", Age: 30},
+		{Name: "Cy", Email: "cy@example.com", Age: 25},
+	} {
+		repo.Create(ctx, &u)
+	}
+	names := func(users []TestUser) []string {
+		var out []string
+		for _, u := range users {
+			out = append(out, u.Name)
+		}
+		return out
+	}
+
+	t.Run("find all ordered by several columns", func(t *testing.T) {
+		users, err := repo.FindAllOrdered(ctx, "age DESC, Name")
+		if err != nil {
+			t.Fatalf("FindAllOrdered failed: %v", err)
+		}
+		if got := names(users); len(got) != 3 || got[0] != "Al" || got[1] != "Bea" || got[2] != "Cy" {
+			t.Errorf("Expected [Al Bea Cy], got %v", got)
+		}
+	})
+
+	t.Run("find where with orders among the arguments", func(t *testing.T) {
+		users, err := repo.FindWhere(ctx, "age = ?", 30, Order{Column: "Name", Desc: true})
+		if err != nil {
+			t.Fatalf("FindWhere failed: %v", err)
+		}
+		if got := names(users); len(got) != 2 || got[0] != "Bea" || got[1] != "Al" {
+			t.Errorf("Expected [Bea Al], got %v", got)
+		}
+	})
+
+	t.Run("rejects anything but columns of the model", func(t *testing.T) {
+		if _, err := repo.FindAllOrdered(ctx, "name; DROP TABLE test_users"); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.FindAllOrdered(ctx, "name SIDEWAYS"); err == nil {
+			t.Error("Expected an error for an invalid sort direction")
+		}
+		if _, err := repo.FindWhere(ctx, "age > ?", 0, Order{Column: "(SELECT 1)"}); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if count, _ := repo.Count(ctx); count != 3 {
+			t.Errorf("Expected the table to be intact, got %d records", count)
+		}
+	})
+	t.Run("warns about large ordered results", func(t *testing.T) {
+		warnings := &warnRecorder{Interface: logger.Discard}
+		warned := New[TestUser](db.Session(&gorm.Session{Logger: warnings}), WithLargeResultWarning(2, nil))
+		if _, err := warned.FindAllOrdered(ctx, "name"); err != nil {
+			t.Fatalf("FindAllOrdered failed: %v", err)
+		}
+		if len(warnings.messages) != 1 || !strings.Contains(warnings.messages[0], "test_users returned 3 rows") {
+			t.Errorf("Expected one warning naming the table and row count, got %v", warnings.messages)
+		}
+	})
+}
@@ -0,0 +1,437 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned by PaginateCursor for a cursor it did not
+// issue, or issued for a different order column
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PaginateOption configures Paginate
+type PaginateOption func(*paginateOptions)
+
+type paginateOptions struct {
+	singleQuery bool
+	skipCount   bool
+	orders      []clause.Expression
+	query       interface{}
+	args        []interface{}
+}
+
+// filter applies the PaginateQuery condition to the page and count queries
+func (o paginateOptions) filter(tx *gorm.DB) *gorm.DB {
+	if isEmptyCondition(o.query) {
+		return tx
+	}
+	return tx.Where(o.query, o.args...)
+}
+
+// order applies the requested ORDER BY expressions to the page query
+func (o paginateOptions) order(tx *gorm.DB) *gorm.DB {
+	if len(o.orders) == 0 {
+		return tx
+	}
+	return tx.Order(clause.OrderBy{Expression: clause.CommaExpression{Exprs: o.orders}})
+}
+
+// SingleQuery makes Paginate fetch the page and the total in one round-trip
+// using COUNT(*) OVER() on postgres. The window function still visits every
+// matching row, so this only saves the network round-trip; other drivers keep
+// the separate COUNT query. When the page is past the last row no total comes
+// back with it and a COUNT query is issued after all.
+func SingleQuery() PaginateOption {
+	return func(o *paginateOptions) {
+		o.singleQuery = true
+	}
+}
+
+// SkipCount makes Paginate and PaginateResult leave out the COUNT query, for
+// infinite-scroll listings that never show a total. The total is then
+// reported as -1. It takes precedence over SingleQuery.
+func SkipCount() PaginateOption {
+	return func(o *paginateOptions) {
+		o.skipCount = true
+	}
+}
+
+// OrderByExpr orders the page by a raw, parameterized SQL expression, such as
+// a relevance score for search results:
+//
+//	repo.Paginate(ctx, 1, 20, repository.OrderByExpr("ts_rank(search, to_tsquery(?)) DESC", q))
+//
+// Unlike column names elsewhere in the repository the expression is not
+// validated and is inserted into the query as is, so it must come from
+// trusted code; pass user input only through args. Repeated options order by
+// each expression in turn.
+func OrderByExpr(expr string, args ...interface{}) PaginateOption {
+	return func(o *paginateOptions) {
+		o.orders = append(o.orders, clause.Expr{SQL: expr, Vars: args})
+	}
+}
+
+// pageRow carries a row together with the window-function total
+type pageRow[T any] struct {
+	Row   T     `gorm:"embedded"`
+	Total int64 `gorm:"column:paginate_total"`
+}
+
+// PaginateQuery describes a page for PaginateWith
+type PaginateQuery struct {
+	Page     int
+	PageSize int
+	// OrderBy sorts the page, in the form of FindAllOrdered such as
+	// "created_at DESC, id". It comes before any OrderByExpr option. Empty
+	// leaves the order to the database.
+	OrderBy string
+	// Where and Args restrict both the page and the total, like a FindWhere
+	// condition. A nil Where matches every record.
+	Where interface{}
+	Args  []interface{}
+}
+
+// Paginate returns paginated results and the total number of records, or -1
+// for the total with SkipCount
+func (r *Repository[T]) Paginate(ctx context.Context, page, pageSize int, opts ...PaginateOption) ([]T, int64, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.PaginateWith(ctx, PaginateQuery{Page: page, PageSize: pageSize}, opts...)
+}
+
+// PaginateWith returns the page described by q together with the number of
+// records matching q.Where, or -1 for the total with SkipCount. A condition
+// with an IN on an empty slice returns an empty page and a total of 0 without
+// querying.
+func (r *Repository[T]) PaginateWith(ctx context.Context, q PaginateQuery, opts ...PaginateOption) ([]T, int64, error) {
+	ctx = r.checkDeadline(ctx)
+	var o paginateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if q.OrderBy != "" {
+		order, err := r.orderList(q.OrderBy)
+		if err != nil {
+			return nil, 0, err
+		}
+		o.orders = append([]clause.Expression{order}, o.orders...)
+	}
+	if hasEmptyIn(q.Where, q.Args) {
+		return []T{}, 0, r.err
+	}
+	o.query, o.args = q.Where, q.Args
+
+	offset := (q.Page - 1) * q.PageSize
+	if o.skipCount {
+		var entities []T
+		err := o.order(o.filter(r.conn(ctx))).Offset(offset).Limit(q.PageSize).Find(&entities).Error
+		return entities, -1, err
+	}
+	if o.singleQuery && r.Dialect() == dialect.Postgres {
+		return r.paginateSingleQuery(ctx, offset, q.PageSize, o)
+	}
+
+	var entities []T
+	var total int64
+	var entity T
+
+	// Get total count
+	if err := o.filter(r.conn(ctx)).Model(&entity).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	err := o.order(o.filter(r.conn(ctx))).Offset(offset).Limit(q.PageSize).Find(&entities).Error
+
+	return entities, total, err
+}
+
+// PageResult is a page of records with the position details a listing needs
+type PageResult[T any] struct {
+	Items    []T
+	Page     int
+	PageSize int
+	// Total is the number of matching records, or -1 when counting was
+	// skipped with SkipCount
+	Total int64
+	// TotalPages is the number of pages of PageSize records. With SkipCount
+	// the total is unknown and it counts the pages up to the last one known
+	// to hold records, which is this page or the next when HasNext is set,
+	// so it is only a lower bound.
+	TotalPages int
+	// HasNext reports whether a page follows this one
+	HasNext bool
+}
+
+// PaginateResult returns the page like Paginate, together with the number of
+// pages and whether a next page exists. page and pageSize must be positive.
+// With SkipCount no COUNT query is issued: the page is fetched with one extra
+// row, which tells whether another page follows, and Total is -1.
+func (r *Repository[T]) PaginateResult(ctx context.Context, page, pageSize int, opts ...PaginateOption) (PageResult[T], error) {
+	ctx = r.checkDeadline(ctx)
+	result := PageResult[T]{Page: page, PageSize: pageSize}
+	if page < 1 || pageSize < 1 {
+		return result, fmt.Errorf("page and page size must be positive, got %d and %d", page, pageSize)
+	}
+
+	var o paginateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.skipCount {
+		var entities []T
+		err := o.order(r.conn(ctx)).Offset((page - 1) * pageSize).Limit(pageSize + 1).Find(&entities).Error
+		if err != nil {
+			return result, err
+		}
+		result.Items, result.Total = entities, -1
+		switch {
+		case len(entities) > pageSize:
+			result.Items, result.HasNext, result.TotalPages = entities[:pageSize], true, page+1
+		case len(entities) > 0:
+			result.TotalPages = page
+		default:
+			result.TotalPages = page - 1
+		}
+		return result, nil
+	}
+
+	entities, total, err := r.Paginate(ctx, page, pageSize, opts...)
+	if err != nil {
+		return result, err
+	}
+	result.Items, result.Total = entities, total
+	result.TotalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	result.HasNext = int64(page)*int64(pageSize) < total
+	return result, nil
+}
+
+// paginateSingleQuery selects a page plus COUNT(*) OVER() as the total
+func (r *Repository[T]) paginateSingleQuery(ctx context.Context, offset, limit int, o paginateOptions) ([]T, int64, error) {
+	var rows []pageRow[T]
+	err := o.order(o.filter(r.conn(ctx))).
+		Model(new(T)).
+		Select("?.*, COUNT(*) OVER() AS paginate_total", clause.Table{Name: clause.CurrentTable}).
+		Offset(offset).
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(rows) == 0 {
+		var total int64
+		err := o.filter(r.conn(ctx)).Model(new(T)).Count(&total).Error
+		return []T{}, total, err
+	}
+
+	entities := make([]T, len(rows))
+	for i := range rows {
+		entities[i] = rows[i].Row
+	}
+	return entities, rows[0].Total, nil
+}
+
+// Keyset describes a keyset (seek) pagination position
+type Keyset struct {
+	// Columns are the sort columns, e.g. created_at, id. Rows are ordered by
+	// all of them, so the list should end with a unique column to break ties
+	// deterministically. Add a composite index on the same columns, in the
+	// same order, or the database has to sort the whole table for each page.
+	Columns []string
+	// After holds the Columns values of the last row of the previous page,
+	// as returned by PaginateKeyset, or is empty for the first page
+	After []interface{}
+	// Desc sorts every column in descending order
+	Desc bool
+}
+
+// PaginateKeyset returns up to limit records following the keyset position,
+// using a row-value comparison such as (created_at, id) > (?, ?) instead of
+// an OFFSET. next holds the Columns values of the last returned row to pass
+// as After for the following page, and is nil once the last page is reached.
+func (r *Repository[T]) PaginateKeyset(ctx context.Context, keyset Keyset, limit int) (items []T, next []interface{}, err error) {
+	ctx = r.checkDeadline(ctx)
+	if len(keyset.Columns) == 0 {
+		return nil, nil, errors.New("keyset columns cannot be empty")
+	}
+	if len(keyset.After) != 0 && len(keyset.After) != len(keyset.Columns) {
+		return nil, nil, fmt.Errorf("keyset has %d columns but %d values", len(keyset.Columns), len(keyset.After))
+	}
+	if limit <= 0 {
+		return nil, nil, errors.New("limit must be positive")
+	}
+
+	cols, err := r.columns(keyset.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := r.conn(ctx)
+	if len(keyset.After) > 0 {
+		tx = tx.Where(keysetCondition(cols, keyset.After, keyset.Desc))
+	}
+	for _, col := range cols {
+		tx = tx.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: col},
+			Desc:   keyset.Desc,
+		})
+	}
+
+	if err := tx.Limit(limit).Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(items) < limit {
+		return items, nil, nil
+	}
+
+	next, err = r.keysetValues(ctx, &items[len(items)-1], cols)
+	return items, next, err
+}
+
+// keysetCondition builds (c1, c2, ...) > (?, ?, ...), or < when desc
+func keysetCondition(cols []string, after []interface{}, desc bool) clause.Expression {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	vars := make([]interface{}, 0, 2*len(cols))
+	for _, col := range cols {
+		vars = append(vars, clause.Column{Table: clause.CurrentTable, Name: col})
+	}
+	vars = append(vars, after...)
+
+	if len(cols) == 1 {
+		return clause.Expr{SQL: "? " + op + " ?", Vars: vars}
+	}
+	return clause.Expr{SQL: "(" + placeholders + ") " + op + " (" + placeholders + ")", Vars: vars}
+}
+
+// keysetValues reads the values of cols from entity
+func (r *Repository[T]) keysetValues(ctx context.Context, entity *T, cols []string) ([]interface{}, error) {
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(entity).Elem()
+	values := make([]interface{}, len(cols))
+	for i, col := range cols {
+		values[i], _ = s.LookUpField(col).ValueOf(ctx, rv)
+	}
+	return values, nil
+}
+
+// cursor is the decoded form of a PaginateCursor cursor
+type cursor struct {
+	Order  string            `json:"o"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// PaginateCursor returns up to limit records sorted by orderColumn, a field or
+// column name of T optionally followed by ASC or DESC, starting after the
+// position encoded in cursor. An empty cursor starts at the first page.
+// nextCursor is an opaque base64 string to pass for the following page, and
+// is empty once a page comes back short; a full last page is followed by an
+// empty one.
+//
+// Ties on a non-unique orderColumn are broken by the primary key, sorted in
+// the same direction, so rows sharing a value are neither skipped nor
+// repeated across pages. Like PaginateKeyset it seeks with a row-value
+// comparison instead of an OFFSET, so an index on (orderColumn, primary key)
+// keeps every page fast. A cursor only fits the orderColumn it was issued
+// for; any other cursor fails with ErrInvalidCursor.
+func (r *Repository[T]) PaginateCursor(ctx context.Context, cursor string, limit int, orderColumn string) (items []T, nextCursor string, err error) {
+	ctx = r.checkDeadline(ctx)
+	order, err := r.orderByColumn(orderColumn)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := r.schema()
+	if err != nil {
+		return nil, "", err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyset := Keyset{Columns: []string{order.Column.Name}, Desc: order.Desc}
+	if pk.DBName != order.Column.Name {
+		keyset.Columns = append(keyset.Columns, pk.DBName)
+	}
+	key := order.Column.Name
+	if order.Desc {
+		key += " DESC"
+	}
+	if cursor != "" {
+		if keyset.After, err = decodeCursor(s.LookUpField, cursor, key, keyset.Columns); err != nil {
+			return nil, "", err
+		}
+	}
+
+	items, next, err := r.PaginateKeyset(ctx, keyset, limit)
+	if err != nil || next == nil {
+		return items, "", err
+	}
+	nextCursor, err = encodeCursor(key, next)
+	return items, nextCursor, err
+}
+
+// encodeCursor encodes the order key and the keyset values of the last row
+func encodeCursor(key string, values []interface{}) (string, error) {
+	c := cursor{Order: key, Values: make([]json.RawMessage, len(values))}
+	for i, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode cursor: %w", err)
+		}
+		c.Values[i] = raw
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor issued for key, converting each value to the
+// type of its column's field so that it compares as that type
+func decodeCursor(lookUp func(string) *schema.Field, encoded, key string, cols []string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.Order != key {
+		return nil, fmt.Errorf("%w: issued for order %q, not %q", ErrInvalidCursor, c.Order, key)
+	}
+	if len(c.Values) != len(cols) {
+		return nil, fmt.Errorf("%w: has %d values for %d columns", ErrInvalidCursor, len(c.Values), len(cols))
+	}
+
+	values := make([]interface{}, len(cols))
+	for i, col := range cols {
+		v := reflect.New(lookUp(col).FieldType)
+		if err := json.Unmarshal(c.Values[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
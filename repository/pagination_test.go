@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaginateSingleQuery(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 15)
+
+	t.Run("falls back to two queries on sqlite", func(t *testing.T) {
+		users, total, err := repo.Paginate(ctx, 2, 5, SingleQuery())
+		if err != nil {
+			t.Fatalf("Failed to paginate: %v", err)
+		}
+		if len(users) != 5 || total != 15 {
+			t.Errorf("Expected 5 users and total 15, got %d and %d", len(users), total)
+		}
+	})
+
+	// SQLite supports window functions, so the postgres code path can be
+	// exercised directly
+	t.Run("returns page and total in one query", func(t *testing.T) {
+		users, total, err := repo.paginateSingleQuery(ctx, 5, 5, paginateOptions{})
+		if err != nil {
+			t.Fatalf("Failed to paginate: %v", err)
+		}
+		if len(users) != 5 {
+			t.Errorf("Expected 5 users, got %d", len(users))
+		}
+		if users[0].ID != 6 {
+			t.Errorf("Expected page to start at user 6, got %d", users[0].ID)
+		}
+		if total != 15 {
+			t.Errorf("Expected total 15, got %d", total)
+		}
+	})
+
+	t.Run("reports total past the last page", func(t *testing.T) {
+		users, total, err := repo.paginateSingleQuery(ctx, 45, 5, paginateOptions{})
+		if err != nil {
+			t.Fatalf("Failed to paginate: %v", err)
+		}
+		if len(users) != 0 {
+			t.Errorf("Expected no users, got %d", len(users))
+		}
+		if total != 15 {
+			t.Errorf("Expected total 15, got %d", total)
+		}
+	})
+}
+
+// BenchmarkPaginate compares the two pagination strategies on a 10k row table.
+// SQLite runs in-process, so this measures query cost without the network
+// round-trip that SingleQuery saves on postgres.
+func BenchmarkPaginate(b *testing.B) {
+	db := setupTestDB(b)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	users := make([]TestUser, 10000)
+	for i := range users {
+		users[i] = TestUser{Name: "User", Email: fmt.Sprintf("bench%d@example.com", i), Age: i % 90}
+	}
+	if err := db.CreateInBatches(users, 500).Error; err != nil {
+		b.Fatalf("Failed to seed users: %v", err)
+	}
+
+	b.Run("two queries", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := repo.Paginate(ctx, 50, 20); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("single query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := repo.paginateSingleQuery(ctx, 980, 20, paginateOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestPaginateKeyset(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	// Ages repeat so the id column has to break ties
+	for i := 1; i <= 10; i++ {
+		repo.Create(ctx, &TestUser{Name: "User", Email: fmt.Sprintf("keyset%d@example.com", i), Age: 20 + i%3})
+	}
+
+	collect := func(t *testing.T, keyset Keyset, limit int) []uint {
+		t.Helper()
+
+		var ids []uint
+		for {
+			page, next, err := repo.PaginateKeyset(ctx, keyset, limit)
+			if err != nil {
+				t.Fatalf("PaginateKeyset failed: %v", err)
+			}
+			for _, user := range page {
+				ids = append(ids, user.ID)
+			}
+			if next == nil {
+				return ids
+			}
+			keyset.After = next
+		}
+	}
+
+	t.Run("walks every row once with a composite key", func(t *testing.T) {
+		ids := collect(t, Keyset{Columns: []string{"age", "id"}}, 3)
+
+		want := []uint{3, 6, 9, 1, 4, 7, 10, 2, 5, 8}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, ids)
+		}
+	})
+
+	t.Run("walks in descending order", func(t *testing.T) {
+		ids := collect(t, Keyset{Columns: []string{"age", "id"}, Desc: true}, 4)
+
+		want := []uint{8, 5, 2, 10, 7, 4, 1, 9, 6, 3}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, ids)
+		}
+	})
+
+	t.Run("rejects mismatched values", func(t *testing.T) {
+		_, _, err := repo.PaginateKeyset(ctx, Keyset{Columns: []string{"age", "id"}, After: []interface{}{21}}, 3)
+		if err == nil {
+			t.Error("Expected error for mismatched keyset values")
+		}
+	})
+}
+
+func TestPaginateOrderByExpr(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 5)
+
+	// Distance from age 23 as a stand-in for a relevance score
+	users, total, err := repo.Paginate(ctx, 1, 3, OrderByExpr("ABS(age - ?)", 23), OrderByExpr("id"))
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("Expected total 5, got %d", total)
+	}
+
+	var ages []int
+	for _, user := range users {
+		ages = append(ages, user.Age)
+	}
+	if len(ages) != 3 || ages[0] != 23 || ages[1] != 22 || ages[2] != 24 {
+		t.Errorf("Expected ages [23 22 24], got %v", ages)
+	}
+
+	var o paginateOptions
+	OrderByExpr("age DESC")(&o)
+	users, _, err = repo.paginateSingleQuery(ctx, 0, 1, o)
+	if err != nil {
+		t.Fatalf("paginateSingleQuery failed: %v", err)
+	}
+	if len(users) != 1 || users[0].Age != 25 {
+		t.Errorf("Expected the oldest user first, got %+v", users)
+	}
+}
+
+func TestPaginateResult(t *testing.T) {
+	var statements []string
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 12)
+	db.Logger = sqlRecorder{Interface: db.Logger, record: func(sql string) { statements = append(statements, sql) }}
+
+	t.Run("reports pages from the total", func(t *testing.T) {
+		result, err := repo.PaginateResult(ctx, 2, 5)
+		if err != nil {
+			t.Fatalf("PaginateResult failed: %v", err)
+		}
+		if len(result.Items) != 5 || result.Total != 12 || result.TotalPages != 3 || !result.HasNext {
+			t.Errorf("Expected 5 items of 12 in 3 pages with a next page, got %+v", result)
+		}
+
+		last, _ := repo.PaginateResult(ctx, 3, 5)
+		if len(last.Items) != 2 || last.HasNext {
+			t.Errorf("Expected 2 items and no next page, got %+v", last)
+		}
+	})
+
+	t.Run("skips the count", func(t *testing.T) {
+		statements = nil
+		result, err := repo.PaginateResult(ctx, 2, 5, SkipCount())
+		if err != nil {
+			t.Fatalf("PaginateResult failed: %v", err)
+		}
+		if len(statements) != 1 || strings.Contains(statements[0], "COUNT") {
+			t.Errorf("Expected a single query without COUNT, got %v", statements)
+		}
+		if len(result.Items) != 5 || result.Total != -1 || !result.HasNext || result.TotalPages != 3 {
+			t.Errorf("Expected 5 items, total -1, a next page and at least 3 pages, got %+v", result)
+		}
+
+		last, _ := repo.PaginateResult(ctx, 3, 5, SkipCount())
+		if len(last.Items) != 2 || last.HasNext || last.TotalPages != 3 {
+			t.Errorf("Expected 2 items, no next page and 3 pages, got %+v", last)
+		}
+
+		users, total, err := repo.Paginate(ctx, 1, 5, SkipCount())
+		if err != nil || len(users) != 5 || total != -1 {
+			t.Errorf("Expected Paginate to return 5 users and total -1, got %d, %d, %v", len(users), total, err)
+		}
+	})
+
+	t.Run("validates page and page size", func(t *testing.T) {
+		if _, err := repo.PaginateResult(ctx, 0, 5); err == nil {
+			t.Error("Expected error for page 0")
+		}
+		if _, err := repo.PaginateResult(ctx, 1, 0); err == nil {
+			t.Error("Expected error for page size 0")
+		}
+	})
+}
+
+func TestPaginateWith(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 15)
+
+	t.Run("filters the page and the total", func(t *testing.T) {
+		q := PaginateQuery{Page: 2, PageSize: 3, OrderBy: "age DESC", Where: "age > ?", Args: []interface{}{28}}
+		users, total, err := repo.PaginateWith(ctx, q)
+		if err != nil {
+			t.Fatalf("PaginateWith failed: %v", err)
+		}
+		if total != 7 {
+			t.Errorf("Expected total 7, got %d", total)
+		}
+		if len(users) != 3 || users[0].Age != 32 || users[2].Age != 30 {
+			t.Errorf("Expected ages 32 to 30, got %+v", users)
+		}
+	})
+
+	t.Run("applies the condition with SingleQuery", func(t *testing.T) {
+		o := paginateOptions{query: "age > ?", args: []interface{}{28}}
+		users, total, err := repo.paginateSingleQuery(ctx, 24, 3, o)
+		if err != nil || len(users) != 0 || total != 7 {
+			t.Errorf("Expected no users and total 7, got %d, %d and %v", len(users), total, err)
+		}
+	})
+
+	t.Run("short-circuits an empty IN", func(t *testing.T) {
+		users, total, err := repo.PaginateWith(ctx, PaginateQuery{Page: 1, PageSize: 3, Where: "id IN ?", Args: []interface{}{[]uint{}}})
+		if err != nil || len(users) != 0 || total != 0 {
+			t.Errorf("Expected an empty page, got %d, %d and %v", len(users), total, err)
+		}
+	})
+
+	t.Run("validates the order", func(t *testing.T) {
+		_, _, err := repo.PaginateWith(ctx, PaginateQuery{Page: 1, PageSize: 3, OrderBy: "age; DROP TABLE test_users"})
+		if err == nil {
+			t.Error("Expected an invalid order to fail")
+		}
+	})
+}
+
+func TestPaginateCursor(t *testing.T) {
+	db := setupTestDB(t, &TestUser{}, &TestArticle{})
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	// Ages repeat so the primary key has to break ties
+	for i := 1; i <= 10; i++ {
+		repo.Create(ctx, &TestUser{Name: "User", Email: fmt.Sprintf("cursor%d@example.com", i), Age: 20 + i%3})
+	}
+
+	collect := func(t *testing.T, limit int, orderColumn string) []uint {
+		t.Helper()
+
+		var ids []uint
+		cursor := ""
+		for {
+			page, next, err := repo.PaginateCursor(ctx, cursor, limit, orderColumn)
+			if err != nil {
+				t.Fatalf("PaginateCursor failed: %v", err)
+			}
+			for _, user := range page {
+				ids = append(ids, user.ID)
+			}
+			if next == "" {
+				return ids
+			}
+			cursor = next
+		}
+	}
+
+	t.Run("walks every row once", func(t *testing.T) {
+		want := []uint{3, 6, 9, 1, 4, 7, 10, 2, 5, 8}
+		if ids := collect(t, 3, "Age"); fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, ids)
+		}
+	})
+
+	t.Run("walks in descending order", func(t *testing.T) {
+		want := []uint{8, 5, 2, 10, 7, 4, 1, 9, 6, 3}
+		if ids := collect(t, 5, "age DESC"); fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Expected %v, got %v", want, ids)
+		}
+	})
+
+	t.Run("round-trips time values", func(t *testing.T) {
+		articles := New[TestArticle](db)
+		base := time.Now()
+		for i := 0; i < 5; i++ {
+			articles.Create(ctx, &TestArticle{Title: fmt.Sprint(i), CreatedAt: base.Add(time.Duration(i) * time.Second)})
+		}
+
+		var titles []string
+		cursor := ""
+		for {
+			page, next, err := articles.PaginateCursor(ctx, cursor, 2, "created_at DESC")
+			if err != nil {
+				t.Fatalf("PaginateCursor failed: %v", err)
+			}
+			for _, article := range page {
+				titles = append(titles, article.Title)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if got := strings.Join(titles, ""); got != "43210" {
+			t.Errorf("Expected 43210, got %s", got)
+		}
+	})
+
+	t.Run("rejects foreign cursors", func(t *testing.T) {
+		_, next, err := repo.PaginateCursor(ctx, "", 3, "age")
+		if err != nil || next == "" {
+			t.Fatalf("Expected a next cursor, got %q and %v", next, err)
+		}
+		if _, _, err := repo.PaginateCursor(ctx, next, 3, "age DESC"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for another order, got %v", err)
+		}
+		if _, _, err := repo.PaginateCursor(ctx, "not a cursor", 3, "age"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+		if _, _, err := repo.PaginateCursor(ctx, "", 3, "missing"); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
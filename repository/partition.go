@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownPartition is returned when a partition named with WithPartition
+// does not exist
+var ErrUnknownPartition = errors.New("unknown partition")
+
+// WithPartition returns a repository whose statements target one partition
+// of T's table, named after the table and suffix, so that WithPartition
+// ("2024_06") on events reads and writes events_2024_06. It is meant for
+// postgres declarative partitioning: inserting into the partition directly
+// skips routing through the parent, and queries scan that partition alone
+// without relying on partition pruning. Rows outside the partition's bounds
+// are rejected by postgres.
+//
+// Every call on the returned repository fails with ErrUnknownPartition when
+// the partition table does not exist. The delete archive of
+// EnableDeleteArchive and the history of EnableVersioning are shared by all
+// partitions: rows deleted or replaced in the partition are copied to the
+// tables of the parent table.
+func (r *Repository[T]) WithPartition(suffix string) *Repository[T] {
+	s, err := r.schema()
+	if err != nil {
+		return r.withError(err)
+	}
+	if suffix == "" {
+		return r.withError(fmt.Errorf("%w: empty partition suffix", ErrUnknownPartition))
+	}
+	partition := s.Table + "_" + suffix
+	if !r.db.Migrator().HasTable(partition) {
+		return r.withError(fmt.Errorf("%w: %q", ErrUnknownPartition, partition))
+	}
+	return r.withScope(func(tx *gorm.DB) *gorm.DB {
+		return tx.Table(partition)
+	})
+}
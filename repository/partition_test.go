@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithPartition(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Table("test_users_2024_06").AutoMigrate(&TestUser{}); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	partition := repo.WithPartition("2024_06")
+	user := TestUser{Name: "June", Email: "june@example.com", Age: 30}
+	if err := partition.Create(ctx, &user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	t.Run("writes to the partition table", func(t *testing.T) {
+		var count int64
+		db.Table("test_users_2024_06").Count(&count)
+		if count != 1 {
+			t.Errorf("Expected 1 row in the partition, got %d", count)
+		}
+		if count, _ := repo.Count(ctx); count != 0 {
+			t.Errorf("Expected the base table to stay empty, got %d rows", count)
+		}
+	})
+
+	t.Run("reads from the partition table", func(t *testing.T) {
+		var found TestUser
+		if err := partition.FindByID(ctx, user.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if found.Email != "june@example.com" {
+			t.Errorf("Expected june@example.com, got %s", found.Email)
+		}
+	})
+
+	t.Run("validates the partition exists", func(t *testing.T) {
+		if _, err := repo.WithPartition("2024_07").FindAll(ctx); !errors.Is(err, ErrUnknownPartition) {
+			t.Errorf("Expected ErrUnknownPartition, got %v", err)
+		}
+		if _, err := repo.WithPartition("").FindAll(ctx); !errors.Is(err, ErrUnknownPartition) {
+			t.Errorf("Expected ErrUnknownPartition for an empty suffix, got %v", err)
+		}
+	})
+}
+
+func TestWithPartitionDeleteArchive(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.Table("test_users_2024_06").AutoMigrate(&TestUser{}); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+	repo := New[TestUser](db, WithDeleteArchive())
+	ctx := context.Background()
+	if err := repo.EnableDeleteArchive(ctx); err != nil {
+		t.Fatalf("EnableDeleteArchive failed: %v", err)
+	}
+
+	partition := repo.WithPartition("2024_06")
+	user := TestUser{Name: "June", Email: "june@example.com", Age: 30}
+	if err := partition.Create(ctx, &user); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := partition.Delete(ctx, &user); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	var archived []TestUser
+	db.Table("test_users_archive").Find(&archived)
+	if len(archived) != 1 || archived[0].ID != user.ID {
+		t.Errorf("Expected the deleted row in the parent's archive, got %+v", archived)
+	}
+	var count int64
+	db.Table("test_users_2024_06").Count(&count)
+	if count != 0 {
+		t.Errorf("Expected the row to be deleted from the partition, got %d rows", count)
+	}
+}
+
+func TestWithPartitionVersioning(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	if err := db.Table("test_articles_2024_06").AutoMigrate(&TestArticle{}); err != nil {
+		t.Fatalf("Failed to create partition: %v", err)
+	}
+	repo := New[TestArticle](db, WithVersioning())
+	ctx := context.Background()
+	if err := repo.EnableVersioning(ctx); err != nil {
+		t.Fatalf("EnableVersioning failed: %v", err)
+	}
+
+	partition := repo.WithPartition("2024_06")
+	article := &TestArticle{Title: "v1"}
+	if err := partition.Create(ctx, article); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	article.Title = "v2"
+	if err := partition.Update(ctx, article); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	history, err := partition.History(ctx, article.ID)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Title != "v1" {
+		t.Fatalf("Expected versions [v1], got %+v", history)
+	}
+
+	if err := partition.RestoreVersion(ctx, article.ID, 1); err != nil {
+		t.Fatalf("RestoreVersion failed: %v", err)
+	}
+	var current TestArticle
+	partition.FindByID(ctx, article.ID, &current)
+	if current.Title != "v1" {
+		t.Errorf("Expected title v1 after restore, got %s", current.Title)
+	}
+}
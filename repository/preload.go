@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidAssociation is returned when an association name does not belong
+// to the model
+var ErrInvalidAssociation = errors.New("invalid association")
+
+// WithDefaultPreloads returns a repository whose reads of one record, or of
+// one record per group, eager-load the given associations: FindByID,
+// FindByIDForUpdate, FindByIDWithPreloads, FirstWhere, FirstOrdered,
+// FindDistinctOn and FindTopNPerGroup. Nested associations use dots, as in "Orders.Items". Other
+// list reads, such as FindAll and FindWhere, are not affected. Every call on
+// the returned repository fails with ErrInvalidAssociation when a name is not
+// an association of T.
+func (r *Repository[T]) WithDefaultPreloads(associations ...string) *Repository[T] {
+	s, err := r.schema()
+	if err != nil {
+		return r.withError(err)
+	}
+	if err := checkAssociations(s, associations); err != nil {
+		return r.withError(err)
+	}
+
+	clone := *r
+	clone.preloads = append(append([]string(nil), r.preloads...), associations...)
+	return &clone
+}
+
+// PreloadPolymorphic returns a repository whose reads listed in
+// WithDefaultPreloads eager-load a polymorphic has-one or has-many
+// association, matching on both the owner ID and the owner type so rows of
+// other owner types sharing the ID are not loaded. The association field
+// needs the polymorphic tag and the associated model the matching ID and type
+// columns:
+//
+//	type Post struct {
+//		ID       uint
+//		Comments []Comment `gorm:"polymorphic:Owner;"`
+//	}
+//
+//	type Comment struct {
+//		ID        uint
+//		OwnerID   uint
+//		OwnerType string // "posts", or the value of polymorphicValue
+//	}
+//
+// The reverse direction, a Comment loading its owner of any type, is not
+// supported by GORM. Every call on the returned repository fails with
+// ErrInvalidAssociation when association is not a polymorphic association of T.
+func (r *Repository[T]) PreloadPolymorphic(association string) *Repository[T] {
+	s, err := r.schema()
+	if err != nil {
+		return r.withError(err)
+	}
+	rel, ok := s.Relationships.Relations[association]
+	if !ok || rel.Polymorphic == nil {
+		return r.withError(fmt.Errorf("%w: %q is not a polymorphic association", ErrInvalidAssociation, association))
+	}
+
+	clone := *r
+	clone.preloads = append(append([]string(nil), r.preloads...), association)
+	return &clone
+}
+
+// WithoutPreloads returns a repository that drops the default preloads, for
+// lightweight reads that do not need the associations
+func (r *Repository[T]) WithoutPreloads() *Repository[T] {
+	clone := *r
+	clone.preloads = nil
+	return &clone
+}
+
+// FindByIDWithPreloads finds a record by ID like FindByID and eager-loads the
+// given associations in addition to the default preloads. Nested associations
+// use dots, as in "Orders.Items". It fails with ErrInvalidAssociation, naming
+// the path, when a name is not an association of T. The identity map of
+// WithIdentityMap is bypassed, so the associations are always loaded.
+func (r *Repository[T]) FindByIDWithPreloads(ctx context.Context, id interface{}, entity *T, preloads ...string) error {
+	ctx = r.checkDeadline(ctx)
+	tx, err := r.preloadConn(ctx, preloads)
+	if err != nil {
+		return err
+	}
+	return r.preload(tx).First(entity, id).Error
+}
+
+// FindAllWithPreloads finds all records like FindAll and eager-loads the
+// given associations, validated like in FindByIDWithPreloads
+func (r *Repository[T]) FindAllWithPreloads(ctx context.Context, preloads ...string) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	tx, err := r.preloadConn(ctx, preloads)
+	if err != nil {
+		return nil, err
+	}
+	return r.findAll(ctx, tx)
+}
+
+// preloadConn validates preloads and returns a connection that loads them
+func (r *Repository[T]) preloadConn(ctx context.Context, preloads []string) (*gorm.DB, error) {
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAssociations(s, preloads); err != nil {
+		return nil, err
+	}
+	tx := r.conn(ctx)
+	for _, name := range preloads {
+		tx = tx.Preload(name)
+	}
+	return tx, nil
+}
+
+// checkAssociations returns ErrInvalidAssociation for the first name that is
+// not an association path of s
+func checkAssociations(s *schema.Schema, names []string) error {
+	for _, name := range names {
+		if !hasAssociation(s, name) {
+			return fmt.Errorf("%w: %q", ErrInvalidAssociation, name)
+		}
+	}
+	return nil
+}
+
+// preload applies the default preloads to tx
+func (r *Repository[T]) preload(tx *gorm.DB) *gorm.DB {
+	for _, name := range r.preloads {
+		tx = tx.Preload(name)
+	}
+	return tx
+}
+
+// hasAssociation reports whether the dotted association path exists on s
+func hasAssociation(s *schema.Schema, path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		rel, ok := s.Relationships.Relations[name]
+		if !ok {
+			return false
+		}
+		s = rel.FieldSchema
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestAuthor is a test entity with a has-many association
+type TestAuthor struct {
+	ID    uint       `gorm:"primarykey"`
+	Name  string     `gorm:"size:100"`
+	Books []TestBook `gorm:"foreignKey:AuthorID"`
+}
+
+// TestBook belongs to a TestAuthor
+type TestBook struct {
+	ID       uint `gorm:"primarykey"`
+	AuthorID uint
+	Title    string `gorm:"size:100"`
+}
+
+func TestWithDefaultPreloads(t *testing.T) {
+	db := setupTestDB(t, &TestAuthor{}, &TestBook{})
+	ctx := context.Background()
+
+	author := &TestAuthor{Name: "Author", Books: []TestBook{{Title: "One"}, {Title: "Two"}}}
+	if err := New[TestAuthor](db).Create(ctx, author); err != nil {
+		t.Fatalf("Failed to create author: %v", err)
+	}
+
+	repo := New[TestAuthor](db).WithDefaultPreloads("Books")
+
+	t.Run("preloads on single-record reads", func(t *testing.T) {
+		var found TestAuthor
+		if err := repo.FindByID(ctx, author.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if len(found.Books) != 2 {
+			t.Errorf("Expected 2 books, got %d", len(found.Books))
+		}
+
+		var first TestAuthor
+		if err := repo.FirstWhere(ctx, &first, "name = ?", "Author"); err != nil {
+			t.Fatalf("FirstWhere failed: %v", err)
+		}
+		if len(first.Books) != 2 {
+			t.Errorf("Expected 2 books, got %d", len(first.Books))
+		}
+	})
+
+	t.Run("does not affect list reads", func(t *testing.T) {
+		authors, err := repo.FindAll(ctx)
+		if err != nil {
+			t.Fatalf("FindAll failed: %v", err)
+		}
+		if len(authors) != 1 || len(authors[0].Books) != 0 {
+			t.Error("Expected list reads without preloads")
+		}
+	})
+
+	t.Run("can be suppressed", func(t *testing.T) {
+		var found TestAuthor
+		if err := repo.WithoutPreloads().FindByID(ctx, author.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if len(found.Books) != 0 {
+			t.Errorf("Expected no books, got %d", len(found.Books))
+		}
+	})
+
+	t.Run("rejects unknown associations", func(t *testing.T) {
+		var found TestAuthor
+		err := New[TestAuthor](db).WithDefaultPreloads("Books.Missing").FindByID(ctx, author.ID, &found)
+		if !errors.Is(err, ErrInvalidAssociation) {
+			t.Errorf("Expected ErrInvalidAssociation, got %v", err)
+		}
+	})
+}
+
+// TestPost owns polymorphic comments
+type TestPost struct {
+	ID       uint          `gorm:"primarykey"`
+	Title    string        `gorm:"size:100"`
+	Comments []TestComment `gorm:"polymorphic:Owner;"`
+}
+
+// TestVideo owns polymorphic comments
+type TestVideo struct {
+	ID       uint          `gorm:"primarykey"`
+	Comments []TestComment `gorm:"polymorphic:Owner;"`
+}
+
+// TestComment belongs to a TestPost or a TestVideo
+type TestComment struct {
+	ID        uint `gorm:"primarykey"`
+	Body      string
+	OwnerID   uint
+	OwnerType string
+}
+
+func TestPreloadPolymorphic(t *testing.T) {
+	db := setupTestDB(t, &TestPost{}, &TestVideo{}, &TestComment{})
+	ctx := context.Background()
+
+	post := &TestPost{Title: "Post", Comments: []TestComment{{Body: "on post"}}}
+	video := &TestVideo{Comments: []TestComment{{Body: "on video 1"}, {Body: "on video 2"}}}
+	New[TestPost](db).Create(ctx, post)
+	New[TestVideo](db).Create(ctx, video)
+	if post.ID != video.ID {
+		t.Fatalf("Expected post and video to share an ID")
+	}
+
+	t.Run("loads only the owner type's rows", func(t *testing.T) {
+		var found TestPost
+		if err := New[TestPost](db).PreloadPolymorphic("Comments").FindByID(ctx, post.ID, &found); err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if len(found.Comments) != 1 || found.Comments[0].Body != "on post" {
+			t.Errorf("Expected only the post comment, got %+v", found.Comments)
+		}
+	})
+
+	t.Run("rejects non-polymorphic associations", func(t *testing.T) {
+		db := setupTestDB(t, &TestAuthor{}, &TestBook{})
+		var found TestAuthor
+		err := New[TestAuthor](db).PreloadPolymorphic("Books").FindByID(ctx, 1, &found)
+		if !errors.Is(err, ErrInvalidAssociation) {
+			t.Errorf("Expected ErrInvalidAssociation, got %v", err)
+		}
+	})
+}
+
+func TestFindWithPreloads(t *testing.T) {
+	db := setupTestDB(t, &TestAuthor{}, &TestBook{})
+	repo := New[TestAuthor](db)
+	ctx := context.Background()
+
+	author := &TestAuthor{Name: "Author", Books: []TestBook{{Title: "One"}, {Title: "Two"}}}
+	if err := repo.Create(ctx, author); err != nil {
+		t.Fatalf("Failed to create author: %v", err)
+	}
+	repo.Create(ctx, &TestAuthor{Name: "Other"})
+
+	t.Run("find by id loads the associations", func(t *testing.T) {
+		var found TestAuthor
+		if err := repo.FindByIDWithPreloads(ctx, author.ID, &found, "Books"); err != nil {
+			t.Fatalf("FindByIDWithPreloads failed: %v", err)
+		}
+		if len(found.Books) != 2 {
+			t.Errorf("Expected 2 books, got %d", len(found.Books))
+		}
+	})
+
+	t.Run("find all loads the associations", func(t *testing.T) {
+		authors, err := repo.FindAllWithPreloads(ctx, "Books")
+		if err != nil {
+			t.Fatalf("FindAllWithPreloads failed: %v", err)
+		}
+		if len(authors) != 2 || len(authors[0].Books) != 2 || len(authors[1].Books) != 0 {
+			t.Errorf("Expected 2 and 0 books, got %+v", authors)
+		}
+	})
+
+	t.Run("find all warns about large results", func(t *testing.T) {
+		warnings := &warnRecorder{Interface: logger.Discard}
+		warned := New[TestAuthor](db.Session(&gorm.Session{Logger: warnings}), WithLargeResultWarning(1, nil))
+		if _, err := warned.FindAllWithPreloads(ctx, "Books"); err != nil {
+			t.Fatalf("FindAllWithPreloads failed: %v", err)
+		}
+		if len(warnings.messages) != 1 || !strings.Contains(warnings.messages[0], "test_authors returned 2 rows") {
+			t.Errorf("Expected one warning naming the table and row count, got %v", warnings.messages)
+		}
+	})
+
+	t.Run("rejects unknown associations", func(t *testing.T) {
+		var found TestAuthor
+		err := repo.FindByIDWithPreloads(ctx, author.ID, &found, "Books.Missing")
+		if !errors.Is(err, ErrInvalidAssociation) || !strings.Contains(err.Error(), "Books.Missing") {
+			t.Errorf("Expected ErrInvalidAssociation naming the path, got %v", err)
+		}
+		if _, err := repo.FindAllWithPreloads(ctx, "Missing"); !errors.Is(err, ErrInvalidAssociation) {
+			t.Errorf("Expected ErrInvalidAssociation, got %v", err)
+		}
+	})
+}
+
+func TestFindDistinctOnPreloads(t *testing.T) {
+	db := setupTestDB(t, &TestAuthor{}, &TestBook{})
+	ctx := context.Background()
+
+	for _, name := range []string{"A", "A", "B"} {
+		author := &TestAuthor{Name: name, Books: []TestBook{{Title: name + " book"}}}
+		if err := New[TestAuthor](db).Create(ctx, author); err != nil {
+			t.Fatalf("Failed to create author: %v", err)
+		}
+	}
+
+	authors, err := New[TestAuthor](db).WithDefaultPreloads("Books").FindDistinctOn(ctx, []string{"name"}, "id DESC", nil)
+	if err != nil {
+		t.Fatalf("FindDistinctOn failed: %v", err)
+	}
+	if len(authors) != 2 {
+		t.Fatalf("Expected 2 authors, got %d", len(authors))
+	}
+	for _, author := range authors {
+		if len(author.Books) != 1 {
+			t.Errorf("Expected the books of %s to be preloaded, got %d", author.Name, len(author.Books))
+		}
+	}
+}
+
+func TestFindTopNPerGroupPreloads(t *testing.T) {
+	db := setupTestDB(t, &TestAuthor{}, &TestBook{})
+	ctx := context.Background()
+
+	for _, name := range []string{"A", "A", "B"} {
+		author := &TestAuthor{Name: name, Books: []TestBook{{Title: name + " book"}}}
+		if err := New[TestAuthor](db).Create(ctx, author); err != nil {
+			t.Fatalf("Failed to create author: %v", err)
+		}
+	}
+
+	authors, err := New[TestAuthor](db).WithDefaultPreloads("Books").FindTopNPerGroup(ctx, "name", "id", 1, true)
+	if err != nil {
+		t.Fatalf("FindTopNPerGroup failed: %v", err)
+	}
+	if len(authors) != 2 {
+		t.Fatalf("Expected 2 authors, got %d", len(authors))
+	}
+	for _, author := range authors {
+		if len(author.Books) != 1 {
+			t.Errorf("Expected the books of %s to be preloaded, got %d", author.Name, len(author.Books))
+		}
+	}
+}
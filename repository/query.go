@@ -0,0 +1,567 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoUpdatedAt is returned when an operation needs an auto-updated
+// timestamp column and the model has none
+var ErrNoUpdatedAt = errors.New("model has no updated_at field")
+
+// updatedAtField returns the model's auto-update time field, or nil if it has none
+func updatedAtField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.AutoUpdateTime > 0 {
+			return field
+		}
+	}
+	return nil
+}
+
+// FindModifiedSince finds records updated after since, ordered by their update
+// time. T must have an auto-updated timestamp field (UpdatedAt or a field tagged
+// autoUpdateTime), otherwise ErrNoUpdatedAt is returned.
+//
+// For soft-delete models, calling it on WithDeleted() also returns rows
+// deleted after since so sync clients can remove them locally.
+func (r *Repository[T]) FindModifiedSince(ctx context.Context, since time.Time) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	updatedAt := updatedAtField(s)
+	if updatedAt == nil {
+		return nil, ErrNoUpdatedAt
+	}
+
+	updatedCol := clause.Column{Table: clause.CurrentTable, Name: updatedAt.DBName}
+	var cond clause.Expression = clause.Gt{Column: updatedCol, Value: since}
+	if deletedAt := softDeleteField(s); deletedAt != nil {
+		cond = clause.Or(cond, clause.Gt{
+			Column: clause.Column{Table: clause.CurrentTable, Name: deletedAt.DBName},
+			Value:  since,
+		})
+	}
+
+	var entities []T
+	err = r.conn(ctx).
+		Where(cond).
+		Order(clause.OrderByColumn{Column: updatedCol}).
+		Find(&entities).Error
+	return entities, err
+}
+
+// FindByIDs finds the records with the given primary keys in one IN query on
+// T's primary key column, whatever its name. The order of the result is the
+// database's; use FindByIDsOrdered to get the order of ids. IDs without a
+// matching record are dropped, and no IDs give an empty result. ID lists over
+// the driver's parameter limit are queried in chunks.
+func (r *Repository[T]) FindByIDs(ctx context.Context, ids []interface{}) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	if len(ids) == 0 {
+		return []T{}, r.err
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := r.findIn(ctx, pk.DBName, ids)
+	if entities == nil && err == nil {
+		entities = []T{}
+	}
+	return entities, err
+}
+
+// FindByIDsOrdered finds the records with the given primary keys and returns
+// them in the order of ids. IDs without a matching record are dropped. Long
+// ID lists are queried in chunks that stay under the driver's parameter limit.
+func (r *Repository[T]) FindByIDsOrdered(ctx context.Context, ids []uint) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	if len(ids) == 0 {
+		return []T{}, r.err
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+
+	found, err := r.findIn(ctx, pk.DBName, values)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]int, len(found))
+	for i := range found {
+		value, _ := pk.ValueOf(ctx, reflect.ValueOf(&found[i]).Elem())
+		byID[fmt.Sprint(value)] = i
+	}
+
+	entities := make([]T, 0, len(found))
+	for _, id := range ids {
+		if i, ok := byID[fmt.Sprint(id)]; ok {
+			entities = append(entities, found[i])
+		}
+	}
+	return entities, nil
+}
+
+// ReloadAll overwrites every entity in place with its row as currently
+// stored, fetching the rows in one IN query, chunked like FindByIDsOrdered,
+// instead of one query per entity. Entities whose row no longer exists are
+// left unchanged and reported in an error wrapping gorm.ErrRecordNotFound
+// that lists their IDs, after the others have been reloaded. Entities must
+// have their primary key set.
+func (r *Repository[T]) ReloadAll(ctx context.Context, entities []T) error {
+	ctx = r.checkDeadline(ctx)
+	if len(entities) == 0 {
+		return r.err
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]interface{}, len(entities))
+	for i := range entities {
+		value, zero := pk.ValueOf(ctx, reflect.ValueOf(&entities[i]).Elem())
+		if zero {
+			return fmt.Errorf("entity %d has no primary key", i)
+		}
+		ids[i] = value
+	}
+
+	found, err := r.findIn(ctx, pk.DBName, ids)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]int, len(found))
+	for i := range found {
+		value, _ := pk.ValueOf(ctx, reflect.ValueOf(&found[i]).Elem())
+		byID[fmt.Sprint(value)] = i
+	}
+
+	var missing []string
+	for i, id := range ids {
+		if j, ok := byID[fmt.Sprint(id)]; ok {
+			entities[i] = found[j]
+		} else {
+			missing = append(missing, fmt.Sprint(id))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s %s", gorm.ErrRecordNotFound, pk.DBName, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// FindMaps finds records matching the condition and returns each row as a map
+// keyed by column name, for dynamic tooling that has no struct for the result.
+// An empty condition matches every row. Values are whatever the driver
+// returns: integers may come back as int64, decimals as []byte or string and
+// times as time.Time or string depending on the driver and its DSN options.
+func (r *Repository[T]) FindMaps(ctx context.Context, query interface{}, args ...interface{}) ([]map[string]interface{}, error) {
+	ctx = r.checkDeadline(ctx)
+	tx := r.conn(ctx).Model(new(T))
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+
+	var rows []map[string]interface{}
+	err := tx.Find(&rows).Error
+	return rows, err
+}
+
+// FindWithSelect runs SELECT selectExpr over the records of repo matching the
+// condition and scans each row into the projection struct R, for results
+// with computed columns:
+//
+//	type ScoredUser struct {
+//		User  `gorm:"embedded"`
+//		Total int
+//	}
+//
+//	rows, err := repository.FindWithSelect[ScoredUser](ctx, repo, "*, (wins + draws) AS total", "active = ?", true)
+//
+// Result columns are matched to the fields of R by GORM's naming rules: a
+// field receives the column named after it in snake_case (Total from total,
+// FullName from full_name) or the name in its gorm:"column" tag, and embedded
+// structs contribute their fields as if declared inline. Columns without a
+// field are ignored and fields without a column keep their zero value, so
+// alias every computed expression with AS. An empty condition matches every
+// record. selectExpr is inserted into the query as is, so it must come from
+// trusted code.
+func FindWithSelect[R any, T any](ctx context.Context, repo *Repository[T], selectExpr string, query interface{}, args ...interface{}) ([]R, error) {
+	ctx = repo.checkDeadline(ctx)
+	tx := repo.conn(ctx).Model(new(T)).Select(selectExpr)
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+
+	var results []R
+	err := tx.Find(&results).Error
+	return results, err
+}
+
+// FindWhereIn finds the records whose column is one of values. An empty
+// values slice returns an empty result without querying, and long ones are
+// queried in chunks that stay under the driver's parameter limit.
+func (r *Repository[T]) FindWhereIn(ctx context.Context, column string, values []interface{}) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	col, err := r.column(column)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return []T{}, r.err
+	}
+
+	return r.findIn(ctx, col, values)
+}
+
+// ExistingValues reports which of values are already stored in column, for
+// filtering out records an ingestion run has imported before without one
+// existence check per record. Every value is a key of the result, mapped to
+// whether a record has it. Values are matched by their printed form, so 42
+// and int64(42) are the same value; they must be usable as map keys. An empty
+// values slice returns an empty map without querying, and long ones are
+// queried in chunks that stay under the driver's parameter limit.
+// Soft-deleted rows are excluded unless called on WithDeleted().
+func (r *Repository[T]) ExistingValues(ctx context.Context, column string, values []interface{}) (map[interface{}]bool, error) {
+	ctx = r.checkDeadline(ctx)
+	col, err := r.column(column)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[interface{}]bool, len(values))
+	if len(values) == 0 {
+		return existing, r.err
+	}
+
+	stored := make(map[string]bool)
+	for _, chunk := range chunkValues(uniqueValues(values), r.inChunkSize()) {
+		var found []interface{}
+		err := r.conn(ctx).Model(new(T)).Distinct().Where(clause.IN{
+			Column: clause.Column{Table: clause.CurrentTable, Name: col},
+			Values: chunk,
+		}).Pluck(col, &found).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range found {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			stored[fmt.Sprint(v)] = true
+		}
+	}
+
+	for _, v := range values {
+		existing[v] = stored[fmt.Sprint(v)]
+	}
+	return existing, nil
+}
+
+// likeEscape is the escape character SearchPrefix declares for LIKE patterns.
+// It is not a backslash because mysql also treats backslashes in string
+// literals as escapes.
+const likeEscape = "!"
+
+// SearchPrefix returns up to limit records whose column starts with prefix,
+// ignoring case, ordered by column, for autocomplete endpoints. It runs
+//
+//	WHERE LOWER(column) LIKE LOWER(?) ESCAPE '!'
+//
+// with the wildcard appended to the escaped prefix in Go rather than
+// concatenated in SQL, which differs between drivers. The LIKE metacharacters
+// % and _ in prefix match literally, so user input can be passed as is. LOWER
+// defeats a plain index on column; on large tables add an index on
+// LOWER(column), with text_pattern_ops on postgres.
+func (r *Repository[T]) SearchPrefix(ctx context.Context, column, prefix string, limit int) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	col, err := r.column(column)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	pattern := strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(prefix) + "%"
+	target := clause.Column{Table: clause.CurrentTable, Name: col}
+
+	var entities []T
+	err = r.conn(ctx).
+		Where("LOWER(?) LIKE LOWER(?) ESCAPE '"+likeEscape+"'", target, pattern).
+		Order(clause.OrderByColumn{Column: target}).
+		Limit(limit).
+		Find(&entities).Error
+	return entities, err
+}
+
+// hasEmptyIn reports whether a string condition binds an empty slice to an
+// IN placeholder, as in "status IN ?". NOT IN is not matched, since an empty
+// NOT IN list should match every row rather than none.
+func hasEmptyIn(query interface{}, args []interface{}) bool {
+	sql, ok := query.(string)
+	if !ok {
+		return false
+	}
+
+	arg, quoted := 0, false
+	for i := 0; i < len(sql) && arg < len(args); i++ {
+		switch sql[i] {
+		case '\'':
+			quoted = !quoted
+		case '?':
+			if quoted {
+				continue
+			}
+			if isEmptySlice(args[arg]) && precededByIn(sql[:i]) {
+				return true
+			}
+			arg++
+		}
+	}
+	return false
+}
+
+// precededByIn reports whether sql ends with the IN keyword, optionally
+// followed by an opening parenthesis, and not NOT IN
+func precededByIn(sql string) bool {
+	words := strings.Fields(strings.ToUpper(strings.TrimRight(sql, " \t\n(")))
+	n := len(words)
+	return n > 0 && words[n-1] == "IN" && (n == 1 || words[n-2] != "NOT")
+}
+
+// isEmptySlice reports whether v is an empty slice other than []byte
+func isEmptySlice(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 && rv.Len() == 0
+}
+
+// GetOr finds a record by ID like FindByID, but returns notFound, wrapped with
+// the ID, instead of gorm.ErrRecordNotFound when there is no such record, so
+// service layers can return their own domain error directly. Other errors are
+// returned unchanged.
+func (r *Repository[T]) GetOr(ctx context.Context, id interface{}, notFound error) (T, error) {
+	ctx = r.checkDeadline(ctx)
+	var entity T
+	err := r.FindByID(ctx, id, &entity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity, fmt.Errorf("%w: id %v", notFound, id)
+	}
+	return entity, err
+}
+
+// FirstOrdered returns the first record matching the condition when sorted by
+// orderBy, such as "created_at DESC" for the most recent one. orderBy is a
+// single field or column name, optionally followed by ASC or DESC. It returns
+// gorm.ErrRecordNotFound when no record matches.
+func (r *Repository[T]) FirstOrdered(ctx context.Context, orderBy string, query interface{}, args ...interface{}) (T, error) {
+	ctx = r.checkDeadline(ctx)
+	var entity T
+
+	order, err := r.orderByColumn(orderBy)
+	if err != nil {
+		return entity, err
+	}
+
+	tx := r.preload(r.conn(ctx)).Order(order)
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+	err = tx.Take(&entity).Error
+	return entity, err
+}
+
+// orderByColumn parses "name [ASC|DESC]" into an order by the resolved column
+func (r *Repository[T]) orderByColumn(orderBy string) (clause.OrderByColumn, error) {
+	parts := strings.Fields(orderBy)
+	if len(parts) == 0 || len(parts) > 2 {
+		return clause.OrderByColumn{}, fmt.Errorf("%w: %q", ErrInvalidColumn, orderBy)
+	}
+
+	col, err := r.column(parts[0])
+	if err != nil {
+		return clause.OrderByColumn{}, err
+	}
+
+	order := clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: col}}
+	if len(parts) == 2 {
+		switch strings.ToUpper(parts[1]) {
+		case "ASC":
+		case "DESC":
+			order.Desc = true
+		default:
+			return clause.OrderByColumn{}, fmt.Errorf("invalid sort direction %q", parts[1])
+		}
+	}
+	return order, nil
+}
+
+// FindDistinctOn returns, for each distinct combination of distinctColumns,
+// the first record matching the condition when sorted by orderBy, such as the
+// latest status row per order:
+//
+//	repo.FindDistinctOn(ctx, []string{"order_id"}, "created_at DESC", nil)
+//
+// orderBy takes the same form as in FirstOrdered. Postgres runs SELECT
+// DISTINCT ON; other drivers emulate it with ROW_NUMBER() OVER (PARTITION BY
+// ...), which needs mysql 8 or sqlite 3.25. Results are ordered by
+// distinctColumns and load the default preloads on every driver.
+func (r *Repository[T]) FindDistinctOn(ctx context.Context, distinctColumns []string, orderBy string, query interface{}, args ...interface{}) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(distinctColumns) == 0 {
+		return nil, errors.New("distinct columns cannot be empty")
+	}
+	cols, err := r.columns(distinctColumns)
+	if err != nil {
+		return nil, err
+	}
+	order, err := r.orderByColumn(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	distinct := make([]clause.Expression, len(cols))
+	orders := make([]clause.OrderByColumn, len(cols), len(cols)+1)
+	for i, col := range cols {
+		column := clause.Column{Table: clause.CurrentTable, Name: col}
+		distinct[i] = clause.Expr{SQL: "?", Vars: []interface{}{column}}
+		orders[i] = clause.OrderByColumn{Column: column}
+	}
+	partition := clause.CommaExpression{Exprs: distinct}
+	table := clause.Table{Name: clause.CurrentTable}
+
+	tx := r.conn(ctx).Model(new(T))
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+
+	var entities []T
+	if r.Dialect() == dialect.Postgres {
+		err = r.preload(tx).Select("DISTINCT ON (?) ?.*", partition, table).
+			Order(clause.OrderBy{Columns: append(orders, order)}).
+			Find(&entities).Error
+		return entities, err
+	}
+
+	ranked := tx.Select("?.*, ROW_NUMBER() OVER (PARTITION BY ? ?) AS distinct_rank",
+		table, partition, clause.OrderBy{Columns: []clause.OrderByColumn{order}})
+	outerOrder := make([]clause.OrderByColumn, len(cols))
+	for i, col := range cols {
+		outerOrder[i] = clause.OrderByColumn{Column: clause.Column{Name: col}}
+	}
+	// The inner query already applies the scopes. The outer one repeats only
+	// the soft-delete filter, on the derived table, so that the preloads
+	// follow the same soft-delete scoping as on postgres.
+	outer := r.db.WithContext(ctx)
+	if r.unscoped {
+		outer = outer.Unscoped()
+	}
+	err = r.preload(outer).
+		Table("(?) AS distinct_ranked", ranked).
+		Where("distinct_rank = 1").
+		Order(clause.OrderBy{Columns: outerOrder}).
+		Find(&entities).Error
+	return entities, err
+}
+
+// FindTopNPerGroup returns, for each distinct value of partitionColumn, the
+// first n records when sorted by orderColumn, descending when desc is set,
+// such as the 3 latest orders per customer:
+//
+//	repo.FindTopNPerGroup(ctx, "customer_id", "created_at", 3, true)
+//
+// It filters ROW_NUMBER() OVER (PARTITION BY partitionColumn ORDER BY
+// orderColumn) in a subquery, which needs postgres 8.4, mysql 8 or sqlite
+// 3.25. Ties on orderColumn are broken by the primary key, so the result is
+// stable. Results are ordered by partitionColumn and then by rank, and load
+// the default preloads.
+func (r *Repository[T]) FindTopNPerGroup(ctx context.Context, partitionColumn, orderColumn string, n int, desc bool) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if n <= 0 {
+		return nil, errors.New("n must be positive")
+	}
+	partition, err := r.column(partitionColumn)
+	if err != nil {
+		return nil, err
+	}
+	orderCol, err := r.column(orderColumn)
+	if err != nil {
+		return nil, err
+	}
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []clause.OrderByColumn{{Column: clause.Column{Table: clause.CurrentTable, Name: orderCol}, Desc: desc}}
+	for _, pk := range s.PrimaryFieldDBNames {
+		orders = append(orders, clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: pk}})
+	}
+	ranked := r.conn(ctx).Model(new(T)).
+		Select("?.*, ROW_NUMBER() OVER (PARTITION BY ? ?) AS group_rank",
+			clause.Table{Name: clause.CurrentTable},
+			clause.Column{Table: clause.CurrentTable, Name: partition},
+			clause.OrderBy{Columns: orders})
+
+	var entities []T
+	// The inner query already applies the scopes. The outer one repeats only
+	// the soft-delete filter, on the derived table, so that the preloads
+	// follow the same soft-delete scoping, as in FindDistinctOn.
+	outer := r.db.WithContext(ctx)
+	if r.unscoped {
+		outer = outer.Unscoped()
+	}
+	err = r.preload(outer).
+		Table("(?) AS group_ranked", ranked).
+		Where("group_rank <= ?", n).
+		Order(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: partition}},
+			{Column: clause.Column{Name: "group_rank"}},
+		}}).
+		Find(&entities).Error
+	return entities, err
+}
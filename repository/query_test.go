@@ -0,0 +1,702 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestArticle is a test entity with timestamps and soft delete support
+type TestArticle struct {
+	ID        uint   `gorm:"primarykey"`
+	Title     string `gorm:"size:100"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func TestFindModifiedSince(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	repo := New[TestArticle](db)
+	ctx := context.Background()
+
+	old := &TestArticle{Title: "Old"}
+	repo.Create(ctx, old)
+	db.Model(old).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+
+	since := time.Now().Add(-time.Minute)
+	fresh := &TestArticle{Title: "Fresh"}
+	repo.Create(ctx, fresh)
+
+	tombstone := &TestArticle{Title: "Tombstone"}
+	repo.Create(ctx, tombstone)
+	db.Model(tombstone).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+	repo.Delete(ctx, tombstone)
+
+	t.Run("returns rows updated after since", func(t *testing.T) {
+		articles, err := repo.FindModifiedSince(ctx, since)
+		if err != nil {
+			t.Fatalf("FindModifiedSince failed: %v", err)
+		}
+		if len(articles) != 1 || articles[0].ID != fresh.ID {
+			t.Errorf("Expected only the fresh article, got %+v", articles)
+		}
+	})
+
+	t.Run("includes tombstones with deleted", func(t *testing.T) {
+		articles, err := repo.WithDeleted().FindModifiedSince(ctx, since)
+		if err != nil {
+			t.Fatalf("FindModifiedSince failed: %v", err)
+		}
+		if len(articles) != 2 {
+			t.Errorf("Expected fresh article and tombstone, got %+v", articles)
+		}
+	})
+
+	t.Run("returns error for model without updated_at", func(t *testing.T) {
+		_, err := New[TestUser](db).FindModifiedSince(ctx, since)
+		if !errors.Is(err, ErrNoUpdatedAt) {
+			t.Errorf("Expected ErrNoUpdatedAt, got %v", err)
+		}
+	})
+}
+
+func TestFindByIDsOrdered(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 5)
+
+	t.Run("returns records in input order", func(t *testing.T) {
+		users, err := repo.FindByIDsOrdered(ctx, []uint{4, 1, 3})
+		if err != nil {
+			t.Fatalf("FindByIDsOrdered failed: %v", err)
+		}
+
+		var got []uint
+		for _, user := range users {
+			got = append(got, user.ID)
+		}
+		if len(got) != 3 || got[0] != 4 || got[1] != 1 || got[2] != 3 {
+			t.Errorf("Expected IDs [4 1 3], got %v", got)
+		}
+	})
+
+	t.Run("drops missing ids", func(t *testing.T) {
+		users, err := repo.FindByIDsOrdered(ctx, []uint{99, 2})
+		if err != nil {
+			t.Fatalf("FindByIDsOrdered failed: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != 2 {
+			t.Errorf("Expected only user 2, got %+v", users)
+		}
+	})
+
+	t.Run("returns empty slice for no ids", func(t *testing.T) {
+		users, err := repo.FindByIDsOrdered(ctx, nil)
+		if err != nil {
+			t.Fatalf("FindByIDsOrdered failed: %v", err)
+		}
+		if len(users) != 0 {
+			t.Errorf("Expected no users, got %d", len(users))
+		}
+	})
+
+	t.Run("keeps the error of a failing option for no ids", func(t *testing.T) {
+		if _, err := New[TestUser](db, WithDefaultBatchSize(0)).FindByIDsOrdered(ctx, nil); err == nil {
+			t.Error("Expected the option error")
+		}
+	})
+}
+
+// TestCountry is a test entity with a natural primary key
+type TestCountry struct {
+	Code string `gorm:"primarykey;size:2"`
+	Name string
+}
+
+func TestFindByIDs(t *testing.T) {
+	db := setupTestDB(t, &TestCountry{})
+	ctx := context.Background()
+
+	t.Run("finds matching records and drops missing ids", func(t *testing.T) {
+		repo := New[TestUser](db)
+		seedUsers(t, repo, 3)
+
+		users, err := repo.FindByIDs(ctx, []interface{}{3, 1, 99})
+		if err != nil {
+			t.Fatalf("FindByIDs failed: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("Expected 2 users, got %+v", users)
+		}
+	})
+
+	t.Run("resolves the primary key column", func(t *testing.T) {
+		repo := New[TestCountry](db)
+		repo.Create(ctx, &TestCountry{Code: "DE", Name: "Germany"})
+		repo.Create(ctx, &TestCountry{Code: "FR", Name: "France"})
+
+		countries, err := repo.FindByIDs(ctx, []interface{}{"FR"})
+		if err != nil {
+			t.Fatalf("FindByIDs failed: %v", err)
+		}
+		if len(countries) != 1 || countries[0].Name != "France" {
+			t.Errorf("Expected only France, got %+v", countries)
+		}
+	})
+
+	t.Run("returns empty slice for no ids", func(t *testing.T) {
+		users, err := New[TestUser](db).FindByIDs(ctx, nil)
+		if err != nil {
+			t.Fatalf("FindByIDs failed: %v", err)
+		}
+		if users == nil || len(users) != 0 {
+			t.Errorf("Expected an empty slice, got %#v", users)
+		}
+	})
+}
+
+func TestReloadAll(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	users, _ := repo.FindAll(ctx)
+	db.Model(&TestUser{}).Where("id IN ?", []uint{1, 3}).Update("age", 99)
+
+	t.Run("overwrites entities with their stored rows", func(t *testing.T) {
+		if err := repo.ReloadAll(ctx, users); err != nil {
+			t.Fatalf("ReloadAll failed: %v", err)
+		}
+		if users[0].Age != 99 || users[1].Age != 22 || users[2].Age != 99 {
+			t.Errorf("Expected ages [99 22 99], got [%d %d %d]", users[0].Age, users[1].Age, users[2].Age)
+		}
+	})
+
+	t.Run("reports deleted rows", func(t *testing.T) {
+		db.Delete(&TestUser{}, 2)
+		db.Model(&TestUser{}).Where("id = ?", 1).Update("age", 50)
+
+		err := repo.ReloadAll(ctx, users)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+		if users[0].Age != 50 {
+			t.Errorf("Expected the remaining rows to be reloaded, got age %d", users[0].Age)
+		}
+		if users[1].ID != 2 || users[1].Age != 22 {
+			t.Errorf("Expected the deleted entity to be left unchanged, got %+v", users[1])
+		}
+	})
+
+	t.Run("requires primary keys", func(t *testing.T) {
+		if err := repo.ReloadAll(ctx, []TestUser{{Name: "New"}}); err == nil {
+			t.Error("Expected error for an entity without primary key")
+		}
+	})
+}
+
+func TestFindMaps(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	t.Run("returns rows keyed by column", func(t *testing.T) {
+		rows, err := repo.FindMaps(ctx, "age > ?", 21)
+		if err != nil {
+			t.Fatalf("FindMaps failed: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 rows, got %d", len(rows))
+		}
+		if rows[0]["email"] != "user2@example.com" {
+			t.Errorf("Expected email user2@example.com, got %v", rows[0]["email"])
+		}
+	})
+
+	t.Run("empty condition returns every row", func(t *testing.T) {
+		rows, err := repo.FindMaps(ctx, nil)
+		if err != nil {
+			t.Fatalf("FindMaps failed: %v", err)
+		}
+		if len(rows) != 3 {
+			t.Errorf("Expected 3 rows, got %d", len(rows))
+		}
+	})
+}
+
+func TestFirstOrdered(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 5)
+
+	t.Run("returns the first row in the given order", func(t *testing.T) {
+		user, err := repo.FirstOrdered(ctx, "age DESC", "age < ?", 24)
+		if err != nil {
+			t.Fatalf("FirstOrdered failed: %v", err)
+		}
+		if user.Age != 23 {
+			t.Errorf("Expected age 23, got %d", user.Age)
+		}
+
+		user, err = repo.FirstOrdered(ctx, "Age", nil)
+		if err != nil {
+			t.Fatalf("FirstOrdered failed: %v", err)
+		}
+		if user.Age != 21 {
+			t.Errorf("Expected age 21, got %d", user.Age)
+		}
+	})
+
+	t.Run("returns not found when nothing matches", func(t *testing.T) {
+		_, err := repo.FirstOrdered(ctx, "age", "age > ?", 100)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid order", func(t *testing.T) {
+		if _, err := repo.FirstOrdered(ctx, "missing DESC", nil); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.FirstOrdered(ctx, "age sideways", nil); err == nil {
+			t.Error("Expected error for invalid direction")
+		}
+	})
+}
+
+func TestGetOr(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 1)
+
+	errUserNotFound := errors.New("user not found")
+
+	t.Run("returns the record", func(t *testing.T) {
+		user, err := repo.GetOr(ctx, 1, errUserNotFound)
+		if err != nil {
+			t.Fatalf("GetOr failed: %v", err)
+		}
+		if user.Name != "User 1" {
+			t.Errorf("Expected User 1, got %s", user.Name)
+		}
+	})
+
+	t.Run("returns the custom error when missing", func(t *testing.T) {
+		_, err := repo.GetOr(ctx, 99, errUserNotFound)
+		if !errors.Is(err, errUserNotFound) {
+			t.Errorf("Expected errUserNotFound, got %v", err)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Error("Expected ErrRecordNotFound to be replaced")
+		}
+	})
+
+	t.Run("propagates other errors", func(t *testing.T) {
+		_, err := New[TestUser](db).WithDefaultPreloads("Missing").GetOr(ctx, 1, errUserNotFound)
+		if !errors.Is(err, ErrInvalidAssociation) {
+			t.Errorf("Expected ErrInvalidAssociation, got %v", err)
+		}
+	})
+}
+
+func TestFindWhereEmptyIn(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	t.Run("empty IN matches nothing", func(t *testing.T) {
+		queries := map[string][]interface{}{
+			"id IN ?":             {[]uint{}},
+			"id in (?)":           {[]uint{}},
+			"age > ? AND id IN ?": {0, []uint{}},
+		}
+		for query, args := range queries {
+			users, err := repo.FindWhere(ctx, query, args...)
+			if err != nil {
+				t.Fatalf("FindWhere %q failed: %v", query, err)
+			}
+			if users == nil || len(users) != 0 {
+				t.Errorf("Expected empty result for %q, got %v", query, users)
+			}
+		}
+	})
+
+	t.Run("non-empty IN still queries", func(t *testing.T) {
+		users, err := repo.FindWhere(ctx, "id IN ?", []uint{1, 2})
+		if err != nil {
+			t.Fatalf("FindWhere failed: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("Expected 2 users, got %d", len(users))
+		}
+	})
+
+	t.Run("detects IN placeholders only", func(t *testing.T) {
+		tests := []struct {
+			query string
+			args  []interface{}
+			want  bool
+		}{
+			{"id IN ?", []interface{}{[]int{}}, true},
+			{"id IN(?)", []interface{}{[]string{}}, true},
+			{"id NOT IN ?", []interface{}{[]int{}}, false},
+			{"name = ? AND id IN ?", []interface{}{"x", []int{1}}, false},
+			{"name = '?' AND id IN ?", []interface{}{[]int{}}, true},
+			{"data = ?", []interface{}{[]byte{}}, false},
+		}
+		for _, tt := range tests {
+			if got := hasEmptyIn(tt.query, tt.args); got != tt.want {
+				t.Errorf("hasEmptyIn(%q): expected %v, got %v", tt.query, tt.want, got)
+			}
+		}
+	})
+}
+
+func TestFindWhereIn(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	users, err := repo.FindWhereIn(ctx, "Age", []interface{}{21, 23})
+	if err != nil {
+		t.Fatalf("FindWhereIn failed: %v", err)
+	}
+	if len(users) != 2 {
+		t.Errorf("Expected 2 users, got %d", len(users))
+	}
+
+	users, err = repo.FindWhereIn(ctx, "age", nil)
+	if err != nil || users == nil || len(users) != 0 {
+		t.Errorf("Expected empty result, got %v, %v", users, err)
+	}
+
+	if _, err := repo.FindWhereIn(ctx, "missing", []interface{}{1}); !errors.Is(err, ErrInvalidColumn) {
+		t.Errorf("Expected ErrInvalidColumn, got %v", err)
+	}
+}
+
+func TestFindWhereInDialects(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		dialector gorm.Dialector
+		want      string
+	}{
+		{
+			mysql.New(mysql.Config{DSN: "user@tcp(localhost)/db", SkipInitializeWithVersion: true}),
+			"SELECT * FROM `test_users` WHERE `test_users`.`age` IN (21,23)",
+		},
+		{
+			postgres.Open("host=localhost"),
+			`SELECT * FROM "test_users" WHERE "test_users"."age" IN (21,23)`,
+		},
+	}
+
+	for _, tt := range tests {
+		var sql []string
+		db := setupDryRunDB(t, tt.dialector, func(s string) { sql = append(sql, s) })
+		repo := New[TestUser](db)
+
+		repo.FindWhereIn(ctx, "age", []interface{}{21, 23})
+		if len(sql) != 1 || sql[0] != tt.want {
+			t.Errorf("%s: expected %s, got %v", repo.Dialect(), tt.want, sql)
+		}
+
+		sql = nil
+		repo.FindWhereIn(ctx, "age", nil)
+		repo.FindWhere(ctx, "id IN ?", []uint{})
+		if len(sql) != 0 {
+			t.Errorf("%s: expected empty IN lists not to query, got %v", repo.Dialect(), sql)
+		}
+	}
+}
+
+func TestExistingValues(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 3)
+
+	existing, err := repo.ExistingValues(ctx, "Email", []interface{}{"user1@example.com", "new@example.com", "user3@example.com"})
+	if err != nil {
+		t.Fatalf("ExistingValues failed: %v", err)
+	}
+	want := map[interface{}]bool{"user1@example.com": true, "new@example.com": false, "user3@example.com": true}
+	if fmt.Sprint(existing) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, existing)
+	}
+
+	t.Run("chunks long lists", func(t *testing.T) {
+		values := make([]interface{}, 0, 2000)
+		for age := 0; age < 2000; age++ {
+			values = append(values, age)
+		}
+		existing, err := repo.ExistingValues(ctx, "age", values)
+		if err != nil {
+			t.Fatalf("ExistingValues failed: %v", err)
+		}
+		if len(existing) != 2000 || !existing[21] || !existing[23] || existing[24] {
+			t.Errorf("Expected ages 21 to 23 to exist among 2000 values, got %d keys", len(existing))
+		}
+	})
+
+	t.Run("validates input", func(t *testing.T) {
+		existing, err := repo.ExistingValues(ctx, "email", nil)
+		if err != nil || existing == nil || len(existing) != 0 {
+			t.Errorf("Expected empty result, got %v, %v", existing, err)
+		}
+		if _, err := repo.ExistingValues(ctx, "missing", []interface{}{1}); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := New[TestUser](db, WithDefaultBatchSize(0)).ExistingValues(ctx, "email", nil); err == nil {
+			t.Error("Expected the option error for no values")
+		}
+	})
+}
+
+func TestSearchPrefix(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	for _, name := range []string{"Anna", "andrew", "Bob", "100% Ann", "100 Ann", "a_b", "axb", "a!b"} {
+		repo.Create(ctx, &TestUser{Name: name, Email: name + "@example.com"})
+	}
+	names := func(t *testing.T, prefix string, limit int) string {
+		t.Helper()
+		users, err := repo.SearchPrefix(ctx, "Name", prefix, limit)
+		if err != nil {
+			t.Fatalf("SearchPrefix failed: %v", err)
+		}
+		var found []string
+		for _, u := range users {
+			found = append(found, u.Name)
+		}
+		return fmt.Sprint(found)
+	}
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   string
+	}{
+		{"an", 10, "[Anna andrew]"},
+		{"AN", 1, "[Anna]"},
+		{"100%", 10, "[100% Ann]"},
+		{"a_", 10, "[a_b]"},
+		{"a!", 10, "[a!b]"},
+		{"zz", 10, "[]"},
+	}
+	for _, tt := range tests {
+		if got := names(t, tt.prefix, tt.limit); got != tt.want {
+			t.Errorf("SearchPrefix(%q) = %s, expected %s", tt.prefix, got, tt.want)
+		}
+	}
+
+	t.Run("validates input", func(t *testing.T) {
+		if _, err := repo.SearchPrefix(ctx, "missing", "a", 10); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.SearchPrefix(ctx, "name", "a", 0); err == nil {
+			t.Error("Expected error for zero limit")
+		}
+	})
+}
+
+// TestStatus is a status history row of a TestJob
+type TestStatus struct {
+	ID        uint `gorm:"primarykey"`
+	JobID     uint
+	Status    string
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func TestFindDistinctOn(t *testing.T) {
+	db := setupTestDB(t, &TestStatus{})
+	repo := New[TestStatus](db)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	rows := []TestStatus{
+		{JobID: 1, Status: "pending", CreatedAt: base},
+		{JobID: 1, Status: "running", CreatedAt: base.Add(time.Minute)},
+		{JobID: 2, Status: "pending", CreatedAt: base},
+		{JobID: 2, Status: "failed", CreatedAt: base.Add(2 * time.Minute)},
+		{JobID: 2, Status: "retried", CreatedAt: base.Add(3 * time.Minute)},
+	}
+	for i := range rows {
+		repo.Create(ctx, &rows[i])
+	}
+	repo.Delete(ctx, &rows[4])
+
+	t.Run("returns the first row per group", func(t *testing.T) {
+		latest, err := repo.FindDistinctOn(ctx, []string{"JobID"}, "created_at DESC", nil)
+		if err != nil {
+			t.Fatalf("FindDistinctOn failed: %v", err)
+		}
+		if len(latest) != 2 || latest[0].Status != "running" || latest[1].Status != "failed" {
+			t.Errorf("Expected [running failed], got %+v", latest)
+		}
+	})
+
+	t.Run("includes deleted rows on WithDeleted", func(t *testing.T) {
+		latest, err := repo.WithDeleted().FindDistinctOn(ctx, []string{"job_id"}, "created_at DESC", nil)
+		if err != nil {
+			t.Fatalf("FindDistinctOn failed: %v", err)
+		}
+		if len(latest) != 2 || latest[1].Status != "retried" {
+			t.Errorf("Expected the deleted retried row, got %+v", latest)
+		}
+	})
+
+	t.Run("applies the condition", func(t *testing.T) {
+		latest, err := repo.FindDistinctOn(ctx, []string{"job_id"}, "created_at DESC", "status = ?", "pending")
+		if err != nil {
+			t.Fatalf("FindDistinctOn failed: %v", err)
+		}
+		if len(latest) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(latest))
+		}
+	})
+
+	t.Run("uses DISTINCT ON on postgres", func(t *testing.T) {
+		var sql string
+		pg := setupDryRunDB(t, postgres.Open("host=localhost"), func(s string) { sql = s })
+		New[TestStatus](pg).FindDistinctOn(ctx, []string{"job_id"}, "created_at DESC", nil)
+
+		want := `SELECT DISTINCT ON ("test_statuses"."job_id") "test_statuses".* FROM "test_statuses" WHERE "test_statuses"."deleted_at" IS NULL ORDER BY "test_statuses"."job_id","test_statuses"."created_at" DESC`
+		if sql != want {
+			t.Errorf("Expected %s, got %s", want, sql)
+		}
+	})
+
+	t.Run("validates columns", func(t *testing.T) {
+		if _, err := repo.FindDistinctOn(ctx, []string{"missing"}, "created_at", nil); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.FindDistinctOn(ctx, nil, "created_at", nil); err == nil {
+			t.Error("Expected error for empty distinct columns")
+		}
+	})
+}
+
+func TestFindTopNPerGroup(t *testing.T) {
+	db := setupTestDB(t, &TestStatus{})
+	repo := New[TestStatus](db)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	rows := []TestStatus{
+		{JobID: 1, Status: "a", CreatedAt: base},
+		{JobID: 1, Status: "b", CreatedAt: base.Add(time.Minute)},
+		{JobID: 1, Status: "c", CreatedAt: base.Add(2 * time.Minute)},
+		{JobID: 2, Status: "d", CreatedAt: base},
+		{JobID: 2, Status: "e", CreatedAt: base.Add(time.Minute)},
+		{JobID: 2, Status: "f", CreatedAt: base.Add(2 * time.Minute)},
+		{JobID: 3, Status: "g", CreatedAt: base},
+	}
+	for i := range rows {
+		repo.Create(ctx, &rows[i])
+	}
+	repo.Delete(ctx, &rows[5])
+
+	statuses := func(rows []TestStatus) string {
+		var s []string
+		for _, row := range rows {
+			s = append(s, row.Status)
+		}
+		return strings.Join(s, " ")
+	}
+
+	tests := []struct {
+		name string
+		desc bool
+		want string
+	}{
+		{"ascending", false, "a b d e g"},
+		{"descending", true, "c b e d g"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top, err := repo.FindTopNPerGroup(ctx, "job_id", "CreatedAt", 2, tt.desc)
+			if err != nil {
+				t.Fatalf("FindTopNPerGroup failed: %v", err)
+			}
+			if got := statuses(top); got != tt.want {
+				t.Errorf("Expected [%s], got [%s]", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("validates arguments", func(t *testing.T) {
+		if _, err := repo.FindTopNPerGroup(ctx, "missing", "created_at", 2, false); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.FindTopNPerGroup(ctx, "job_id", "missing", 2, false); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if _, err := repo.FindTopNPerGroup(ctx, "job_id", "created_at", 0, false); err == nil {
+			t.Error("Expected error for non-positive n")
+		}
+	})
+}
+
+func TestFindWithSelect(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	repo.Create(ctx, &TestUser{Name: "Ann", Email: "ann@example.com", Age: 30})
+	repo.Create(ctx, &TestUser{Name: "Bob", Email: "bob@example.com", Age: 40})
+
+	type userWithLabel struct {
+		TestUser  `gorm:"embedded"`
+		Label     string
+		AgeInDays int `gorm:"column:days"`
+	}
+
+	rows, err := FindWithSelect[userWithLabel](ctx, repo, "*, name || ' <' || email || '>' AS label, age * 365 AS days", "age > ?", 35)
+	if err != nil {
+		t.Fatalf("FindWithSelect failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(rows))
+	}
+	if rows[0].Name != "Bob" || rows[0].ID == 0 {
+		t.Errorf("Expected the embedded user Bob, got %+v", rows[0].TestUser)
+	}
+	if rows[0].Label != "Bob <bob@example.com>" {
+		t.Errorf("Expected computed label, got %q", rows[0].Label)
+	}
+	if rows[0].AgeInDays != 40*365 {
+		t.Errorf("Expected %d days, got %d", 40*365, rows[0].AgeInDays)
+	}
+
+	t.Run("projects into a narrow struct", func(t *testing.T) {
+		type nameOnly struct {
+			Name string
+		}
+		names, err := FindWithSelect[nameOnly](ctx, repo, "name", nil)
+		if err != nil {
+			t.Fatalf("FindWithSelect failed: %v", err)
+		}
+		if len(names) != 2 || names[0].Name != "Ann" {
+			t.Errorf("Expected both names, got %+v", names)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/modsynth/db-module/internal/dberr"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// WithPrimary returns a repository whose statements are all routed to the
+// primary database when a dbresolver read/write split is configured, and
+// behaves like the original repository otherwise.
+//
+// Replicas may lag behind the primary, so a read issued right after a write
+// can miss it. Use WithPrimary for the reads that follow a write in the same
+// flow (for example for the rest of the request, or for a few seconds after a
+// user updates their own data) to keep read-your-writes consistency.
+func (r *Repository[T]) WithPrimary() *Repository[T] {
+	return r.withScope(func(tx *gorm.DB) *gorm.DB {
+		return tx.Clauses(dbresolver.Write)
+	})
+}
+
+// FindByIDResilient finds a record by ID like FindByID, reading from a
+// replica when a dbresolver read/write split is configured, and retries the
+// read on the primary when the replica cannot be reached, for critical reads
+// that should survive a partial replica outage. Only connection errors fall
+// back: gorm.ErrRecordNotFound and other errors from a reachable replica are
+// returned as is. Without dbresolver it is FindByID.
+func (r *Repository[T]) FindByIDResilient(ctx context.Context, id interface{}, entity *T) error {
+	ctx = r.checkDeadline(ctx)
+	err := r.FindByID(ctx, id, entity)
+	if !dberr.IsConnection(err) || r.db.Callback().Query().Get("gorm:db_resolver") == nil {
+		return err
+	}
+	return r.WithPrimary().FindByID(ctx, id, entity)
+}
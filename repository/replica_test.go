@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// setupReplicatedDB returns a database whose reads go to a separate replica
+// that never receives the primary's writes
+func setupReplicatedDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "primary.db")
+	replica := filepath.Join(dir, "replica.db")
+
+	for _, path := range []string{primary, replica} {
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("Failed to connect to test database: %v", err)
+		}
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatalf("Failed to migrate test schema: %v", err)
+		}
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+
+	db, err := gorm.Open(sqlite.Open(primary), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	err = db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{sqlite.Open(replica)},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to register dbresolver: %v", err)
+	}
+
+	return db
+}
+
+func TestWithPrimary(t *testing.T) {
+	db := setupReplicatedDB(t, &TestUser{})
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Writer", Email: "writer@example.com", Age: 30}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("default reads go to the replica", func(t *testing.T) {
+		var found TestUser
+		if err := repo.FindByID(ctx, user.ID, &found); err == nil {
+			t.Error("Expected stale replica to miss the new user")
+		}
+	})
+
+	t.Run("forced reads go to the primary", func(t *testing.T) {
+		var found TestUser
+		if err := repo.WithPrimary().FindByID(ctx, user.ID, &found); err != nil {
+			t.Fatalf("Failed to find user on primary: %v", err)
+		}
+		if found.Email != user.Email {
+			t.Errorf("Expected email %s, got %s", user.Email, found.Email)
+		}
+	})
+}
+
+func TestFindByIDResilient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("falls back to the primary when the replica is unreachable", func(t *testing.T) {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "primary.db")), &gorm.Config{DisableAutomaticPing: true})
+		if err != nil {
+			t.Fatalf("Failed to connect to test database: %v", err)
+		}
+		if err := db.AutoMigrate(&TestUser{}); err != nil {
+			t.Fatalf("Failed to migrate test schema: %v", err)
+		}
+		// Nothing listens on port 1, so every replica read is refused
+		err = db.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: []gorm.Dialector{postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")},
+		}))
+		if err != nil {
+			t.Fatalf("Failed to register dbresolver: %v", err)
+		}
+		repo := New[TestUser](db)
+
+		user := &TestUser{Name: "Critical", Email: "critical@example.com"}
+		if err := repo.Create(ctx, user); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		var found TestUser
+		if err := repo.FindByID(ctx, user.ID, &found); err == nil {
+			t.Fatal("Expected the plain read to fail on the unreachable replica")
+		}
+		if err := repo.FindByIDResilient(ctx, user.ID, &found); err != nil {
+			t.Fatalf("FindByIDResilient failed: %v", err)
+		}
+		if found.Email != user.Email {
+			t.Errorf("Expected email %s, got %s", user.Email, found.Email)
+		}
+	})
+
+	t.Run("does not fall back for missing records", func(t *testing.T) {
+		db := setupReplicatedDB(t, &TestUser{})
+		repo := New[TestUser](db)
+
+		user := &TestUser{Name: "Fresh", Email: "fresh@example.com"}
+		repo.Create(ctx, user)
+
+		var found TestUser
+		if err := repo.FindByIDResilient(ctx, user.ID, &found); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected the replica's not found, got %v", err)
+		}
+	})
+
+	t.Run("is FindByID without replicas", func(t *testing.T) {
+		repo := New[TestUser](setupTestDB(t))
+		user := &TestUser{Name: "Single", Email: "single@example.com"}
+		repo.Create(ctx, user)
+
+		var found TestUser
+		if err := repo.FindByIDResilient(ctx, user.ID, &found); err != nil || found.ID != user.ID {
+			t.Errorf("Expected user %d, got %+v, %v", user.ID, found, err)
+		}
+	})
+}
@@ -2,92 +2,424 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
 
+	"github.com/modsynth/db-module/internal/dialect"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrInvalidColumn is returned when a column name does not belong to the model
+	ErrInvalidColumn = errors.New("invalid column")
+	// ErrDeleteNotConfirmed is returned by DeleteAll when it is called without
+	// confirmation
+	ErrDeleteNotConfirmed = errors.New("delete all records not confirmed")
 )
 
 // Repository provides generic CRUD operations
 type Repository[T any] struct {
-	db *gorm.DB
+	db       *gorm.DB
+	opts     options
+	scopes   []func(*gorm.DB) *gorm.DB
+	preloads []string
+	unscoped bool
+	err      error
+
+	countCache *countCache
 }
 
 // New creates a new repository instance
-func New[T any](db *gorm.DB) *Repository[T] {
-	return &Repository[T]{db: db}
+func New[T any](db *gorm.DB, opts ...Option) *Repository[T] {
+	r := &Repository[T]{db: db, countCache: &countCache{}}
+	for _, opt := range opts {
+		opt(&r.opts)
+	}
+	r.err = r.opts.err
+	return r
+}
+
+// Dialect returns the normalized name of the database dialect: "postgres",
+// "mysql" or "sqlite", or the dialector's own name for other drivers
+func (r *Repository[T]) Dialect() string {
+	return dialect.Of(r.db)
+}
+
+// conn returns a session bound to ctx with the repository's scopes applied
+func (r *Repository[T]) conn(ctx context.Context) *gorm.DB {
+	tx := r.db.WithContext(ctx)
+	if r.err != nil {
+		tx.AddError(r.err)
+	}
+	if len(r.scopes) > 0 {
+		tx = tx.Scopes(r.scopes...)
+	}
+	return tx
+}
+
+// sideTable returns a session on table, such as the delete archive, that runs
+// in the connection or transaction of tx but without the repository's scopes,
+// which address T's own table
+func sideTable(tx *gorm.DB, table string) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true}).Table(table)
+}
+
+// withScope returns a copy of the repository with an additional scope
+func (r *Repository[T]) withScope(scope func(*gorm.DB) *gorm.DB) *Repository[T] {
+	clone := *r
+	clone.scopes = append(append([]func(*gorm.DB) *gorm.DB(nil), r.scopes...), scope)
+	clone.countCache = &countCache{}
+	return &clone
+}
+
+// withError returns a copy of the repository whose calls all fail with err
+func (r *Repository[T]) withError(err error) *Repository[T] {
+	clone := *r
+	if clone.err == nil {
+		clone.err = err
+	}
+	return &clone
+}
+
+// schema returns the parsed GORM schema of T
+func (r *Repository[T]) schema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// column resolves a field or column name of T to its database column name
+func (r *Repository[T]) column(name string) (string, error) {
+	s, err := r.schema()
+	if err != nil {
+		return "", err
+	}
+
+	field := s.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidColumn, name)
+	}
+	return field.DBName, nil
+}
+
+// columns resolves each name with column
+func (r *Repository[T]) columns(names []string) ([]string, error) {
+	cols := make([]string, len(names))
+	for i, name := range names {
+		col, err := r.column(name)
+		if err != nil {
+			return nil, err
+		}
+		cols[i] = col
+	}
+	return cols, nil
+}
+
+// primaryKey returns the model's primary key field
+func primaryKey(s *schema.Schema) (*schema.Field, error) {
+	if s.PrioritizedPrimaryField == nil {
+		return nil, gorm.ErrPrimaryKeyRequired
+	}
+	return s.PrioritizedPrimaryField, nil
 }
 
 // Create creates a new record
 func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Create(entity).Error
+	ctx = r.checkDeadline(ctx)
+	defer r.invalidateCount()
+	return r.conn(ctx).Create(entity).Error
 }
 
-// FindByID finds a record by ID
+// FindByID finds a record by ID. With a context from WithIdentityMap, later
+// calls for the same ID return the record loaded first without querying.
 func (r *Repository[T]) FindByID(ctx context.Context, id interface{}, entity *T) error {
-	return r.db.WithContext(ctx).First(entity, id).Error
+	ctx = r.checkDeadline(ctx)
+	if r.recall(ctx, id, entity) {
+		return nil
+	}
+	if err := r.preload(r.conn(ctx)).First(entity, id).Error; err != nil {
+		return err
+	}
+	r.remember(ctx, id, entity)
+	return nil
 }
 
 // FindAll finds all records
 func (r *Repository[T]) FindAll(ctx context.Context) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.findAll(ctx, r.conn(ctx))
+}
+
+// findAll finds every record tx selects and reports a large result
+func (r *Repository[T]) findAll(ctx context.Context, tx *gorm.DB) ([]T, error) {
 	var entities []T
-	err := r.db.WithContext(ctx).Find(&entities).Error
-	return entities, err
+	tx = tx.Find(&entities)
+	if tx.Error == nil {
+		r.warnLargeResult(ctx, tx.Statement.Table, len(entities))
+	}
+	return entities, tx.Error
+}
+
+// warnLargeResult reports a result of rows rows above the threshold set with
+// WithLargeResultWarning
+func (r *Repository[T]) warnLargeResult(ctx context.Context, table string, rows int) {
+	threshold := r.opts.largeResultThreshold
+	if threshold <= 0 || rows <= threshold {
+		return
+	}
+	r.db.Logger.Warn(ctx, "FindAll on %s returned %d rows, above the warning threshold of %d; consider paginating", table, rows, threshold)
+	if r.opts.onLargeResult != nil {
+		r.opts.onLargeResult(ctx, table, rows)
+	}
 }
 
 // Update updates a record
 func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Save(entity).Error
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	if r.opts.onChange != nil {
+		return r.trackedUpdate(ctx, entity)
+	}
+	if r.opts.versioning {
+		return r.versionedUpdate(ctx, entity)
+	}
+	return r.conn(ctx).Save(entity).Error
 }
 
 // Delete deletes a record
 func (r *Repository[T]) Delete(ctx context.Context, entity *T) error {
-	return r.db.WithContext(ctx).Delete(entity).Error
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	if r.opts.deleteArchive {
+		_, err := r.archiveDelete(ctx, entity)
+		return err
+	}
+	return r.conn(ctx).Delete(entity).Error
 }
 
 // DeleteByID deletes a record by ID
 func (r *Repository[T]) DeleteByID(ctx context.Context, id interface{}) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
 	var entity T
-	return r.db.WithContext(ctx).Delete(&entity, id).Error
+	if r.opts.deleteArchive {
+		_, err := r.archiveDelete(ctx, &entity, id)
+		return err
+	}
+	return r.conn(ctx).Delete(&entity, id).Error
+}
+
+// DeleteAll deletes every record of T's table, or of the repository's
+// scopes, and returns the number of rows deleted. It only runs when confirm
+// is true and fails with ErrDeleteNotConfirmed otherwise, so a full-table
+// delete in tests or admin tooling is explicit in the calling code rather
+// than hidden behind an always-true condition. Models with a gorm.DeletedAt
+// field are soft-deleted, unless called on WithDeleted(). With
+// WithDeleteArchive, hard-deleted rows are archived first, which loads them
+// all into memory.
+func (r *Repository[T]) DeleteAll(ctx context.Context, confirm bool) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	if !confirm {
+		return 0, ErrDeleteNotConfirmed
+	}
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	var entity T
+	if r.opts.deleteArchive {
+		return r.archiveDelete(ctx, &entity, "1 = 1")
+	}
+	result := r.conn(ctx).Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&entity)
+	return result.RowsAffected, result.Error
+}
+
+// DeleteReturning deletes the record with the given ID like DeleteByID and
+// returns it as stored just before the delete, e.g. to echo it back in an
+// audit response. The row is locked with FindByIDForUpdate and deleted in the
+// same transaction, so no concurrent write lands between the read and the
+// delete. It returns gorm.ErrRecordNotFound when there is no such record.
+func (r *Repository[T]) DeleteReturning(ctx context.Context, id interface{}) (T, error) {
+	ctx = r.checkDeadline(ctx)
+	var entity T
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		bound := *r
+		bound.db = tx
+		if err := bound.FindByIDForUpdate(ctx, id, &entity); err != nil {
+			return err
+		}
+		return bound.DeleteByID(ctx, id)
+	})
+	return entity, err
 }
 
 // Count counts all records
 func (r *Repository[T]) Count(ctx context.Context) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	var count int64
+	var entity T
+	err := r.conn(ctx).Model(&entity).Count(&count).Error
+	return count, err
+}
+
+// CountWhere counts records matching the condition, with the condition
+// handling of FindWhere: an IN with an empty slice argument counts 0 without
+// querying, and Order values among args are ignored
+func (r *Repository[T]) CountWhere(ctx context.Context, query interface{}, args ...interface{}) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	args, _ = splitOrders(args)
+	if hasEmptyIn(query, args) {
+		return 0, r.err
+	}
+
 	var count int64
 	var entity T
-	err := r.db.WithContext(ctx).Model(&entity).Count(&count).Error
+	err := r.conn(ctx).Model(&entity).Where(query, args...).Count(&count).Error
 	return count, err
 }
 
-// FindWhere finds records matching the condition
+// Exists reports whether a record matches the condition, selecting 1 with
+// LIMIT 1 instead of loading a record. An empty condition matches any record.
+// Soft-deleted records are excluded unless called on WithDeleted().
+func (r *Repository[T]) Exists(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.exists(r.conn(ctx), query, args)
+}
+
+// ExistsByID reports whether a record with the given ID exists, like Exists
+func (r *Repository[T]) ExistsByID(ctx context.Context, id interface{}) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return false, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return false, err
+	}
+	return r.exists(r.conn(ctx), pkCondition(pk, id), nil)
+}
+
+// FindWhere finds records matching the condition. A condition that uses IN
+// with an empty slice argument, such as "id IN ?" with no IDs, matches nothing
+// and returns an empty result without querying. Order values among args are
+// not bound to the condition but sort the result, in the order given.
 func (r *Repository[T]) FindWhere(ctx context.Context, query interface{}, args ...interface{}) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	args, orders := splitOrders(args)
+	if hasEmptyIn(query, args) {
+		return []T{}, r.err
+	}
+
+	tx := r.conn(ctx)
+	if len(orders) > 0 {
+		order, err := r.orderBy(orders)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Order(order)
+	}
+
 	var entities []T
-	err := r.db.WithContext(ctx).Where(query, args...).Find(&entities).Error
+	err := tx.Where(query, args...).Find(&entities).Error
 	return entities, err
 }
 
 // FirstWhere finds the first record matching the condition
 func (r *Repository[T]) FirstWhere(ctx context.Context, entity *T, query interface{}, args ...interface{}) error {
-	return r.db.WithContext(ctx).Where(query, args...).First(entity).Error
+	ctx = r.checkDeadline(ctx)
+	return r.preload(r.conn(ctx)).Where(query, args...).First(entity).Error
 }
 
-// Paginate returns paginated results
-func (r *Repository[T]) Paginate(ctx context.Context, page, pageSize int) ([]T, int64, error) {
-	var entities []T
-	var total int64
-	var entity T
+// Transaction executes operations within a transaction
+func (r *Repository[T]) Transaction(ctx context.Context, fn func(*gorm.DB) error) error {
+	ctx = r.checkDeadline(ctx)
+	return r.db.WithContext(ctx).Transaction(fn)
+}
+
+// ReadTransaction runs fn in a read-only transaction for reports that issue
+// several queries and need them to agree with each other. Any write inside fn
+// fails and the transaction is rolled back.
+//
+// On postgres and mysql the transaction is opened READ ONLY with REPEATABLE
+// READ isolation, so every query in fn reads from the same snapshot, taken at
+// the first query, and the database can skip write bookkeeping. SQLite ignores
+// transaction options, so there writes are blocked with PRAGMA query_only for
+// the duration of fn instead; its transactions are serializable, so reads are
+// consistent as well. Called on a repository bound to a transaction, fn runs
+// in a savepoint of that transaction and has only its guarantees.
+func (r *Repository[T]) ReadTransaction(ctx context.Context, fn func(*gorm.DB) error) error {
+	ctx = r.checkDeadline(ctx)
+	if r.Dialect() != dialect.SQLite {
+		opts := &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead}
+		return r.db.WithContext(ctx).Transaction(fn, opts)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		if err := tx.Exec("PRAGMA query_only = ON").Error; err != nil {
+			return err
+		}
+		defer func() {
+			if resetErr := tx.Exec("PRAGMA query_only = OFF").Error; err == nil {
+				err = resetErr
+			}
+		}()
+		return fn(tx)
+	})
+}
 
-	// Get total count
-	if err := r.db.WithContext(ctx).Model(&entity).Count(&total).Error; err != nil {
-		return nil, 0, err
+// TransactionWithDeadline runs fn in a transaction that must finish within d.
+// The deadline covers the whole unit of work rather than each statement:
+// every statement run on the *gorm.DB passed to fn sees only the remaining
+// budget, and once it is used up the next statement fails and the transaction
+// is rolled back. The returned error then wraps context.DeadlineExceeded.
+func (r *Repository[T]) TransactionWithDeadline(ctx context.Context, d time.Duration, fn func(*gorm.DB) error) error {
+	if d <= 0 {
+		return fmt.Errorf("transaction deadline must be positive, got %s", d)
 	}
 
-	// Get paginated results
-	offset := (page - 1) * pageSize
-	err := r.db.WithContext(ctx).Offset(offset).Limit(pageSize).Find(&entities).Error
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
 
-	return entities, total, err
+	err := r.db.WithContext(ctx).Transaction(fn)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	return err
 }
 
-// Transaction executes operations within a transaction
-func (r *Repository[T]) Transaction(ctx context.Context, fn func(*gorm.DB) error) error {
-	return r.db.WithContext(ctx).Transaction(fn)
+// TransactionWithRetry runs fn in a transaction like Transaction and runs it
+// again in a new transaction, up to attempts times in all, when the database
+// aborts it as a deadlock victim or for a serialization failure, errors that
+// concurrent transactions cause and that a retry usually gets past. fn must
+// be safe to run more than once: it must not have effects outside the
+// database, or must undo them itself. Retries wait a short, growing, jittered
+// delay and stop when ctx is done. The last error is returned.
+func (r *Repository[T]) TransactionWithRetry(ctx context.Context, attempts int, fn func(*gorm.DB) error) error {
+	if attempts <= 0 {
+		return fmt.Errorf("transaction attempts must be positive, got %d", attempts)
+	}
+	ctx = r.checkDeadline(ctx)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = r.db.WithContext(ctx).Transaction(fn)
+		if err == nil || attempt == attempts || !isRetryableTxError(err) {
+			return err
+		}
+
+		delay := time.Duration(attempt) * 10 * time.Millisecond
+		delay += time.Duration(rand.Int64N(int64(delay)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
 }
@@ -2,10 +2,17 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // TestUser is a test entity
@@ -16,7 +23,7 @@ type TestUser struct {
 	Age   int
 }
 
-func setupTestDB(t *testing.T) *gorm.DB {
+func setupTestDB(t testing.TB, models ...interface{}) *gorm.DB {
 	t.Helper()
 
 	// Use SQLite in-memory database for testing
@@ -26,13 +33,40 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate the test schema
-	if err := db.AutoMigrate(&TestUser{}); err != nil {
+	if err := db.AutoMigrate(append([]interface{}{&TestUser{}}, models...)...); err != nil {
 		t.Fatalf("Failed to migrate test schema: %v", err)
 	}
 
 	return db
 }
 
+// sqlRecorder is a logger that reports the SQL of every statement
+type sqlRecorder struct {
+	logger.Interface
+	record func(sql string)
+}
+
+func (l sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.record(sql)
+}
+
+// setupDryRunDB opens dialector in dry-run mode, without connecting, and
+// passes the SQL of every statement to record
+func setupDryRunDB(t testing.TB, dialector gorm.Dialector, record func(sql string)) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               sqlRecorder{Interface: logger.Discard, record: record},
+	})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run database: %v", err)
+	}
+	return db
+}
+
 func TestNew(t *testing.T) {
 	db := setupTestDB(t)
 	repo := New[TestUser](db)
@@ -150,6 +184,52 @@ func TestFindAll(t *testing.T) {
 			t.Errorf("Expected 3 users, got %d", len(foundUsers))
 		}
 	})
+
+	t.Run("warns about large results", func(t *testing.T) {
+		warnings := &warnRecorder{Interface: logger.Discard}
+		var exceeded []int
+		warned := New[TestUser](db.Session(&gorm.Session{Logger: warnings}), WithLargeResultWarning(2, func(ctx context.Context, table string, rows int) {
+			exceeded = append(exceeded, rows)
+		}))
+
+		foundUsers, err := warned.FindAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to find all users: %v", err)
+		}
+		if len(foundUsers) != 3 {
+			t.Errorf("Expected all 3 users to be returned, got %d", len(foundUsers))
+		}
+		if len(warnings.messages) != 1 || !strings.Contains(warnings.messages[0], "test_users returned 3 rows") {
+			t.Errorf("Expected one warning naming the table and row count, got %v", warnings.messages)
+		}
+		if len(exceeded) != 1 || exceeded[0] != 3 {
+			t.Errorf("Expected the callback to get 3 rows, got %v", exceeded)
+		}
+
+		quiet := New[TestUser](db.Session(&gorm.Session{Logger: warnings}), WithLargeResultWarning(3, nil))
+		if _, err := quiet.FindAll(ctx); err != nil {
+			t.Fatalf("Failed to find all users: %v", err)
+		}
+		if len(warnings.messages) != 1 {
+			t.Errorf("Expected no warning at the threshold, got %v", warnings.messages)
+		}
+	})
+
+	t.Run("rejects a non-positive warning threshold", func(t *testing.T) {
+		if _, err := New[TestUser](db, WithLargeResultWarning(0, nil)).FindAll(ctx); err == nil {
+			t.Error("Expected an error for a zero threshold")
+		}
+	})
+}
+
+// warnRecorder is a logger that records its warnings
+type warnRecorder struct {
+	logger.Interface
+	messages []string
+}
+
+func (l *warnRecorder) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(msg, args...))
 }
 
 func TestUpdate(t *testing.T) {
@@ -240,6 +320,151 @@ func TestDeleteByID(t *testing.T) {
 	})
 }
 
+func TestDeleteReturning(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Departing", Email: "departing@example.com", Age: 40}
+	repo.Create(ctx, user)
+
+	t.Run("returns the deleted record", func(t *testing.T) {
+		deleted, err := repo.DeleteReturning(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("DeleteReturning failed: %v", err)
+		}
+		if deleted != *user {
+			t.Errorf("Expected %+v, got %+v", *user, deleted)
+		}
+
+		var found TestUser
+		if err := repo.FindByID(ctx, user.ID, &found); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected the record to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		if _, err := repo.DeleteReturning(ctx, user.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
+
+func TestDeleteAll(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	ctx := context.Background()
+
+	t.Run("requires confirmation", func(t *testing.T) {
+		repo := New[TestUser](db)
+		repo.Create(ctx, &TestUser{Name: "Kept", Email: "kept@example.com"})
+
+		if _, err := repo.DeleteAll(ctx, false); !errors.Is(err, ErrDeleteNotConfirmed) {
+			t.Errorf("Expected ErrDeleteNotConfirmed, got %v", err)
+		}
+		if count, _ := repo.Count(ctx); count != 1 {
+			t.Errorf("Expected the record to be kept, got %d records", count)
+		}
+	})
+
+	t.Run("deletes every record", func(t *testing.T) {
+		repo := New[TestUser](db)
+		repo.Create(ctx, &TestUser{Name: "Second", Email: "second@example.com"})
+
+		deleted, err := repo.DeleteAll(ctx, true)
+		if err != nil {
+			t.Fatalf("DeleteAll failed: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("Expected 2 records deleted, got %d", deleted)
+		}
+		if count, _ := repo.Count(ctx); count != 0 {
+			t.Errorf("Expected no records left, got %d", count)
+		}
+	})
+
+	t.Run("soft-deletes soft-delete models", func(t *testing.T) {
+		repo := New[TestSoftUser](db)
+		repo.Create(ctx, &TestSoftUser{Name: "Soft", Email: "soft@example.com"})
+
+		if _, err := repo.DeleteAll(ctx, true); err != nil {
+			t.Fatalf("DeleteAll failed: %v", err)
+		}
+		if count, _ := repo.WithDeleted().Count(ctx); count != 1 {
+			t.Errorf("Expected the soft-deleted record to remain, got %d records", count)
+		}
+	})
+
+	t.Run("archives with WithDeleteArchive", func(t *testing.T) {
+		repo := New[TestUser](db, WithDeleteArchive())
+		if err := repo.EnableDeleteArchive(ctx); err != nil {
+			t.Fatalf("EnableDeleteArchive failed: %v", err)
+		}
+		repo.Create(ctx, &TestUser{Name: "Archived", Email: "archived@example.com"})
+
+		deleted, err := repo.DeleteAll(ctx, true)
+		if err != nil {
+			t.Fatalf("DeleteAll failed: %v", err)
+		}
+		if deleted != 1 {
+			t.Errorf("Expected 1 record deleted, got %d", deleted)
+		}
+		var archived int64
+		db.Table("test_users_archive").Count(&archived)
+		if archived != 1 {
+			t.Errorf("Expected 1 archived record, got %d", archived)
+		}
+	})
+}
+
+func TestExistsByID(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Present", Email: "present@example.com", Age: 30}
+	repo.Create(ctx, user)
+
+	t.Run("reports matching records", func(t *testing.T) {
+		exists, err := repo.Exists(ctx, "email = ?", "present@example.com")
+		if err != nil {
+			t.Fatalf("Exists failed: %v", err)
+		}
+		if !exists {
+			t.Error("Expected a match")
+		}
+	})
+
+	t.Run("returns false without error when nothing matches", func(t *testing.T) {
+		exists, err := repo.Exists(ctx, "email = ?", "absent@example.com")
+		if err != nil || exists {
+			t.Errorf("Expected false, nil, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("looks up by ID", func(t *testing.T) {
+		if exists, err := repo.ExistsByID(ctx, user.ID); err != nil || !exists {
+			t.Errorf("Expected true, nil, got %v, %v", exists, err)
+		}
+		if exists, err := repo.ExistsByID(ctx, 9999); err != nil || exists {
+			t.Errorf("Expected false, nil, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("excludes soft-deleted records", func(t *testing.T) {
+		soft := New[TestSoftUser](db)
+		deleted := &TestSoftUser{Name: "Deleted", Email: "deleted@example.com"}
+		soft.Create(ctx, deleted)
+		soft.Delete(ctx, deleted)
+
+		if exists, _ := soft.ExistsByID(ctx, deleted.ID); exists {
+			t.Error("Expected the soft-deleted record to be excluded")
+		}
+		if exists, _ := soft.WithDeleted().ExistsByID(ctx, deleted.ID); !exists {
+			t.Error("Expected WithDeleted to include the soft-deleted record")
+		}
+	})
+}
+
 func TestCount(t *testing.T) {
 	db := setupTestDB(t)
 	repo := New[TestUser](db)
@@ -264,6 +489,27 @@ func TestCount(t *testing.T) {
 			t.Errorf("Expected count 5, got %d", count)
 		}
 	})
+
+	t.Run("counts matching records", func(t *testing.T) {
+		count, err := repo.CountWhere(ctx, "age > ?", 23)
+		if err != nil {
+			t.Fatalf("CountWhere failed: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("Expected count 2, got %d", count)
+		}
+	})
+
+	t.Run("returns zero when nothing matches", func(t *testing.T) {
+		count, err := repo.CountWhere(ctx, "age > ?", 100)
+		if err != nil || count != 0 {
+			t.Errorf("Expected (0, nil), got (%d, %v)", count, err)
+		}
+		count, err = repo.CountWhere(ctx, "id IN ?", []uint{})
+		if err != nil || count != 0 {
+			t.Errorf("Expected (0, nil) for an empty IN, got (%d, %v)", count, err)
+		}
+	})
 }
 
 func TestFindWhere(t *testing.T) {
@@ -452,3 +698,141 @@ func TestTransaction(t *testing.T) {
 		}
 	})
 }
+
+func TestTransactionWithDeadline(t *testing.T) {
+	// A file database, since the connection of a timed out transaction is
+	// discarded and would take a :memory: database with it
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "deadline.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.AutoMigrate(&TestUser{})
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	t.Run("commits within the budget", func(t *testing.T) {
+		err := repo.TransactionWithDeadline(ctx, time.Second, func(tx *gorm.DB) error {
+			return tx.Create(&TestUser{Name: "Fast", Email: "fast@example.com"}).Error
+		})
+		if err != nil {
+			t.Fatalf("TransactionWithDeadline failed: %v", err)
+		}
+	})
+
+	t.Run("rolls back once the budget is spent", func(t *testing.T) {
+		err := repo.TransactionWithDeadline(ctx, 20*time.Millisecond, func(tx *gorm.DB) error {
+			if err := tx.Create(&TestUser{Name: "Slow", Email: "slow@example.com"}).Error; err != nil {
+				return err
+			}
+			time.Sleep(50 * time.Millisecond)
+			return tx.Create(&TestUser{Name: "Late", Email: "late@example.com"}).Error
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected DeadlineExceeded, got %v", err)
+		}
+
+		count, _ := repo.Count(ctx)
+		if count != 1 {
+			t.Errorf("Expected only the first transaction's row, got %d rows", count)
+		}
+	})
+
+	t.Run("rejects a non-positive deadline", func(t *testing.T) {
+		if err := repo.TransactionWithDeadline(ctx, 0, func(*gorm.DB) error { return nil }); err == nil {
+			t.Error("Expected error for zero deadline")
+		}
+	})
+}
+
+func TestReadTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	repo.Create(ctx, &TestUser{Name: "Ann", Email: "ann@example.com"})
+
+	t.Run("reads", func(t *testing.T) {
+		var count int64
+		err := repo.ReadTransaction(ctx, func(tx *gorm.DB) error {
+			return tx.Model(&TestUser{}).Count(&count).Error
+		})
+		if err != nil {
+			t.Fatalf("ReadTransaction failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 user, got %d", count)
+		}
+	})
+
+	t.Run("rejects writes", func(t *testing.T) {
+		err := repo.ReadTransaction(ctx, func(tx *gorm.DB) error {
+			return tx.Create(&TestUser{Name: "Bob", Email: "bob@example.com"}).Error
+		})
+		if err == nil {
+			t.Error("Expected the write to fail")
+		}
+	})
+
+	t.Run("leaves the connection writable", func(t *testing.T) {
+		if err := repo.Create(ctx, &TestUser{Name: "Cid", Email: "cid@example.com"}); err != nil {
+			t.Errorf("Expected writes after the read transaction to succeed, got %v", err)
+		}
+	})
+}
+
+func TestTransactionWithRetry(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	t.Run("retries deadlocks", func(t *testing.T) {
+		calls := 0
+		err := repo.TransactionWithRetry(ctx, 3, func(tx *gorm.DB) error {
+			calls++
+			if err := tx.Create(&TestUser{Name: "Retry", Email: fmt.Sprintf("retry%d@example.com", calls)}).Error; err != nil {
+				return err
+			}
+			if calls < 3 {
+				return sqlStateError("40P01")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("TransactionWithRetry failed: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 attempts, got %d", calls)
+		}
+		if users, _ := repo.FindWhere(ctx, "name = ?", "Retry"); len(users) != 1 {
+			t.Errorf("Expected only the last attempt to commit, got %d users", len(users))
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		calls := 0
+		err := repo.TransactionWithRetry(ctx, 2, func(tx *gorm.DB) error {
+			calls++
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+		})
+		if err == nil || calls != 2 {
+			t.Errorf("Expected the deadlock after 2 attempts, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("returns other errors right away", func(t *testing.T) {
+		calls := 0
+		err := repo.TransactionWithRetry(ctx, 3, func(tx *gorm.DB) error {
+			calls++
+			return sqlStateError("23505")
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("Expected one attempt, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("rejects non-positive attempts", func(t *testing.T) {
+		if err := repo.TransactionWithRetry(ctx, 0, func(*gorm.DB) error { return nil }); err == nil {
+			t.Error("Expected an error for zero attempts")
+		}
+	})
+}
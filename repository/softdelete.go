@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrSoftDeleteUnsupported is returned when a soft-delete operation is used on
+// a model without a gorm.DeletedAt field
+var ErrSoftDeleteUnsupported = errors.New("model does not support soft delete")
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// softDeleteField returns the model's gorm.DeletedAt field, or nil if it has none
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.FieldType == deletedAtType {
+			return field
+		}
+	}
+	return nil
+}
+
+// WithDeleted returns a repository whose reads include soft-deleted rows
+func (r *Repository[T]) WithDeleted() *Repository[T] {
+	clone := r.withScope(func(tx *gorm.DB) *gorm.DB {
+		return tx.Unscoped()
+	})
+	clone.unscoped = true
+	return clone
+}
+
+// OnlyDeleted returns a repository whose reads only match soft-deleted rows.
+// Every call on the returned repository fails with ErrSoftDeleteUnsupported
+// when T has no gorm.DeletedAt field.
+func (r *Repository[T]) OnlyDeleted() *Repository[T] {
+	s, err := r.schema()
+	if err != nil {
+		return r.withError(err)
+	}
+
+	field := softDeleteField(s)
+	if field == nil {
+		return r.withError(ErrSoftDeleteUnsupported)
+	}
+
+	clone := r.withScope(func(tx *gorm.DB) *gorm.DB {
+		return tx.Unscoped().Where(clause.Not(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Value:  nil,
+		}))
+	})
+	clone.unscoped = true
+	return clone
+}
+
+// ExistsActive reports whether a record that is not soft-deleted matches the
+// condition, even when called on WithDeleted(). Use it for uniqueness checks
+// where a deleted record frees the value again. For models without a
+// gorm.DeletedAt field every record is active.
+func (r *Repository[T]) ExistsActive(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return false, err
+	}
+
+	tx := r.conn(ctx)
+	if field := softDeleteField(s); field != nil {
+		tx = tx.Unscoped().Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Value:  nil,
+		})
+	}
+	return r.exists(tx, query, args)
+}
+
+// ExistsIncludingDeleted reports whether any record matches the condition,
+// soft-deleted or not. Use it for uniqueness checks where the value stays
+// taken by a deleted record, such as the email of a deleted account that
+// may still be restored, or that a unique index spanning deleted rows would
+// reject anyway.
+func (r *Repository[T]) ExistsIncludingDeleted(ctx context.Context, query interface{}, args ...interface{}) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.exists(r.conn(ctx).Unscoped(), query, args)
+}
+
+// exists reports whether tx finds a record matching the condition. An empty
+// condition matches any record.
+func (r *Repository[T]) exists(tx *gorm.DB, query interface{}, args []interface{}) (bool, error) {
+	if !isEmptyCondition(query) {
+		tx = tx.Where(query, args...)
+	}
+
+	var found []int
+	err := tx.Model(new(T)).Select("1").Limit(1).Scan(&found).Error
+	return len(found) > 0, err
+}
+
+// SoftDeleteByIDs soft-deletes the records with the given primary keys and
+// returns the number of rows deleted. Records that are already deleted are
+// not touched again. ID lists over the driver's parameter limit are deleted
+// in chunks within one transaction. Models without a gorm.DeletedAt field are
+// not hard-deleted instead; they fail with ErrSoftDeleteUnsupported.
+func (r *Repository[T]) SoftDeleteByIDs(ctx context.Context, ids []interface{}) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	if len(ids) == 0 {
+		return 0, r.err
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+	field := softDeleteField(s)
+	if field == nil {
+		return 0, ErrSoftDeleteUnsupported
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.invalidateCount()
+	now := r.db.NowFunc()
+	softDelete := func(tx *gorm.DB, chunk []interface{}) (int64, error) {
+		tx = tx.Model(new(T)).Where(clause.IN{
+			Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName},
+			Values: chunk,
+		})
+		if r.unscoped {
+			tx = tx.Where(clause.Eq{
+				Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+				Value:  nil,
+			})
+		}
+		result := tx.UpdateColumn(field.DBName, now)
+		return result.RowsAffected, result.Error
+	}
+
+	chunks := chunkValues(uniqueValues(ids), r.inChunkSize())
+	if len(chunks) == 1 {
+		return softDelete(r.conn(ctx), chunks[0])
+	}
+
+	// Delete all chunks or none
+	var deleted int64
+	err = r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range chunks {
+			n, err := softDelete(tx, chunk)
+			if err != nil {
+				return err
+			}
+			deleted += n
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// RecreateAfterSoftDelete creates entity after permanently deleting any
+// soft-deleted row with the same uniqueColumn value, in one transaction. It
+// resolves inserts that a unique index rejects because of a tombstone, such
+// as re-registering a deleted email. Live rows are never purged, so a live
+// duplicate still fails the insert.
+//
+// Purging destroys the tombstone and whatever history it carried, including
+// rows that reference it through foreign keys with ON DELETE CASCADE. Where
+// the old row must be kept, prefer an index that ignores deleted rows, e.g. a
+// partial unique index WHERE deleted_at IS NULL on postgres and sqlite.
+func (r *Repository[T]) RecreateAfterSoftDelete(ctx context.Context, entity *T, uniqueColumn string) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	deletedAt := softDeleteField(s)
+	if deletedAt == nil {
+		return ErrSoftDeleteUnsupported
+	}
+	field := s.LookUpField(uniqueColumn)
+	if field == nil || field.DBName == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidColumn, uniqueColumn)
+	}
+	value, _ := field.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+
+	defer r.invalidateCount()
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().
+			Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value}).
+			Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: deletedAt.DBName}, Value: nil}).
+			Delete(new(T)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(entity).Error
+	})
+}
+
+// HardDelete permanently deletes entity, even when T has a gorm.DeletedAt
+// field and Delete would only soft-delete it. With WithDeleteArchive the row
+// is archived first, like any other physical delete.
+func (r *Repository[T]) HardDelete(ctx context.Context, entity *T) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	unscoped := r.WithDeleted()
+	if r.opts.deleteArchive {
+		_, err := unscoped.archiveDelete(ctx, entity)
+		return err
+	}
+	return unscoped.conn(ctx).Delete(entity).Error
+}
+
+// FindAllWithDeleted finds all records, soft-deleted ones included. It is
+// WithDeleted().FindAll.
+func (r *Repository[T]) FindAllWithDeleted(ctx context.Context) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	return r.WithDeleted().FindAll(ctx)
+}
+
+// Restore undeletes the soft-deleted record with the given ID by setting its
+// gorm.DeletedAt field back to NULL. Restoring a record that is not deleted
+// does nothing. It returns gorm.ErrRecordNotFound when there is no record
+// with the ID, and ErrSoftDeleteUnsupported when T has no gorm.DeletedAt
+// field.
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	field := softDeleteField(s)
+	if field == nil {
+		return ErrSoftDeleteUnsupported
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+
+	defer r.invalidateCount()
+	result := r.conn(ctx).Unscoped().Model(new(T)).
+		Where(pkCondition(pk, id)).
+		Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}).
+		UpdateColumn(field.DBName, nil)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	exists, err := r.exists(r.conn(ctx).Unscoped(), pkCondition(pk, id), nil)
+	if err == nil && !exists {
+		err = gorm.ErrRecordNotFound
+	}
+	return err
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// TestSoftUser is a test entity with soft delete support
+type TestSoftUser struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"size:100"`
+	Email     string `gorm:"size:100"`
+	DeletedAt gorm.DeletedAt
+}
+
+func TestOnlyDeleted(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	repo := New[TestSoftUser](db)
+	ctx := context.Background()
+
+	live := &TestSoftUser{Name: "Live", Email: "live@example.com"}
+	deleted := &TestSoftUser{Name: "Deleted", Email: "deleted@example.com"}
+	repo.Create(ctx, live)
+	repo.Create(ctx, deleted)
+	repo.Delete(ctx, deleted)
+
+	t.Run("default reads exclude deleted rows", func(t *testing.T) {
+		users, err := repo.FindAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to find users: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != live.ID {
+			t.Errorf("Expected only the live user, got %+v", users)
+		}
+	})
+
+	t.Run("only deleted returns deleted rows", func(t *testing.T) {
+		users, err := repo.OnlyDeleted().FindAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to find deleted users: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != deleted.ID {
+			t.Errorf("Expected only the deleted user, got %+v", users)
+		}
+
+		count, err := repo.OnlyDeleted().Count(ctx)
+		if err != nil {
+			t.Fatalf("Failed to count deleted users: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected count 1, got %d", count)
+		}
+	})
+
+	t.Run("with deleted returns all rows", func(t *testing.T) {
+		users, err := repo.WithDeleted().FindAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to find users: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("Expected 2 users, got %d", len(users))
+		}
+	})
+
+	t.Run("returns error for model without soft delete", func(t *testing.T) {
+		_, err := New[TestUser](db).OnlyDeleted().FindAll(ctx)
+		if !errors.Is(err, ErrSoftDeleteUnsupported) {
+			t.Errorf("Expected ErrSoftDeleteUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestSoftDeleteByIDs(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	repo := New[TestSoftUser](db)
+	ctx := context.Background()
+
+	var ids []interface{}
+	for _, name := range []string{"a", "b", "c"} {
+		user := &TestSoftUser{Name: name, Email: name + "@example.com"}
+		repo.Create(ctx, user)
+		ids = append(ids, user.ID)
+	}
+
+	t.Run("soft-deletes the given rows", func(t *testing.T) {
+		deleted, err := repo.SoftDeleteByIDs(ctx, ids[:2])
+		if err != nil {
+			t.Fatalf("SoftDeleteByIDs failed: %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("Expected 2 rows deleted, got %d", deleted)
+		}
+
+		live, _ := repo.Count(ctx)
+		all, _ := repo.WithDeleted().Count(ctx)
+		if live != 1 || all != 3 {
+			t.Errorf("Expected 1 live of 3 rows, got %d of %d", live, all)
+		}
+	})
+
+	t.Run("skips rows already deleted", func(t *testing.T) {
+		deleted, err := repo.WithDeleted().SoftDeleteByIDs(ctx, ids)
+		if err != nil {
+			t.Fatalf("SoftDeleteByIDs failed: %v", err)
+		}
+		if deleted != 1 {
+			t.Errorf("Expected 1 row deleted, got %d", deleted)
+		}
+	})
+
+	t.Run("short-circuits empty ids", func(t *testing.T) {
+		deleted, err := repo.SoftDeleteByIDs(ctx, nil)
+		if err != nil || deleted != 0 {
+			t.Errorf("Expected (0, nil), got (%d, %v)", deleted, err)
+		}
+	})
+
+	t.Run("returns error for model without soft delete", func(t *testing.T) {
+		userRepo := New[TestUser](db)
+		user := &TestUser{Name: "Hard", Email: "hard@example.com"}
+		userRepo.Create(ctx, user)
+
+		_, err := userRepo.SoftDeleteByIDs(ctx, []interface{}{user.ID})
+		if !errors.Is(err, ErrSoftDeleteUnsupported) {
+			t.Errorf("Expected ErrSoftDeleteUnsupported, got %v", err)
+		}
+		if count, _ := userRepo.Count(ctx); count != 1 {
+			t.Errorf("Expected the row to be kept, got count %d", count)
+		}
+	})
+}
+
+// TestMember is a soft-delete test entity with a unique email
+type TestMember struct {
+	ID        uint   `gorm:"primarykey"`
+	Email     string `gorm:"size:100;uniqueIndex"`
+	DeletedAt gorm.DeletedAt
+}
+
+func TestRecreateAfterSoftDelete(t *testing.T) {
+	db := setupTestDB(t, &TestMember{})
+	repo := New[TestMember](db)
+	ctx := context.Background()
+
+	old := &TestMember{Email: "member@example.com"}
+	repo.Create(ctx, old)
+	repo.Delete(ctx, old)
+
+	if err := repo.Create(ctx, &TestMember{Email: "member@example.com"}); err == nil {
+		t.Fatal("Expected the tombstone to block a plain create")
+	}
+
+	t.Run("purges the tombstone and creates", func(t *testing.T) {
+		member := &TestMember{Email: "member@example.com"}
+		if err := repo.RecreateAfterSoftDelete(ctx, member, "Email"); err != nil {
+			t.Fatalf("RecreateAfterSoftDelete failed: %v", err)
+		}
+
+		all, _ := repo.WithDeleted().FindAll(ctx)
+		if len(all) != 1 || all[0].ID != member.ID {
+			t.Errorf("Expected only the new member, got %+v", all)
+		}
+	})
+
+	t.Run("keeps live duplicates", func(t *testing.T) {
+		err := repo.RecreateAfterSoftDelete(ctx, &TestMember{Email: "member@example.com"}, "email")
+		if err == nil {
+			t.Error("Expected a live duplicate to fail")
+		}
+		if count, _ := repo.Count(ctx); count != 1 {
+			t.Errorf("Expected the live member to be kept, got count %d", count)
+		}
+	})
+
+	t.Run("validates model and column", func(t *testing.T) {
+		if err := repo.RecreateAfterSoftDelete(ctx, &TestMember{}, "missing"); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		err := New[TestUser](db).RecreateAfterSoftDelete(ctx, &TestUser{}, "email")
+		if !errors.Is(err, ErrSoftDeleteUnsupported) {
+			t.Errorf("Expected ErrSoftDeleteUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestExists(t *testing.T) {
+	db := setupTestDB(t, &TestMember{})
+	repo := New[TestMember](db)
+	ctx := context.Background()
+
+	deleted := &TestMember{Email: "deleted@example.com"}
+	repo.Create(ctx, deleted)
+	repo.Delete(ctx, deleted)
+	repo.Create(ctx, &TestMember{Email: "active@example.com"})
+
+	tests := []struct {
+		email            string
+		active, included bool
+	}{
+		{"active@example.com", true, true},
+		{"deleted@example.com", false, true},
+		{"missing@example.com", false, false},
+	}
+	for _, tt := range tests {
+		for _, r := range []*Repository[TestMember]{repo, repo.WithDeleted()} {
+			active, err := r.ExistsActive(ctx, "email = ?", tt.email)
+			if err != nil {
+				t.Fatalf("ExistsActive failed: %v", err)
+			}
+			if active != tt.active {
+				t.Errorf("ExistsActive(%s) = %v, expected %v", tt.email, active, tt.active)
+			}
+
+			included, err := r.ExistsIncludingDeleted(ctx, "email = ?", tt.email)
+			if err != nil {
+				t.Fatalf("ExistsIncludingDeleted failed: %v", err)
+			}
+			if included != tt.included {
+				t.Errorf("ExistsIncludingDeleted(%s) = %v, expected %v", tt.email, included, tt.included)
+			}
+		}
+	}
+
+	t.Run("treats every record of a model without soft delete as active", func(t *testing.T) {
+		counters := New[TestCounter](setupTestDB(t, &TestCounter{}))
+		counters.Create(ctx, &TestCounter{Day: "2024-06-01"})
+
+		active, err := counters.ExistsActive(ctx, "day = ?", "2024-06-01")
+		if err != nil || !active {
+			t.Errorf("Expected an active counter, got %v and %v", active, err)
+		}
+	})
+}
+
+func TestHardDeleteAndRestore(t *testing.T) {
+	db := setupTestDB(t, &TestSoftUser{})
+	repo := New[TestSoftUser](db)
+	ctx := context.Background()
+
+	t.Run("hard delete removes the row", func(t *testing.T) {
+		user := &TestSoftUser{Name: "Gone", Email: "gone@example.com"}
+		repo.Create(ctx, user)
+
+		if err := repo.HardDelete(ctx, user); err != nil {
+			t.Fatalf("HardDelete failed: %v", err)
+		}
+		if exists, _ := repo.ExistsIncludingDeleted(ctx, "id = ?", user.ID); exists {
+			t.Error("Expected the row to be removed, not soft-deleted")
+		}
+	})
+
+	t.Run("find all with deleted includes soft-deleted rows", func(t *testing.T) {
+		live := &TestSoftUser{Name: "Live", Email: "live@example.com"}
+		deleted := &TestSoftUser{Name: "Deleted", Email: "deleted@example.com"}
+		repo.Create(ctx, live)
+		repo.Create(ctx, deleted)
+		repo.Delete(ctx, deleted)
+
+		users, err := repo.FindAllWithDeleted(ctx)
+		if err != nil {
+			t.Fatalf("FindAllWithDeleted failed: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("Expected 2 users, got %+v", users)
+		}
+	})
+
+	t.Run("restore undeletes the row", func(t *testing.T) {
+		user := &TestSoftUser{Name: "Restored", Email: "restored@example.com"}
+		repo.Create(ctx, user)
+		repo.Delete(ctx, user)
+
+		if err := repo.Restore(ctx, user.ID); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		var found TestSoftUser
+		if err := repo.FindByID(ctx, user.ID, &found); err != nil {
+			t.Errorf("Expected the restored user to be found, got %v", err)
+		}
+		if err := repo.Restore(ctx, user.ID); err != nil {
+			t.Errorf("Expected restoring a live record to succeed, got %v", err)
+		}
+	})
+
+	t.Run("restore reports missing records", func(t *testing.T) {
+		if err := repo.Restore(ctx, 9999); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("restore requires a soft-delete field", func(t *testing.T) {
+		if err := New[TestUser](db).Restore(ctx, 1); !errors.Is(err, ErrSoftDeleteUnsupported) {
+			t.Errorf("Expected ErrSoftDeleteUnsupported, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store is the set of data-access methods of Repository, for callers that
+// want to depend on an interface and substitute a mock in tests. Methods that
+// configure and return a *Repository, such as WithDeleted, are not part of it.
+type Store[T any] interface {
+	Create(ctx context.Context, entity *T) error
+	FindByID(ctx context.Context, id interface{}, entity *T) error
+	FindByIDWithPreloads(ctx context.Context, id interface{}, entity *T, preloads ...string) error
+	FindAllWithPreloads(ctx context.Context, preloads ...string) ([]T, error)
+	FindByIDResilient(ctx context.Context, id interface{}, entity *T) error
+	FindAll(ctx context.Context) ([]T, error)
+	Update(ctx context.Context, entity *T) error
+	Delete(ctx context.Context, entity *T) error
+	DeleteByID(ctx context.Context, id interface{}) error
+	DeleteAll(ctx context.Context, confirm bool) (int64, error)
+	DeleteReturning(ctx context.Context, id interface{}) (T, error)
+	SoftDeleteByIDs(ctx context.Context, ids []interface{}) (int64, error)
+	RecreateAfterSoftDelete(ctx context.Context, entity *T, uniqueColumn string) error
+	HardDelete(ctx context.Context, entity *T) error
+	FindAllWithDeleted(ctx context.Context) ([]T, error)
+	Restore(ctx context.Context, id interface{}) error
+	ExistsActive(ctx context.Context, query interface{}, args ...interface{}) (bool, error)
+	ExistsIncludingDeleted(ctx context.Context, query interface{}, args ...interface{}) (bool, error)
+	Exists(ctx context.Context, query interface{}, args ...interface{}) (bool, error)
+	ExistsByID(ctx context.Context, id interface{}) (bool, error)
+	Count(ctx context.Context) (int64, error)
+	CountWhere(ctx context.Context, query interface{}, args ...interface{}) (int64, error)
+	CachedCount(ctx context.Context, ttl time.Duration) (int64, error)
+	FindAllOrdered(ctx context.Context, orderBy string) ([]T, error)
+	FindWhere(ctx context.Context, query interface{}, args ...interface{}) ([]T, error)
+	FirstWhere(ctx context.Context, entity *T, query interface{}, args ...interface{}) error
+	Transaction(ctx context.Context, fn func(*gorm.DB) error) error
+	TransactionWithDeadline(ctx context.Context, d time.Duration, fn func(*gorm.DB) error) error
+	TransactionWithRetry(ctx context.Context, attempts int, fn func(*gorm.DB) error) error
+	RunInTx(ctx context.Context, fn func(*gorm.DB) error) error
+	ReadTransaction(ctx context.Context, fn func(*gorm.DB) error) error
+	SetConstraintsDeferred(ctx context.Context) error
+
+	FindModifiedSince(ctx context.Context, since time.Time) ([]T, error)
+	FindWhereIn(ctx context.Context, column string, values []interface{}) ([]T, error)
+	ExistingValues(ctx context.Context, column string, values []interface{}) (map[interface{}]bool, error)
+	SearchPrefix(ctx context.Context, column, prefix string, limit int) ([]T, error)
+	FindByIDs(ctx context.Context, ids []interface{}) ([]T, error)
+	FindByIDsOrdered(ctx context.Context, ids []uint) ([]T, error)
+	ReloadAll(ctx context.Context, entities []T) error
+	GetOr(ctx context.Context, id interface{}, notFound error) (T, error)
+	FirstOrdered(ctx context.Context, orderBy string, query interface{}, args ...interface{}) (T, error)
+	FindMaps(ctx context.Context, query interface{}, args ...interface{}) ([]map[string]interface{}, error)
+	FindDistinctOn(ctx context.Context, distinctColumns []string, orderBy string, query interface{}, args ...interface{}) ([]T, error)
+	FindTopNPerGroup(ctx context.Context, partitionColumn, orderColumn string, n int, desc bool) ([]T, error)
+	GroupCount(ctx context.Context, column string) (map[string]int64, error)
+	GroupCountWhere(ctx context.Context, column string, query interface{}, args ...interface{}) (map[string]int64, error)
+	FindEach(ctx context.Context, batchSize int, fn func(T) error) error
+	FindEachProgress(ctx context.Context, batchSize int, fn func(T) error, onBatch func(processed int64)) error
+	Iterator(ctx context.Context, pageSize int) *PageIterator[T]
+	FindByIDForUpdate(ctx context.Context, id interface{}, entity *T, opts ...LockOption) error
+	ClaimNext(ctx context.Context, query interface{}, args ...interface{}) (*T, error)
+
+	Paginate(ctx context.Context, page, pageSize int, opts ...PaginateOption) ([]T, int64, error)
+	PaginateWith(ctx context.Context, q PaginateQuery, opts ...PaginateOption) ([]T, int64, error)
+	PaginateResult(ctx context.Context, page, pageSize int, opts ...PaginateOption) (PageResult[T], error)
+	PaginateKeyset(ctx context.Context, keyset Keyset, limit int) (items []T, next []interface{}, err error)
+	PaginateCursor(ctx context.Context, cursor string, limit int, orderColumn string) (items []T, nextCursor string, err error)
+
+	Upsert(ctx context.Context, entity *T, conflictColumns, updateColumns []string) error
+	UpsertMany(ctx context.Context, entities []T, conflictColumns, updateColumns []string, opts ...UpsertOption) error
+	InsertIgnore(ctx context.Context, entities []T, conflictColumns []string) (inserted int64, skipped int64, err error)
+	UpsertExpr(ctx context.Context, entity *T, conflictColumns []string, updateExpressions map[string]clause.Expression) error
+	UpsertIfNewer(ctx context.Context, entity *T, conflictColumns []string, compareColumn string, updateColumns []string) (bool, error)
+	ValidateUpsertTargets(ctx context.Context, conflictColumns []string) error
+	UpdateColumns(ctx context.Context, id interface{}, values map[string]interface{}) error
+	UpdateFields(ctx context.Context, id interface{}, fields map[string]interface{}) (int64, error)
+	UpdateWhere(ctx context.Context, values map[string]interface{}, query interface{}, args ...interface{}) (int64, error)
+	Patch(ctx context.Context, id interface{}, patch map[string]interface{}) (T, error)
+	Increment(ctx context.Context, id interface{}, column string, delta interface{}, opts ...CounterOption) error
+	Decrement(ctx context.Context, id interface{}, column string, delta interface{}, opts ...CounterOption) error
+	ReassignWhere(ctx context.Context, column string, newValue interface{}, query interface{}, args ...interface{}) (int64, error)
+	UpdateIfChanged(ctx context.Context, entity *T) (bool, error)
+	RowHash(entity *T, columns ...string) (string, error)
+	CreateManyResults(ctx context.Context, entities []T) ([]RowResult, error)
+	CreateBatch(ctx context.Context, entities []T, batchSize int) error
+
+	ExportCSV(ctx context.Context, w io.Writer, columns []string, query interface{}, args ...interface{}) error
+	ImportCSV(ctx context.Context, reader io.Reader, columns []string) (int64, error)
+	EnableDeleteArchive(ctx context.Context) error
+	EnableVersioning(ctx context.Context) error
+	History(ctx context.Context, id interface{}) ([]T, error)
+	RestoreVersion(ctx context.Context, id interface{}, version int) error
+	Analyze(ctx context.Context) error
+}
+
+var _ Store[struct{}] = (*Repository[struct{}])(nil)
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// defaultBatchSize is used by batch operations when no batch size is given
+const defaultBatchSize = 100
+
+// batchSize returns n if positive, else the repository's default batch size
+func (r *Repository[T]) batchSize(n int) int {
+	if n > 0 {
+		return n
+	}
+	if r.opts.batchSize > 0 {
+		return r.opts.batchSize
+	}
+	return defaultBatchSize
+}
+
+// FindEach streams all records in primary key order, loading batchSize rows
+// at a time and calling fn for each one. Iteration stops at the first error
+// returned by fn. A batchSize of zero or less uses the repository's default
+// batch size (see WithDefaultBatchSize).
+func (r *Repository[T]) FindEach(ctx context.Context, batchSize int, fn func(T) error) error {
+	ctx = r.checkDeadline(ctx)
+	return r.FindEachProgress(ctx, batchSize, fn, nil)
+}
+
+// FindEachProgress behaves like FindEach and additionally calls onBatch after
+// each batch with the running number of rows fn has handled successfully.
+// onBatch may be nil.
+//
+// Cancelling ctx stops the iteration before the next row is handed to fn and
+// the context's error is returned, never a partial success.
+func (r *Repository[T]) FindEachProgress(ctx context.Context, batchSize int, fn func(T) error, onBatch func(processed int64)) error {
+	ctx = r.checkDeadline(ctx)
+	batchSize = r.batchSize(batchSize)
+
+	var batch []T
+	var processed int64
+	err := r.conn(ctx).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for _, entity := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(entity); err != nil {
+				return err
+			}
+			processed++
+		}
+
+		if onBatch != nil {
+			onBatch(processed)
+		}
+		return nil
+	}).Error
+
+	// Drivers report a query interrupted by cancellation in their own words
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
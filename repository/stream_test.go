@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func seedUsers(t *testing.T, repo *Repository[TestUser], n int) {
+	t.Helper()
+
+	for i := 1; i <= n; i++ {
+		user := &TestUser{
+			Name:  fmt.Sprintf("User %d", i),
+			Email: fmt.Sprintf("user%d@example.com", i),
+			Age:   20 + i,
+		}
+		if err := repo.Create(context.Background(), user); err != nil {
+			t.Fatalf("Failed to seed user: %v", err)
+		}
+	}
+}
+
+func TestFindEach(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 25)
+
+	t.Run("visits every record", func(t *testing.T) {
+		var seen int
+		err := repo.FindEach(ctx, 10, func(TestUser) error {
+			seen++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("FindEach failed: %v", err)
+		}
+		if seen != 25 {
+			t.Errorf("Expected 25 records, got %d", seen)
+		}
+	})
+
+	t.Run("aborts promptly when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var seen int
+		err := repo.FindEach(ctx, 10, func(TestUser) error {
+			seen++
+			if seen == 5 {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if seen != 5 {
+			t.Errorf("Expected iteration to stop after 5 records, got %d", seen)
+		}
+	})
+
+	t.Run("returns context error when cancelled before start", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := repo.FindEach(ctx, 10, func(TestUser) error {
+			t.Error("Expected no records after cancellation")
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("stops at the first callback error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var seen int
+		err := repo.FindEach(ctx, 10, func(TestUser) error {
+			seen++
+			if seen == 3 {
+				return errStop
+			}
+			return nil
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("Expected errStop, got %v", err)
+		}
+		if seen != 3 {
+			t.Errorf("Expected iteration to stop after 3 records, got %d", seen)
+		}
+	})
+}
+
+func TestFindEachProgress(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+	seedUsers(t, repo, 25)
+
+	t.Run("reports running count after each batch", func(t *testing.T) {
+		var progress []int64
+		err := repo.FindEachProgress(ctx, 10, func(TestUser) error {
+			return nil
+		}, func(processed int64) {
+			progress = append(progress, processed)
+		})
+		if err != nil {
+			t.Fatalf("FindEachProgress failed: %v", err)
+		}
+
+		want := []int64{10, 20, 25}
+		if fmt.Sprint(progress) != fmt.Sprint(want) {
+			t.Errorf("Expected progress %v, got %v", want, progress)
+		}
+	})
+
+	t.Run("accepts nil progress callback", func(t *testing.T) {
+		err := repo.FindEachProgress(ctx, 10, func(TestUser) error {
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("FindEachProgress failed: %v", err)
+		}
+	})
+}
+
+func TestWithDefaultBatchSize(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	seedUsers(t, New[TestUser](db), 25)
+
+	t.Run("applies when the call passes zero", func(t *testing.T) {
+		repo := New[TestUser](db, WithDefaultBatchSize(20))
+
+		var batches int
+		err := repo.FindEachProgress(ctx, 0, func(TestUser) error {
+			return nil
+		}, func(int64) {
+			batches++
+		})
+		if err != nil {
+			t.Fatalf("FindEachProgress failed: %v", err)
+		}
+		if batches != 2 {
+			t.Errorf("Expected 2 batches of 20, got %d", batches)
+		}
+	})
+
+	t.Run("explicit batch size wins", func(t *testing.T) {
+		repo := New[TestUser](db, WithDefaultBatchSize(20))
+
+		var batches int
+		repo.FindEachProgress(ctx, 5, func(TestUser) error {
+			return nil
+		}, func(int64) {
+			batches++
+		})
+		if batches != 5 {
+			t.Errorf("Expected 5 batches of 5, got %d", batches)
+		}
+	})
+
+	t.Run("rejects non-positive size", func(t *testing.T) {
+		repo := New[TestUser](db, WithDefaultBatchSize(0))
+		if _, err := repo.Count(ctx); err == nil {
+			t.Error("Expected error from repository with invalid batch size")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// txHooksKey stores the hooks of the enclosing RunInTx on the transaction
+const txHooksKey = "repository:tx_hooks"
+
+// txHooks collects the lifecycle hooks registered within one RunInTx
+type txHooks struct {
+	mu            sync.Mutex
+	beforeCommit  []func(*gorm.DB) error
+	afterCommit   []func()
+	afterRollback []func()
+}
+
+// RunInTx runs fn in a transaction like Transaction and runs the hooks
+// registered on its *gorm.DB with OnBeforeCommit, OnAfterCommit and
+// OnAfterRollback at the matching points:
+//
+//	repo.RunInTx(ctx, func(tx *gorm.DB) error {
+//		if err := repository.New[Order](tx).Create(ctx, order); err != nil {
+//			return err
+//		}
+//		return repository.OnAfterCommit(tx, func() { cache.Delete(order.CustomerID) })
+//	})
+//
+// Hooks of each kind run in the order they were registered. Before-commit
+// hooks run inside the transaction once fn has succeeded, and the first one
+// to fail rolls it back. After-commit hooks run only if the commit succeeds.
+// After-rollback hooks run whenever the transaction is aborted: fn or a
+// before-commit hook failed or panicked, or the commit itself failed.
+//
+// A RunInTx on a repository bound to the transaction of an outer RunInTx runs
+// in a savepoint. Its before-commit hooks run when the savepoint is released.
+// When it succeeds its after-commit and after-rollback hooks are handed to
+// the outer transaction and run when that one ends; when it fails only its
+// own after-rollback hooks run, right away.
+func (r *Repository[T]) RunInTx(ctx context.Context, fn func(*gorm.DB) error) error {
+	ctx = r.checkDeadline(ctx)
+	hooks := &txHooks{}
+	err := func() error {
+		defer func() {
+			if p := recover(); p != nil {
+				hooks.rolledBack()
+				panic(p)
+			}
+		}()
+
+		return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			tx = tx.Set(txHooksKey, hooks)
+			if err := fn(tx); err != nil {
+				return err
+			}
+			for _, hook := range hooks.before() {
+				if err := hook(tx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}()
+	if err != nil {
+		hooks.rolledBack()
+		return err
+	}
+
+	if parent, ok := currentTxHooks(r.db); ok {
+		parent.adopt(hooks)
+		return nil
+	}
+	hooks.committed()
+	return nil
+}
+
+// OnBeforeCommit registers fn to run inside the transaction of the enclosing
+// RunInTx just before it commits. An error from fn rolls the transaction
+// back and is returned by RunInTx. It returns ErrNotInTransaction when tx
+// does not come from RunInTx.
+func OnBeforeCommit(tx *gorm.DB, fn func(*gorm.DB) error) error {
+	hooks, ok := currentTxHooks(tx)
+	if !ok {
+		return ErrNotInTransaction
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.beforeCommit = append(hooks.beforeCommit, fn)
+	return nil
+}
+
+// OnAfterCommit registers fn to run once the transaction of the enclosing
+// RunInTx has committed, such as invalidating cached rows. It returns
+// ErrNotInTransaction when tx does not come from RunInTx.
+func OnAfterCommit(tx *gorm.DB, fn func()) error {
+	hooks, ok := currentTxHooks(tx)
+	if !ok {
+		return ErrNotInTransaction
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterCommit = append(hooks.afterCommit, fn)
+	return nil
+}
+
+// OnAfterRollback registers fn to run once the transaction of the enclosing
+// RunInTx has been aborted, such as removing files written for it. It
+// returns ErrNotInTransaction when tx does not come from RunInTx.
+func OnAfterRollback(tx *gorm.DB, fn func()) error {
+	hooks, ok := currentTxHooks(tx)
+	if !ok {
+		return ErrNotInTransaction
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterRollback = append(hooks.afterRollback, fn)
+	return nil
+}
+
+// currentTxHooks returns the hooks of the RunInTx that tx belongs to
+func currentTxHooks(tx *gorm.DB) (*txHooks, bool) {
+	value, ok := tx.Get(txHooksKey)
+	if !ok {
+		return nil, false
+	}
+	hooks, ok := value.(*txHooks)
+	return hooks, ok
+}
+
+// before returns the registered before-commit hooks
+func (h *txHooks) before() []func(*gorm.DB) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]func(*gorm.DB) error(nil), h.beforeCommit...)
+}
+
+// committed runs the after-commit hooks
+func (h *txHooks) committed() {
+	h.mu.Lock()
+	hooks := h.afterCommit
+	h.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// rolledBack runs the after-rollback hooks
+func (h *txHooks) rolledBack() {
+	h.mu.Lock()
+	hooks := h.afterRollback
+	h.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// adopt takes over the after-commit and after-rollback hooks of a committed
+// nested RunInTx. Its before-commit hooks have already run.
+func (h *txHooks) adopt(child *txHooks) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.afterCommit = append(h.afterCommit, child.afterCommit...)
+	h.afterRollback = append(h.afterRollback, child.afterRollback...)
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestRunInTx(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	var events []string
+	record := func(event string) func() {
+		return func() { events = append(events, event) }
+	}
+	failed := errors.New("failed")
+
+	t.Run("runs commit hooks in order", func(t *testing.T) {
+		events = nil
+		err := repo.RunInTx(ctx, func(tx *gorm.DB) error {
+			OnAfterCommit(tx, record("commit 1"))
+			OnAfterRollback(tx, record("rollback"))
+			OnBeforeCommit(tx, func(tx *gorm.DB) error {
+				events = append(events, "before")
+				return nil
+			})
+			OnAfterCommit(tx, record("commit 2"))
+			return New[TestUser](tx).Create(ctx, &TestUser{Name: "Ann", Email: "ann@example.com"})
+		})
+		if err != nil {
+			t.Fatalf("RunInTx failed: %v", err)
+		}
+		if fmt.Sprint(events) != "[before commit 1 commit 2]" {
+			t.Errorf("Unexpected hooks: %v", events)
+		}
+	})
+
+	t.Run("runs rollback hooks when fn fails", func(t *testing.T) {
+		events = nil
+		err := repo.RunInTx(ctx, func(tx *gorm.DB) error {
+			OnAfterCommit(tx, record("commit"))
+			OnAfterRollback(tx, record("rollback"))
+			New[TestUser](tx).Create(ctx, &TestUser{Name: "Bob", Email: "bob@example.com"})
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Expected fn error, got %v", err)
+		}
+		if fmt.Sprint(events) != "[rollback]" {
+			t.Errorf("Unexpected hooks: %v", events)
+		}
+		if n, _ := repo.Count(ctx); n != 1 {
+			t.Errorf("Expected the insert to be rolled back, got %d users", n)
+		}
+	})
+
+	t.Run("rolls back when a before-commit hook fails", func(t *testing.T) {
+		events = nil
+		err := repo.RunInTx(ctx, func(tx *gorm.DB) error {
+			OnAfterRollback(tx, record("rollback"))
+			OnBeforeCommit(tx, func(*gorm.DB) error { return failed })
+			return New[TestUser](tx).Create(ctx, &TestUser{Name: "Cid", Email: "cid@example.com"})
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Expected hook error, got %v", err)
+		}
+		if fmt.Sprint(events) != "[rollback]" {
+			t.Errorf("Unexpected hooks: %v", events)
+		}
+		if n, _ := repo.Count(ctx); n != 1 {
+			t.Errorf("Expected the insert to be rolled back, got %d users", n)
+		}
+	})
+
+	t.Run("runs rollback hooks on panic", func(t *testing.T) {
+		events = nil
+		func() {
+			defer func() { recover() }()
+			repo.RunInTx(ctx, func(tx *gorm.DB) error {
+				OnAfterRollback(tx, record("rollback"))
+				panic("boom")
+			})
+		}()
+		if fmt.Sprint(events) != "[rollback]" {
+			t.Errorf("Unexpected hooks: %v", events)
+		}
+	})
+
+	t.Run("defers nested hooks to the outer transaction", func(t *testing.T) {
+		events = nil
+		err := repo.RunInTx(ctx, func(tx *gorm.DB) error {
+			OnAfterCommit(tx, record("outer commit"))
+			err := New[TestUser](tx).RunInTx(ctx, func(tx *gorm.DB) error {
+				OnAfterCommit(tx, record("inner commit"))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(events) != 0 {
+				t.Errorf("Expected no hooks before the outer commit, got %v", events)
+			}
+
+			New[TestUser](tx).RunInTx(ctx, func(tx *gorm.DB) error {
+				OnAfterCommit(tx, record("failed commit"))
+				OnAfterRollback(tx, record("inner rollback"))
+				return failed
+			})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("RunInTx failed: %v", err)
+		}
+		if fmt.Sprint(events) != "[inner rollback outer commit inner commit]" {
+			t.Errorf("Unexpected hooks: %v", events)
+		}
+	})
+
+	t.Run("rejects registration outside RunInTx", func(t *testing.T) {
+		if err := OnAfterCommit(db, func() {}); !errors.Is(err, ErrNotInTransaction) {
+			t.Errorf("Expected ErrNotInTransaction, got %v", err)
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return OnBeforeCommit(tx, func(*gorm.DB) error { return nil })
+		})
+		if !errors.Is(err, ErrNotInTransaction) {
+			t.Errorf("Expected ErrNotInTransaction, got %v", err)
+		}
+	})
+}
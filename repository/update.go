@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrMissingCondition is returned when a bulk write is attempted without
+	// a where condition
+	ErrMissingCondition = errors.New("where condition is required")
+	// ErrImmutableColumn is returned when a patch sets a column that is not
+	// written by updates, such as the primary key or created_at
+	ErrImmutableColumn = errors.New("immutable column")
+)
+
+// isEmptyCondition reports whether query would leave a statement unfiltered
+func isEmptyCondition(query interface{}) bool {
+	if query == nil {
+		return true
+	}
+	if s, ok := query.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	if v := reflect.ValueOf(query); v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return false
+}
+
+// ReassignWhere sets column to newValue on every record matching the
+// condition and returns the number of rows changed, e.g. moving all orders of
+// one customer to another:
+//
+//	repo.ReassignWhere(ctx, "customer_id", b.ID, "customer_id = ?", a.ID)
+//
+// The column must belong to T and an empty condition is rejected with
+// ErrMissingCondition.
+func (r *Repository[T]) ReassignWhere(ctx context.Context, column string, newValue interface{}, query interface{}, args ...interface{}) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	col, err := r.column(column)
+	if err != nil {
+		return 0, err
+	}
+	if isEmptyCondition(query) {
+		return 0, ErrMissingCondition
+	}
+
+	result := r.conn(ctx).Model(new(T)).Where(query, args...).Update(col, newValue)
+	return result.RowsAffected, result.Error
+}
+
+// UpdateColumns sets the columns in values on the record with the given ID,
+// skipping hooks and the other columns. Keys may be field or column names.
+// Unlike GORM's UpdateColumns, the auto-updated timestamp of T is set to the
+// current time unless values sets it or the repository was created with
+// WithoutAutoUpdatedAt.
+func (r *Repository[T]) UpdateColumns(ctx context.Context, id interface{}, values map[string]interface{}) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.updateColumns(r.conn(ctx).Where(pkCondition(pk, id)), s, values)
+	return err
+}
+
+// UpdateFields sets the columns in fields on the record with the given ID
+// like UpdateColumns, leaving every other column as stored, and returns the
+// number of rows changed. Zero values in fields are written as is. It returns
+// gorm.ErrRecordNotFound when no record has the ID. MySQL counts only the
+// rows whose values change, so there a model without an auto-updated
+// timestamp also reports not found when fields match what is stored.
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, fields map[string]interface{}) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := r.updateColumns(r.conn(ctx).Where(pkCondition(pk, id)), s, fields)
+	if err == nil && rows == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return rows, err
+}
+
+// UpdateWhere sets the columns in values on every record matching the
+// condition and returns the number of rows changed, bumping the auto-updated
+// timestamp like UpdateColumns. An empty condition is rejected with
+// ErrMissingCondition.
+func (r *Repository[T]) UpdateWhere(ctx context.Context, values map[string]interface{}, query interface{}, args ...interface{}) (int64, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	if isEmptyCondition(query) {
+		return 0, ErrMissingCondition
+	}
+	s, err := r.schema()
+	if err != nil {
+		return 0, err
+	}
+	return r.updateColumns(r.conn(ctx).Where(query, args...), s, values)
+}
+
+// Patch applies a partial update, as sent by an HTTP PATCH endpoint, to the
+// record with the given ID and returns the record as stored afterwards. Keys
+// may be field or column names. Every key is validated before anything is
+// written: keys that are not columns of T fail with ErrInvalidColumn, and
+// keys naming the primary key, the creation or update timestamps, the
+// soft-delete column or fields GORM does not update fail with
+// ErrImmutableColumn, each error listing all offending keys. The update
+// timestamp is bumped like UpdateColumns does. It returns
+// gorm.ErrRecordNotFound when there is no record with the ID.
+func (r *Repository[T]) Patch(ctx context.Context, id interface{}, patch map[string]interface{}) (T, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	var entity T
+	s, err := r.schema()
+	if err != nil {
+		return entity, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return entity, err
+	}
+
+	var unknown, immutable []string
+	for name := range patch {
+		field := s.LookUpField(name)
+		switch {
+		case field == nil || field.DBName == "":
+			unknown = append(unknown, strconv.Quote(name))
+		case field.PrimaryKey || !field.Updatable || field.AutoCreateTime > 0 || field.AutoUpdateTime > 0 || field.FieldType == deletedAtType:
+			immutable = append(immutable, strconv.Quote(name))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return entity, fmt.Errorf("%w in patch: %s", ErrInvalidColumn, strings.Join(unknown, ", "))
+	}
+	if len(immutable) > 0 {
+		sort.Strings(immutable)
+		return entity, fmt.Errorf("%w in patch: %s", ErrImmutableColumn, strings.Join(immutable, ", "))
+	}
+
+	err = r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(patch) > 0 {
+			if _, err := r.updateColumns(tx.Where(pkCondition(pk, id)), s, patch); err != nil {
+				return err
+			}
+		}
+		return tx.Where(pkCondition(pk, id)).First(&entity).Error
+	})
+	return entity, err
+}
+
+// updateColumns resolves the keys of values to columns, adds the timestamp
+// bump and runs the update on tx
+func (r *Repository[T]) updateColumns(tx *gorm.DB, s *schema.Schema, values map[string]interface{}) (int64, error) {
+	if len(values) == 0 {
+		return 0, errors.New("update values cannot be empty")
+	}
+
+	columns := make(map[string]interface{}, len(values)+1)
+	for name, value := range values {
+		col, err := r.column(name)
+		if err != nil {
+			return 0, err
+		}
+		columns[col] = value
+	}
+	if field := updatedAtField(s); field != nil && !r.opts.keepUpdatedAt {
+		if _, ok := columns[field.DBName]; !ok {
+			columns[field.DBName] = autoUpdateValue(field, r.db.NowFunc())
+		}
+	}
+
+	result := tx.Model(new(T)).UpdateColumns(columns)
+	return result.RowsAffected, result.Error
+}
+
+// autoUpdateValue returns now in the representation of an auto-updated
+// timestamp field, as GORM itself would set it
+func autoUpdateValue(field *schema.Field, now time.Time) interface{} {
+	switch field.AutoUpdateTime {
+	case schema.UnixNanosecond:
+		return now.UnixNano()
+	case schema.UnixMillisecond:
+		return now.UnixMilli()
+	case schema.UnixSecond:
+		return now.Unix()
+	}
+	return now
+}
+
+// UpdateIfChanged loads the stored row with entity's primary key and saves
+// entity only if one of its columns differs, reporting whether it wrote.
+// It costs an extra SELECT but avoids no-op UPDATEs, and the updated_at bumps
+// and triggers they cause, on idempotent syncs.
+//
+// Every column Update would write is compared except the auto-updated
+// timestamp. Zero values are compared like any other value: a field left at
+// its zero value in entity counts as a change when the stored row has a value.
+// Times are compared with time.Time.Equal and driver.Valuer fields by the
+// value they store.
+func (r *Repository[T]) UpdateIfChanged(ctx context.Context, entity *T) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return false, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return false, err
+	}
+
+	id, zero := pk.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		return false, gorm.ErrPrimaryKeyRequired
+	}
+
+	var current T
+	if err := r.conn(ctx).First(&current, id).Error; err != nil {
+		return false, err
+	}
+
+	if len(diffFields(ctx, s, &current, entity)) == 0 {
+		return false, nil
+	}
+	if err := r.Update(ctx, entity); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ChangeSet describes a column whose value differs between two versions of
+// a row
+type ChangeSet struct {
+	Column string
+	Old    interface{}
+	New    interface{}
+}
+
+// diffFields returns the persisted columns, other than the auto-updated
+// timestamp, whose values differ between before and after
+func diffFields[T any](ctx context.Context, s *schema.Schema, before, after *T) []ChangeSet {
+	beforeValue := reflect.ValueOf(before).Elem()
+	afterValue := reflect.ValueOf(after).Elem()
+
+	var changes []ChangeSet
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.AutoUpdateTime > 0 {
+			continue
+		}
+
+		old, _ := field.ValueOf(ctx, beforeValue)
+		cur, _ := field.ValueOf(ctx, afterValue)
+		if !sameValue(old, cur) {
+			changes = append(changes, ChangeSet{Column: field.DBName, Old: old, New: cur})
+		}
+	}
+	return changes
+}
+
+// sameValue compares two column values the way the database would store them
+func sameValue(a, b interface{}) bool {
+	a, b = indirectValue(a), indirectValue(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch av := a.(type) {
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	case driver.Valuer:
+		bv, ok := b.(driver.Valuer)
+		if !ok {
+			return false
+		}
+		aStored, aErr := av.Value()
+		bStored, bErr := bv.Value()
+		if aErr != nil || bErr != nil {
+			return reflect.DeepEqual(a, b)
+		}
+		return sameValue(aStored, bStored)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// indirectValue dereferences pointers, returning nil for nil pointers
+func indirectValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestReassignWhere(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	users := []TestUser{
+		{Name: "Alice", Email: "alice@example.com", Age: 25},
+		{Name: "Bob", Email: "bob@example.com", Age: 25},
+		{Name: "Charlie", Email: "charlie@example.com", Age: 30},
+	}
+	for i := range users {
+		repo.Create(ctx, &users[i])
+	}
+
+	t.Run("moves matching rows and reports the count", func(t *testing.T) {
+		affected, err := repo.ReassignWhere(ctx, "age", 26, "age = ?", 25)
+		if err != nil {
+			t.Fatalf("ReassignWhere failed: %v", err)
+		}
+		if affected != 2 {
+			t.Errorf("Expected 2 rows affected, got %d", affected)
+		}
+
+		moved, _ := repo.FindWhere(ctx, "age = ?", 26)
+		if len(moved) != 2 {
+			t.Errorf("Expected 2 users with age 26, got %d", len(moved))
+		}
+	})
+
+	t.Run("rejects empty condition", func(t *testing.T) {
+		_, err := repo.ReassignWhere(ctx, "age", 40, "")
+		if !errors.Is(err, ErrMissingCondition) {
+			t.Errorf("Expected ErrMissingCondition, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown column", func(t *testing.T) {
+		_, err := repo.ReassignWhere(ctx, "owner_id; DROP TABLE test_users", 1, "age = ?", 26)
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestUpdateIfChanged(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	repo := New[TestArticle](db)
+	ctx := context.Background()
+
+	article := &TestArticle{Title: "Original"}
+	repo.Create(ctx, article)
+	stamp := time.Now().Add(-time.Hour).UTC()
+	db.Model(article).UpdateColumn("updated_at", stamp)
+
+	var loaded TestArticle
+	repo.FindByID(ctx, article.ID, &loaded)
+
+	t.Run("skips the write when nothing changed", func(t *testing.T) {
+		changed, err := repo.UpdateIfChanged(ctx, &loaded)
+		if err != nil {
+			t.Fatalf("UpdateIfChanged failed: %v", err)
+		}
+		if changed {
+			t.Error("Expected no change to be reported")
+		}
+
+		var stored TestArticle
+		repo.FindByID(ctx, article.ID, &stored)
+		if !stored.UpdatedAt.Equal(stamp) {
+			t.Errorf("Expected updated_at to stay %v, got %v", stamp, stored.UpdatedAt)
+		}
+	})
+
+	t.Run("writes when a field changed", func(t *testing.T) {
+		loaded.Title = "Edited"
+		changed, err := repo.UpdateIfChanged(ctx, &loaded)
+		if err != nil {
+			t.Fatalf("UpdateIfChanged failed: %v", err)
+		}
+		if !changed {
+			t.Error("Expected change to be reported")
+		}
+
+		var stored TestArticle
+		repo.FindByID(ctx, article.ID, &stored)
+		if stored.Title != "Edited" {
+			t.Errorf("Expected title Edited, got %s", stored.Title)
+		}
+	})
+
+	t.Run("returns not found for missing row", func(t *testing.T) {
+		_, err := repo.UpdateIfChanged(ctx, &TestArticle{ID: 999, Title: "Ghost"})
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUpdateColumns(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	ctx := context.Background()
+	stamp := time.Now().Add(-time.Hour).UTC()
+
+	seed := func(t *testing.T) *TestArticle {
+		t.Helper()
+		article := &TestArticle{Title: "Original"}
+		db.Create(article)
+		db.Model(article).UpdateColumn("updated_at", stamp)
+		return article
+	}
+	load := func(t *testing.T, id uint) TestArticle {
+		t.Helper()
+		var stored TestArticle
+		if err := db.First(&stored, id).Error; err != nil {
+			t.Fatalf("Failed to load article: %v", err)
+		}
+		return stored
+	}
+
+	t.Run("bumps updated_at", func(t *testing.T) {
+		article := seed(t)
+		if err := New[TestArticle](db).UpdateColumns(ctx, article.ID, map[string]interface{}{"Title": "Edited"}); err != nil {
+			t.Fatalf("UpdateColumns failed: %v", err)
+		}
+
+		stored := load(t, article.ID)
+		if stored.Title != "Edited" {
+			t.Errorf("Expected title Edited, got %s", stored.Title)
+		}
+		if !stored.UpdatedAt.After(stamp) {
+			t.Errorf("Expected updated_at after %v, got %v", stamp, stored.UpdatedAt)
+		}
+	})
+
+	t.Run("keeps an explicit updated_at", func(t *testing.T) {
+		article := seed(t)
+		explicit := stamp.Add(-time.Hour)
+		values := map[string]interface{}{"title": "Edited", "updated_at": explicit}
+		if err := New[TestArticle](db).UpdateColumns(ctx, article.ID, values); err != nil {
+			t.Fatalf("UpdateColumns failed: %v", err)
+		}
+
+		if stored := load(t, article.ID); !stored.UpdatedAt.Equal(explicit) {
+			t.Errorf("Expected updated_at %v, got %v", explicit, stored.UpdatedAt)
+		}
+	})
+
+	t.Run("can opt out", func(t *testing.T) {
+		article := seed(t)
+		repo := New[TestArticle](db, WithoutAutoUpdatedAt())
+		if _, err := repo.UpdateWhere(ctx, map[string]interface{}{"title": "Backfilled"}, "id = ?", article.ID); err != nil {
+			t.Fatalf("UpdateWhere failed: %v", err)
+		}
+
+		stored := load(t, article.ID)
+		if stored.Title != "Backfilled" || !stored.UpdatedAt.Equal(stamp) {
+			t.Errorf("Expected Backfilled with updated_at %v, got %s and %v", stamp, stored.Title, stored.UpdatedAt)
+		}
+	})
+
+	t.Run("updates every match", func(t *testing.T) {
+		a, b := seed(t), seed(t)
+		n, err := New[TestArticle](db).UpdateWhere(ctx, map[string]interface{}{"title": "Bulk"}, "id IN ?", []uint{a.ID, b.ID})
+		if err != nil {
+			t.Fatalf("UpdateWhere failed: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 rows, got %d", n)
+		}
+		if stored := load(t, b.ID); !stored.UpdatedAt.After(stamp) {
+			t.Errorf("Expected updated_at after %v, got %v", stamp, stored.UpdatedAt)
+		}
+	})
+
+	t.Run("validates input", func(t *testing.T) {
+		repo := New[TestArticle](db)
+		if _, err := repo.UpdateWhere(ctx, map[string]interface{}{"title": "x"}, ""); !errors.Is(err, ErrMissingCondition) {
+			t.Errorf("Expected ErrMissingCondition, got %v", err)
+		}
+		if err := repo.UpdateColumns(ctx, 1, map[string]interface{}{"nope": 1}); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if err := repo.UpdateColumns(ctx, 1, nil); err == nil {
+			t.Error("Expected error for empty values")
+		}
+	})
+}
+
+func TestUpdateFields(t *testing.T) {
+	db := setupTestDB(t)
+	repo := New[TestUser](db)
+	ctx := context.Background()
+
+	user := &TestUser{Name: "Original", Email: "original@example.com", Age: 30}
+	repo.Create(ctx, user)
+
+	t.Run("sets only the given columns, zero values included", func(t *testing.T) {
+		rows, err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"age": 0})
+		if err != nil {
+			t.Fatalf("UpdateFields failed: %v", err)
+		}
+		if rows != 1 {
+			t.Errorf("Expected 1 row affected, got %d", rows)
+		}
+
+		var stored TestUser
+		db.First(&stored, user.ID)
+		if stored.Age != 0 {
+			t.Errorf("Expected age 0, got %d", stored.Age)
+		}
+		if stored.Name != "Original" || stored.Email != "original@example.com" {
+			t.Errorf("Expected other columns untouched, got %+v", stored)
+		}
+	})
+
+	t.Run("returns not found for a missing ID", func(t *testing.T) {
+		rows, err := repo.UpdateFields(ctx, 9999, map[string]interface{}{"Name": "Ghost"})
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected gorm.ErrRecordNotFound, got %v", err)
+		}
+		if rows != 0 {
+			t.Errorf("Expected 0 rows affected, got %d", rows)
+		}
+	})
+
+	t.Run("validates columns", func(t *testing.T) {
+		if _, err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"nope": 1}); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestPatch(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	repo := New[TestArticle](db)
+	ctx := context.Background()
+
+	article := &TestArticle{Title: "Draft"}
+	if err := repo.Create(ctx, article); err != nil {
+		t.Fatalf("Failed to create article: %v", err)
+	}
+
+	t.Run("applies the patch and returns the stored row", func(t *testing.T) {
+		patched, err := repo.Patch(ctx, article.ID, map[string]interface{}{"title": "Published"})
+		if err != nil {
+			t.Fatalf("Patch failed: %v", err)
+		}
+		if patched.ID != article.ID || patched.Title != "Published" {
+			t.Errorf("Expected the patched article, got %+v", patched)
+		}
+		if !patched.UpdatedAt.After(article.UpdatedAt) {
+			t.Errorf("Expected updated_at to move past %v, got %v", article.UpdatedAt, patched.UpdatedAt)
+		}
+	})
+
+	t.Run("lists unknown keys", func(t *testing.T) {
+		_, err := repo.Patch(ctx, article.ID, map[string]interface{}{"title": "x", "slug": "x", "author": "x"})
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Fatalf("Expected ErrInvalidColumn, got %v", err)
+		}
+		if !strings.Contains(err.Error(), `"author", "slug"`) {
+			t.Errorf("Expected the error to list the unknown keys, got %v", err)
+		}
+	})
+
+	t.Run("rejects immutable columns", func(t *testing.T) {
+		for _, key := range []string{"id", "CreatedAt", "updated_at", "deleted_at"} {
+			_, err := repo.Patch(ctx, article.ID, map[string]interface{}{key: nil})
+			if !errors.Is(err, ErrImmutableColumn) {
+				t.Errorf("Expected ErrImmutableColumn for %s, got %v", key, err)
+			}
+		}
+
+		var stored TestArticle
+		repo.FindByID(ctx, article.ID, &stored)
+		if stored.Title != "Published" {
+			t.Errorf("Expected rejected patches to write nothing, got title %q", stored.Title)
+		}
+	})
+
+	t.Run("missing record", func(t *testing.T) {
+		_, err := repo.Patch(ctx, article.ID+100, map[string]interface{}{"title": "x"})
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
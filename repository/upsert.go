@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoUniqueIndex is returned by ValidateUpsertTargets when no unique index
+// or constraint matches the conflict columns
+var ErrNoUniqueIndex = errors.New("no unique index matches the conflict columns")
+
+// Upsert inserts entity or, when it conflicts with an existing row on
+// conflictColumns, updates that row's updateColumns from entity. An empty
+// updateColumns updates every column.
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, conflictColumns, updateColumns []string) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	onConflict, err := r.onConflict(conflictColumns)
+	if err != nil {
+		return err
+	}
+
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		cols, err := r.columns(updateColumns)
+		if err != nil {
+			return err
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(cols)
+	}
+
+	return r.conn(ctx).Clauses(onConflict).Create(entity).Error
+}
+
+// UpsertOption configures UpsertMany
+type UpsertOption func(*upsertOptions)
+
+type upsertOptions struct {
+	ordered bool
+}
+
+// OrderByConflictKey makes UpsertMany write the entities sorted by their
+// conflict columns. Upserts lock the rows they touch in the order they write
+// them, so two transactions upserting overlapping keys in different orders
+// can each hold a row the other waits for and deadlock. When every writer
+// sorts the same way, row locks are taken in one global order and that cycle
+// cannot form. It only helps if all concurrent writers of the table use it;
+// run the upsert in TransactionWithRetry for the deadlocks other statements
+// can still cause.
+func OrderByConflictKey() UpsertOption {
+	return func(o *upsertOptions) {
+		o.ordered = true
+	}
+}
+
+// UpsertMany upserts entities like Upsert, in multi-row statements of the
+// repository's batch size within one transaction, and sets generated IDs in
+// entities. Entities keep their positions in the slice whatever their write
+// order.
+func (r *Repository[T]) UpsertMany(ctx context.Context, entities []T, conflictColumns, updateColumns []string, opts ...UpsertOption) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	var o upsertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	onConflict, err := r.onConflict(conflictColumns)
+	if err != nil {
+		return err
+	}
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		cols, err := r.columns(updateColumns)
+		if err != nil {
+			return err
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(cols)
+	}
+	if len(entities) == 0 {
+		return r.err
+	}
+
+	if !o.ordered {
+		return r.conn(ctx).Clauses(onConflict).CreateInBatches(&entities, r.batchSize(0)).Error
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	keys := make([][]interface{}, len(entities))
+	for i := range entities {
+		rv := reflect.ValueOf(&entities[i]).Elem()
+		for _, col := range onConflict.Columns {
+			v, _ := s.FieldsByDBName[col.Name].ValueOf(ctx, rv)
+			keys[i] = append(keys[i], indirectValue(v))
+		}
+	}
+	order := make([]int, len(entities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareKeys(keys[order[a]], keys[order[b]]) < 0
+	})
+
+	sorted := make([]T, len(entities))
+	for i, j := range order {
+		sorted[i] = entities[j]
+	}
+	err = r.conn(ctx).Clauses(onConflict).CreateInBatches(&sorted, r.batchSize(0)).Error
+	for i, j := range order {
+		entities[j] = sorted[i]
+	}
+	return err
+}
+
+// InsertIgnore inserts entities in multi-row statements of the repository's
+// batch size within one transaction, skipping those that conflict with an
+// existing row on conflictColumns with ON CONFLICT DO NOTHING. It reports how
+// many were inserted and how many skipped, the difference between
+// len(entities) and the rows affected; entities that conflict with an earlier
+// one in the same call count as skipped too.
+//
+// entities are left unchanged and get no generated IDs: the database returns
+// the IDs of the inserted rows only, which cannot be matched back to the
+// entities once some were skipped. Look the records up by conflictColumns,
+// such as with FindWhereIn, when the IDs are needed.
+func (r *Repository[T]) InsertIgnore(ctx context.Context, entities []T, conflictColumns []string) (inserted int64, skipped int64, err error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	onConflict, err := r.onConflict(conflictColumns)
+	if err != nil {
+		return 0, 0, err
+	}
+	onConflict.DoNothing = true
+	if len(entities) == 0 {
+		return 0, 0, r.err
+	}
+
+	// GORM copies returned IDs onto the slice by position, which misplaces
+	// them when rows are skipped, so insert a copy
+	rows := append([]T(nil), entities...)
+	tx := r.conn(ctx).Clauses(onConflict).CreateInBatches(&rows, r.batchSize(0))
+	if tx.Error != nil {
+		return 0, 0, tx.Error
+	}
+	return tx.RowsAffected, int64(len(entities)) - tx.RowsAffected, nil
+}
+
+// compareKeys orders conflict keys column by column
+func compareKeys(a, b []interface{}) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues orders two column values of the same field: numbers and
+// times by value, strings and bytes lexically, nil first and anything else
+// by its formatted value
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case av.CanInt() && bv.CanInt():
+		return cmp.Compare(av.Int(), bv.Int())
+	case av.CanUint() && bv.CanUint():
+		return cmp.Compare(av.Uint(), bv.Uint())
+	case av.CanFloat() && bv.CanFloat():
+		return cmp.Compare(av.Float(), bv.Float())
+	}
+	switch at := a.(type) {
+	case time.Time:
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	case []byte:
+		if bt, ok := b.([]byte); ok {
+			return bytes.Compare(at, bt)
+		}
+	}
+	if av.Kind() == reflect.String && bv.Kind() == reflect.String {
+		return strings.Compare(av.String(), bv.String())
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// UpsertExpr inserts entity or, when it conflicts with an existing row on
+// conflictColumns, sets each column in updateExpressions to its expression.
+// Combined with Increment and Excluded it expresses atomic counters:
+//
+//	repo.UpsertExpr(ctx, &rollup, []string{"day"}, map[string]clause.Expression{
+//		"hits": Increment("hits", Excluded("hits")),
+//	})
+func (r *Repository[T]) UpsertExpr(ctx context.Context, entity *T, conflictColumns []string, updateExpressions map[string]clause.Expression) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	if len(updateExpressions) == 0 {
+		return errors.New("update expressions cannot be empty")
+	}
+
+	onConflict, err := r.onConflict(conflictColumns)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(updateExpressions))
+	for name := range updateExpressions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		col, err := r.column(name)
+		if err != nil {
+			return err
+		}
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: col},
+			Value:  updateExpressions[name],
+		})
+	}
+
+	return r.conn(ctx).Clauses(onConflict).Create(entity).Error
+}
+
+// UpsertIfNewer behaves like Upsert but only overwrites a conflicting row
+// whose compareColumn is older than entity's, so events that arrive out of
+// order cannot replace fresher data with stale values. It reports whether
+// entity was written; a stale entity is skipped without error.
+//
+// Postgres and sqlite add WHERE table.compareColumn < excluded.compareColumn
+// to the conflict update. MySQL has no conditional ON DUPLICATE KEY UPDATE,
+// so there the existing row is read with FOR UPDATE inside a transaction and
+// the upsert is skipped when it is at least as new; the lock also blocks
+// concurrent inserts of the same key on InnoDB under REPEATABLE READ.
+func (r *Repository[T]) UpsertIfNewer(ctx context.Context, entity *T, conflictColumns []string, compareColumn string, updateColumns []string) (bool, error) {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	defer r.invalidateCount()
+	compare, err := r.column(compareColumn)
+	if err != nil {
+		return false, err
+	}
+	onConflict, err := r.onConflict(conflictColumns)
+	if err != nil {
+		return false, err
+	}
+	if len(updateColumns) == 0 {
+		onConflict.UpdateAll = true
+	} else {
+		cols, err := r.columns(updateColumns)
+		if err != nil {
+			return false, err
+		}
+		onConflict.DoUpdates = clause.AssignmentColumns(cols)
+	}
+
+	if r.Dialect() != dialect.MySQL {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{clause.Lt{
+			Column: clause.Column{Table: clause.CurrentTable, Name: compare},
+			Value:  Excluded(compare),
+		}}}
+		tx := r.conn(ctx).Clauses(onConflict).Create(entity)
+		return tx.RowsAffected > 0, tx.Error
+	}
+
+	s, err := r.schema()
+	if err != nil {
+		return false, err
+	}
+	rv := reflect.ValueOf(entity).Elem()
+	value := func(col string) interface{} {
+		v, _ := s.FieldsByDBName[col].ValueOf(ctx, rv)
+		return v
+	}
+
+	written := false
+	err = r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		newer := tx.Model(new(T)).Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate})
+		for _, col := range onConflict.Columns {
+			newer = newer.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: col.Name}, Value: value(col.Name)})
+		}
+		newer = newer.Where(clause.Gte{Column: clause.Column{Table: clause.CurrentTable, Name: compare}, Value: value(compare)})
+
+		var n int64
+		if err := newer.Count(&n).Error; err != nil || n > 0 {
+			return err
+		}
+
+		written = true
+		return tx.Clauses(onConflict).Create(entity).Error
+	})
+	return written && err == nil, err
+}
+
+// ValidateUpsertTargets checks that the table has a primary key, unique index
+// or unique constraint on exactly conflictColumns. Without one, postgres and
+// sqlite reject the upsert and mysql silently inserts duplicates, so call it
+// once during startup for every conflict target the application upserts on.
+func (r *Repository[T]) ValidateUpsertTargets(ctx context.Context, conflictColumns []string) error {
+	ctx = r.checkDeadline(ctx)
+	if len(conflictColumns) == 0 {
+		return errors.New("conflict columns cannot be empty")
+	}
+
+	cols, err := r.columns(conflictColumns)
+	if err != nil {
+		return err
+	}
+	target := columnSet(cols)
+
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	if target == columnSet(s.PrimaryFieldDBNames) {
+		return nil
+	}
+
+	migrator := r.conn(ctx).Migrator()
+	if len(cols) == 1 {
+		columnTypes, err := migrator.ColumnTypes(new(T))
+		if err != nil {
+			return err
+		}
+		for _, ct := range columnTypes {
+			if unique, ok := ct.Unique(); ok && unique && ct.Name() == cols[0] {
+				return nil
+			}
+		}
+	}
+
+	indexes, err := migrator.GetIndexes(new(T))
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		unique, _ := idx.Unique()
+		primary, _ := idx.PrimaryKey()
+		if (unique || primary) && columnSet(idx.Columns()) == target {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s on %s", ErrNoUniqueIndex, strings.Join(cols, ", "), s.Table)
+}
+
+// columnSet returns an order-independent key for a list of columns
+func columnSet(cols []string) string {
+	sorted := append([]string(nil), cols...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// onConflict builds an ON CONFLICT clause targeting the validated conflict columns
+func (r *Repository[T]) onConflict(conflictColumns []string) (clause.OnConflict, error) {
+	if len(conflictColumns) == 0 {
+		return clause.OnConflict{}, errors.New("conflict columns cannot be empty")
+	}
+
+	cols, err := r.columns(conflictColumns)
+	if err != nil {
+		return clause.OnConflict{}, err
+	}
+
+	onConflict := clause.OnConflict{}
+	for _, col := range cols {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: col})
+	}
+	return onConflict, nil
+}
+
+// Increment returns an expression adding by to the existing value of column.
+// by may be a plain value or another expression such as Excluded.
+func Increment(column string, by interface{}) clause.Expression {
+	return gorm.Expr("? + ?", clause.Column{Table: clause.CurrentTable, Name: column}, by)
+}
+
+// Excluded returns an expression referring to the value of column in the row
+// that failed to insert, i.e. excluded.column on postgres and sqlite and
+// VALUES(column) on mysql.
+func Excluded(column string) clause.Expression {
+	return excluded(column)
+}
+
+type excluded string
+
+// Build implements clause.Expression
+func (e excluded) Build(builder clause.Builder) {
+	if stmt, ok := builder.(*gorm.Statement); ok && dialect.Of(stmt.DB) == dialect.MySQL {
+		builder.WriteString("VALUES(")
+		builder.WriteQuoted(string(e))
+		builder.WriteByte(')')
+		return
+	}
+
+	builder.WriteQuoted(clause.Column{Table: "excluded", Name: string(e)})
+}
@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// TestCounter is a test entity keyed by a unique day
+type TestCounter struct {
+	ID    uint   `gorm:"primarykey"`
+	Day   string `gorm:"size:10;uniqueIndex"`
+	Label string `gorm:"size:100"`
+	Hits  int
+}
+
+func TestUpsert(t *testing.T) {
+	db := setupTestDB(t, &TestCounter{})
+	repo := New[TestCounter](db)
+	ctx := context.Background()
+
+	repo.Create(ctx, &TestCounter{Day: "2024-06-01", Label: "first", Hits: 1})
+
+	t.Run("updates listed columns on conflict", func(t *testing.T) {
+		err := repo.Upsert(ctx, &TestCounter{Day: "2024-06-01", Label: "second", Hits: 5}, []string{"day"}, []string{"label"})
+		if err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+
+		var counter TestCounter
+		repo.FirstWhere(ctx, &counter, "day = ?", "2024-06-01")
+		if counter.Label != "second" {
+			t.Errorf("Expected label to be updated to second, got %s", counter.Label)
+		}
+		if counter.Hits != 1 {
+			t.Errorf("Expected hits to stay 1, got %d", counter.Hits)
+		}
+	})
+
+	t.Run("inserts when there is no conflict", func(t *testing.T) {
+		err := repo.Upsert(ctx, &TestCounter{Day: "2024-06-02", Hits: 2}, []string{"day"}, nil)
+		if err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+
+		count, _ := repo.Count(ctx)
+		if count != 2 {
+			t.Errorf("Expected 2 counters, got %d", count)
+		}
+	})
+
+	t.Run("rejects unknown columns", func(t *testing.T) {
+		err := repo.Upsert(ctx, &TestCounter{Day: "2024-06-03"}, []string{"nope"}, nil)
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestUpsertExpr(t *testing.T) {
+	db := setupTestDB(t, &TestCounter{})
+	repo := New[TestCounter](db)
+	ctx := context.Background()
+
+	t.Run("increments on conflict", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			err := repo.UpsertExpr(ctx, &TestCounter{Day: "2024-06-01", Hits: 2}, []string{"day"}, map[string]clause.Expression{
+				"hits": Increment("hits", Excluded("hits")),
+			})
+			if err != nil {
+				t.Fatalf("UpsertExpr failed: %v", err)
+			}
+		}
+
+		var counter TestCounter
+		repo.FirstWhere(ctx, &counter, "day = ?", "2024-06-01")
+		if counter.Hits != 6 {
+			t.Errorf("Expected hits 6, got %d", counter.Hits)
+		}
+	})
+
+	t.Run("rejects empty expressions", func(t *testing.T) {
+		err := repo.UpsertExpr(ctx, &TestCounter{Day: "2024-06-01"}, []string{"day"}, nil)
+		if err == nil {
+			t.Error("Expected error for empty update expressions")
+		}
+	})
+}
+
+func TestValidateUpsertTargets(t *testing.T) {
+	db := setupTestDB(t, &TestCounter{})
+	repo := New[TestCounter](db)
+	ctx := context.Background()
+
+	t.Run("accepts unique index columns", func(t *testing.T) {
+		if err := repo.ValidateUpsertTargets(ctx, []string{"day"}); err != nil {
+			t.Errorf("Expected day to be a valid target, got %v", err)
+		}
+	})
+
+	t.Run("accepts primary key", func(t *testing.T) {
+		if err := repo.ValidateUpsertTargets(ctx, []string{"id"}); err != nil {
+			t.Errorf("Expected id to be a valid target, got %v", err)
+		}
+	})
+
+	t.Run("rejects columns without unique index", func(t *testing.T) {
+		err := repo.ValidateUpsertTargets(ctx, []string{"label"})
+		if !errors.Is(err, ErrNoUniqueIndex) {
+			t.Errorf("Expected ErrNoUniqueIndex, got %v", err)
+		}
+	})
+
+	t.Run("rejects partial match of a composite target", func(t *testing.T) {
+		err := repo.ValidateUpsertTargets(ctx, []string{"day", "label"})
+		if !errors.Is(err, ErrNoUniqueIndex) {
+			t.Errorf("Expected ErrNoUniqueIndex, got %v", err)
+		}
+	})
+}
+
+// TestEvent is a test entity whose rows carry the time of the event they
+// were last written from
+type TestEvent struct {
+	ID        uint   `gorm:"primarykey"`
+	Key       string `gorm:"size:50;uniqueIndex"`
+	Status    string `gorm:"size:50"`
+	UpdatedAt time.Time
+}
+
+func TestUpsertIfNewer(t *testing.T) {
+	db := setupTestDB(t, &TestEvent{})
+	repo := New[TestEvent](db)
+	ctx := context.Background()
+
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	upsert := func(status string, at time.Time) bool {
+		t.Helper()
+		written, err := repo.UpsertIfNewer(ctx, &TestEvent{Key: "order-1", Status: status, UpdatedAt: at}, []string{"key"}, "updated_at", []string{"status", "updated_at"})
+		if err != nil {
+			t.Fatalf("UpsertIfNewer failed: %v", err)
+		}
+		return written
+	}
+	status := func() string {
+		t.Helper()
+		var event TestEvent
+		if err := repo.FirstWhere(ctx, &event, "key = ?", "order-1"); err != nil {
+			t.Fatalf("Failed to load event: %v", err)
+		}
+		return event.Status
+	}
+
+	if !upsert("created", base) {
+		t.Error("Expected the first event to be inserted")
+	}
+	if !upsert("shipped", base.Add(2*time.Hour)) {
+		t.Error("Expected a newer event to be written")
+	}
+	if upsert("paid", base.Add(time.Hour)) {
+		t.Error("Expected a stale event to be skipped")
+	}
+	if upsert("shipped again", base.Add(2*time.Hour)) {
+		t.Error("Expected an event as old as the row to be skipped")
+	}
+	if got := status(); got != "shipped" {
+		t.Errorf("Expected status shipped, got %s", got)
+	}
+
+	t.Run("guards the conflict update on postgres", func(t *testing.T) {
+		var sql string
+		pg := setupDryRunDB(t, postgres.Open("host=localhost"), func(s string) { sql = s })
+		pg = pg.Session(&gorm.Session{SkipDefaultTransaction: true})
+		New[TestEvent](pg).UpsertIfNewer(ctx, &TestEvent{Key: "order-1", UpdatedAt: base}, []string{"key"}, "updated_at", []string{"status"})
+
+		want := `ON CONFLICT ("key") DO UPDATE SET "status"="excluded"."status" WHERE "test_events"."updated_at" < "excluded"."updated_at"`
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected %s in %s", want, sql)
+		}
+	})
+
+	t.Run("rejects unknown compare column", func(t *testing.T) {
+		_, err := repo.UpsertIfNewer(ctx, &TestEvent{Key: "order-2"}, []string{"key"}, "nope", nil)
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestUpsertMany(t *testing.T) {
+	db := setupTestDB(t, &TestEvent{})
+	repo := New[TestEvent](db, WithDefaultBatchSize(2))
+	ctx := context.Background()
+
+	events := []TestEvent{{Key: "c", Status: "new"}, {Key: "a", Status: "new"}, {Key: "b", Status: "new"}}
+	if err := repo.UpsertMany(ctx, events, []string{"key"}, []string{"status"}); err != nil {
+		t.Fatalf("UpsertMany failed: %v", err)
+	}
+
+	t.Run("updates conflicting rows in key order", func(t *testing.T) {
+		batch := []TestEvent{{Key: "d", Status: "new"}, {Key: "b", Status: "paid"}, {Key: "a", Status: "paid"}}
+		if err := repo.UpsertMany(ctx, batch, []string{"key"}, []string{"status"}, OrderByConflictKey()); err != nil {
+			t.Fatalf("UpsertMany failed: %v", err)
+		}
+		for _, event := range batch {
+			var stored TestEvent
+			if err := repo.FirstWhere(ctx, &stored, "key = ?", event.Key); err != nil {
+				t.Fatalf("FirstWhere failed: %v", err)
+			}
+			if stored.Status != event.Status {
+				t.Errorf("Expected %s to be %s, got %s", event.Key, event.Status, stored.Status)
+			}
+		}
+		if batch[0].Key != "d" || batch[0].ID == 0 {
+			t.Errorf("Expected entities to keep their positions and get IDs, got %+v", batch)
+		}
+		if n, _ := repo.Count(ctx); n != 4 {
+			t.Errorf("Expected 4 events, got %d", n)
+		}
+	})
+
+	t.Run("writes sorted rows", func(t *testing.T) {
+		var sql []string
+		pg := setupDryRunDB(t, postgres.Open("host=localhost"), func(s string) { sql = append(sql, s) })
+		pg = pg.Session(&gorm.Session{SkipDefaultTransaction: true})
+		batch := []TestEvent{{Key: "k3"}, {Key: "k10"}, {Key: "k1"}, {Key: "k2"}}
+		New[TestEvent](pg).UpsertMany(ctx, batch, []string{"key"}, []string{"status"}, OrderByConflictKey())
+
+		if len(sql) != 1 {
+			t.Fatalf("Expected one statement, got %v", sql)
+		}
+		first, second := strings.Index(sql[0], "'k1'"), strings.Index(sql[0], "'k10'")
+		third, last := strings.Index(sql[0], "'k2'"), strings.Index(sql[0], "'k3'")
+		if !(first < second && second < third && third < last) {
+			t.Errorf("Expected rows ordered by key in %s", sql[0])
+		}
+	})
+
+	t.Run("rejects unknown conflict columns", func(t *testing.T) {
+		err := repo.UpsertMany(ctx, []TestEvent{{Key: "e"}}, []string{"nope"}, nil, OrderByConflictKey())
+		if !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestInsertIgnore(t *testing.T) {
+	db := setupTestDB(t, &TestEvent{})
+	repo := New[TestEvent](db, WithDefaultBatchSize(2))
+	ctx := context.Background()
+
+	repo.Create(ctx, &TestEvent{Key: "a", Status: "old"})
+
+	t.Run("skips conflicting rows", func(t *testing.T) {
+		batch := []TestEvent{{Key: "a", Status: "new"}, {Key: "b", Status: "new"}, {Key: "c", Status: "new"}}
+		inserted, skipped, err := repo.InsertIgnore(ctx, batch, []string{"key"})
+		if err != nil {
+			t.Fatalf("InsertIgnore failed: %v", err)
+		}
+		if inserted != 2 || skipped != 1 {
+			t.Errorf("Expected 2 inserted and 1 skipped, got %d and %d", inserted, skipped)
+		}
+
+		var stored TestEvent
+		repo.FirstWhere(ctx, &stored, "key = ?", "a")
+		if stored.Status != "old" {
+			t.Errorf("Expected the existing row to be kept, got %+v", stored)
+		}
+		if n, _ := repo.Count(ctx); n != 3 {
+			t.Errorf("Expected 3 events, got %d", n)
+		}
+	})
+
+	t.Run("does not assign IDs", func(t *testing.T) {
+		batch := []TestEvent{{Key: "a"}, {Key: "d"}, {Key: "e"}}
+		if _, _, err := repo.InsertIgnore(ctx, batch, []string{"key"}); err != nil {
+			t.Fatalf("InsertIgnore failed: %v", err)
+		}
+		for _, event := range batch {
+			var stored TestEvent
+			if err := repo.FirstWhere(ctx, &stored, "key = ?", event.Key); err != nil {
+				t.Fatalf("FirstWhere failed: %v", err)
+			}
+			if event.ID != 0 {
+				t.Errorf("Expected %s to get no ID, got %d for stored ID %d", event.Key, event.ID, stored.ID)
+			}
+		}
+	})
+
+	t.Run("short-circuits empty entities", func(t *testing.T) {
+		inserted, skipped, err := repo.InsertIgnore(ctx, nil, []string{"key"})
+		if err != nil || inserted != 0 || skipped != 0 {
+			t.Errorf("Expected (0, 0, nil), got (%d, %d, %v)", inserted, skipped, err)
+		}
+	})
+
+	t.Run("validates conflict columns", func(t *testing.T) {
+		if _, _, err := repo.InsertIgnore(ctx, []TestEvent{{Key: "d"}}, []string{"missing"}); !errors.Is(err, ErrInvalidColumn) {
+			t.Errorf("Expected ErrInvalidColumn, got %v", err)
+		}
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{1, 2, -1},
+		{int64(10), int64(9), 1},
+		{uint(3), uint(3), 0},
+		{1.5, 0.5, 1},
+		{"k10", "k2", -1},
+		{now, now.Add(time.Second), -1},
+		{[]byte("b"), []byte("a"), 1},
+		{nil, 1, -1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v): expected %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+// TestUpsertManyDeadlocks upserts overlapping keys from concurrent
+// transactions on postgres, once in random order and once sorted
+func TestUpsertManyDeadlocks(t *testing.T) {
+	dsn := os.Getenv("DB_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("DB_TEST_POSTGRES_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if err := db.Migrator().DropTable(&TestEvent{}); err != nil {
+		t.Fatalf("DropTable failed: %v", err)
+	}
+	if err := db.AutoMigrate(&TestEvent{}); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	t.Cleanup(func() { db.Migrator().DropTable(&TestEvent{}) })
+
+	repo := New[TestEvent](db, WithDefaultBatchSize(5))
+	ctx := context.Background()
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%02d", i)
+	}
+
+	deadlocks := func(opts ...UpsertOption) int64 {
+		var count atomic.Int64
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rng := mathrand.New(mathrand.NewSource(seed))
+				for round := 0; round < 10; round++ {
+					batch := make([]TestEvent, len(keys))
+					for i, j := range rng.Perm(len(keys)) {
+						batch[i] = TestEvent{Key: keys[j], Status: fmt.Sprintf("w%d-r%d", seed, round)}
+					}
+					err := repo.UpsertMany(ctx, batch, []string{"key"}, []string{"status"}, opts...)
+					if isRetryableTxError(err) {
+						count.Add(1)
+					} else if err != nil {
+						t.Errorf("UpsertMany failed: %v", err)
+					}
+				}
+			}(int64(worker))
+		}
+		wg.Wait()
+		return count.Load()
+	}
+
+	unordered := deadlocks()
+	ordered := deadlocks(OrderByConflictKey())
+	t.Logf("deadlocks: %d unordered, %d ordered", unordered, ordered)
+	if ordered != 0 {
+		t.Errorf("Expected no deadlocks with ordered upserts, got %d", ordered)
+	}
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// historyVersionColumn numbers the versions of each ID in the history table
+const historyVersionColumn = "history_version"
+
+// historyMeta declares the extra history columns for the migrator
+type historyMeta struct {
+	HistoryVersion int       `gorm:"column:history_version;not null"`
+	HistoryAt      time.Time `gorm:"column:history_at;not null"`
+}
+
+// historyRow is a prior version of a row as stored in the history table
+type historyRow[T any] struct {
+	Row            T         `gorm:"embedded"`
+	HistoryVersion int       `gorm:"column:history_version"`
+	HistoryAt      time.Time `gorm:"column:history_at"`
+}
+
+// EnableVersioning creates the <table>_history table used by WithVersioning,
+// or adds the columns it is missing when T has gained fields since it was
+// created. The history table has the columns of T plus history_version and
+// history_at, and none of T's keys, so it holds many versions per ID.
+func (r *Repository[T]) EnableVersioning(ctx context.Context) error {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	history := historyTable(s)
+
+	tx := r.db.WithContext(ctx)
+	if !tx.Migrator().HasTable(history) {
+		if err := createShadowTable(tx, history, s.Table); err != nil {
+			return err
+		}
+	}
+
+	migrator := tx.Table(history).Migrator()
+	for _, name := range s.DBNames {
+		if !migrator.HasColumn(new(T), name) {
+			if err := migrator.AddColumn(new(T), name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{"HistoryVersion", "HistoryAt"} {
+		if !migrator.HasColumn(&historyMeta{}, name) {
+			if err := migrator.AddColumn(&historyMeta{}, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// versionedUpdate saves entity after copying the row it replaces into the
+// history table as the next version, in one transaction. The prior row is
+// locked while it is copied, so concurrent updates of one ID get distinct
+// versions on databases with row locks.
+func (r *Repository[T]) versionedUpdate(ctx context.Context, entity *T) error {
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+	id, zero := pk.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+	if zero {
+		// Save inserts rows without a primary key, so there is no prior version
+		return r.conn(ctx).Save(entity).Error
+	}
+
+	history := historyTable(s)
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		var prior T
+		err := tx.Unscoped().
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+			Take(&prior, pkCondition(pk, id)).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Save(entity).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		var latest int
+		err = sideTable(tx, history).
+			Select("COALESCE(MAX(?), 0)", clause.Column{Name: historyVersionColumn}).
+			Where(clause.Eq{Column: clause.Column{Name: pk.DBName}, Value: id}).
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		err = sideTable(tx, history).
+			Session(&gorm.Session{SkipHooks: true}).
+			Omit(clause.Associations).
+			Create(&historyRow[T]{Row: prior, HistoryVersion: latest + 1, HistoryAt: r.db.NowFunc()}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Save(entity).Error
+	})
+}
+
+// History returns the prior versions of the record with the given ID, oldest
+// first, so the element at index i is version i+1. The current row is not
+// included. It needs the history table created by EnableVersioning.
+func (r *Repository[T]) History(ctx context.Context, id interface{}) ([]T, error) {
+	ctx = r.checkDeadline(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions of soft-deleted rows are history too
+	var rows []historyRow[T]
+	err = sideTable(r.conn(ctx), historyTable(s)).
+		Unscoped().
+		Where(clause.Eq{Column: clause.Column{Name: pk.DBName}, Value: id}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: historyVersionColumn}}).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]T, len(rows))
+	for i := range rows {
+		versions[i] = rows[i].Row
+	}
+	return versions, nil
+}
+
+// RestoreVersion reverts the record with the given ID to a version returned
+// by History. The restore goes through Update, so on a repository created
+// with WithVersioning the row it replaces becomes a new version and the
+// restore can itself be undone.
+func (r *Repository[T]) RestoreVersion(ctx context.Context, id interface{}, version int) error {
+	ctx = r.checkDeadline(ctx)
+	defer r.forget(ctx)
+	s, err := r.schema()
+	if err != nil {
+		return err
+	}
+	pk, err := primaryKey(s)
+	if err != nil {
+		return err
+	}
+
+	var row historyRow[T]
+	err = sideTable(r.conn(ctx), historyTable(s)).
+		Unscoped().
+		Where(clause.Eq{Column: clause.Column{Name: pk.DBName}, Value: id}).
+		Where(clause.Eq{Column: clause.Column{Name: historyVersionColumn}, Value: version}).
+		Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("version %d of %v: %w", version, id, err)
+	}
+	if err != nil {
+		return err
+	}
+	return r.Update(ctx, &row.Row)
+}
+
+// pkCondition matches the row whose primary key is id
+func pkCondition(pk *schema.Field, id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Value: id}
+}
+
+// historyTable returns the name of the version history table for a schema
+func historyTable(s *schema.Schema) string {
+	return s.Table + "_history"
+}
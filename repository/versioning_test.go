@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestVersioning(t *testing.T) {
+	db := setupTestDB(t, &TestArticle{})
+	repo := New[TestArticle](db, WithVersioning())
+	ctx := context.Background()
+
+	if err := repo.EnableVersioning(ctx); err != nil {
+		t.Fatalf("EnableVersioning failed: %v", err)
+	}
+	if err := repo.EnableVersioning(ctx); err != nil {
+		t.Fatalf("EnableVersioning is not idempotent: %v", err)
+	}
+
+	article := &TestArticle{Title: "v1"}
+	repo.Create(ctx, article)
+	for _, title := range []string{"v2", "v3"} {
+		article.Title = title
+		if err := repo.Update(ctx, article); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	t.Run("records prior versions", func(t *testing.T) {
+		history, err := repo.History(ctx, article.ID)
+		if err != nil {
+			t.Fatalf("History failed: %v", err)
+		}
+		if len(history) != 2 || history[0].Title != "v1" || history[1].Title != "v2" {
+			t.Errorf("Expected versions [v1 v2], got %+v", history)
+		}
+	})
+
+	t.Run("restores a version", func(t *testing.T) {
+		if err := repo.RestoreVersion(ctx, article.ID, 1); err != nil {
+			t.Fatalf("RestoreVersion failed: %v", err)
+		}
+
+		var current TestArticle
+		repo.FindByID(ctx, article.ID, &current)
+		if current.Title != "v1" {
+			t.Errorf("Expected title v1 after restore, got %s", current.Title)
+		}
+
+		history, _ := repo.History(ctx, article.ID)
+		if len(history) != 3 || history[2].Title != "v3" {
+			t.Errorf("Expected the restore to record v3 as a version, got %+v", history)
+		}
+	})
+
+	t.Run("reports a missing version", func(t *testing.T) {
+		err := repo.RestoreVersion(ctx, article.ID, 99)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("leaves unversioned repositories alone", func(t *testing.T) {
+		other := &TestArticle{Title: "plain"}
+		plain := New[TestArticle](db)
+		plain.Create(ctx, other)
+		other.Title = "changed"
+		plain.Update(ctx, other)
+
+		history, _ := repo.History(ctx, other.ID)
+		if len(history) != 0 {
+			t.Errorf("Expected no history, got %+v", history)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DumpSchema returns the CREATE TABLE and CREATE INDEX statements the
+// migrator would run to create the tables of models from scratch, for the
+// active driver and in dependency order, so tables referenced by foreign keys
+// come first. As with AutoMigrate, many2many join tables and tables of
+// referenced models missing from models are included. Statements end with a
+// semicolon and are separated by a blank line. Nothing is executed: the
+// migrator runs in dry-run mode and the statements are collected from it,
+// which also means the output does not depend on which tables already exist.
+func (db *DB) DumpSchema(ctx context.Context, models ...interface{}) (string, error) {
+	if db.DB == nil {
+		return "", ErrNotConnected
+	}
+
+	recorder := &ddlRecorder{}
+	tx := db.DB.Session(&gorm.Session{DryRun: true, Logger: recorder, Context: ctx})
+	migrator := tx.Migrator()
+	if reorderer, ok := migrator.(interface {
+		ReorderModels(values []interface{}, autoAdd bool) []interface{}
+	}); ok {
+		models = reorderer.ReorderModels(models, true)
+	}
+	for _, model := range models {
+		if err := migrator.CreateTable(model); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(recorder.statements, "\n\n"), nil
+}
+
+// ddlRecorder is a logger that collects the SQL of every traced statement
+type ddlRecorder struct {
+	statements []string
+}
+
+// LogMode implements logger.Interface
+func (r *ddlRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+// Info implements logger.Interface
+func (r *ddlRecorder) Info(context.Context, string, ...interface{}) {}
+
+// Warn implements logger.Interface
+func (r *ddlRecorder) Warn(context.Context, string, ...interface{}) {}
+
+// Error implements logger.Interface
+func (r *ddlRecorder) Error(context.Context, string, ...interface{}) {}
+
+// Trace implements logger.Interface
+func (r *ddlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	if sql, _ := fc(); strings.TrimSpace(sql) != "" {
+		r.statements = append(r.statements, strings.TrimSuffix(strings.TrimSpace(sql), ";")+";")
+	}
+}
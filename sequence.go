@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sequence is a named counter row used by NextSequence. Migrate it with
+// AutoMigrate(&db.Sequence{}) before allocating values.
+type Sequence struct {
+	Name  string `gorm:"primaryKey;size:191"`
+	Value int64  `gorm:"not null;default:0"`
+}
+
+// TableName implements schema.Tabler
+func (Sequence) TableName() string {
+	return "db_sequences"
+}
+
+// NextSequence allocates the next value of the named sequence in its own
+// transaction. Use NextSequenceTx to allocate as part of a larger transaction.
+func (db *DB) NextSequence(ctx context.Context, name string) (int64, error) {
+	if db.DB == nil {
+		return 0, ErrNotConnected
+	}
+
+	var value int64
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		value, err = NextSequenceTx(tx, name)
+		return err
+	})
+	return value, err
+}
+
+// NextSequenceTx increments the named sequence inside tx and returns the new
+// value, starting at 1 for a new name. The row stays locked until tx ends, so
+// concurrent callers never get the same value, and a rolled back transaction
+// releases its value again, which keeps the sequence gap-free. Postgres uses
+// UPDATE ... RETURNING; other drivers update the row, which locks it, and then
+// read it back.
+func NextSequenceTx(tx *gorm.DB, name string) (int64, error) {
+	if name == "" {
+		return 0, errors.New("sequence name cannot be empty")
+	}
+
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Sequence{Name: name}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	seq := Sequence{Name: name}
+	increment := gorm.Expr("? + 1", clause.Column{Name: "value"})
+	if dialect.Of(tx) == dialect.Postgres {
+		err := tx.Model(&seq).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "value"}}}).
+			UpdateColumn("value", increment).Error
+		return seq.Value, err
+	}
+
+	if err := tx.Model(&seq).UpdateColumn("value", increment).Error; err != nil {
+		return 0, err
+	}
+	err = tx.Take(&seq, "name = ?", name).Error
+	return seq.Value, err
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracerName is the instrumentation name spans are created under
+const tracerName = "github.com/modsynth/db-module"
+
+// spanInstanceKey stores the active span on the statement
+const spanInstanceKey = "db:span"
+
+// waitInstanceKey stores the pool's total wait duration when the span started
+const waitInstanceKey = "db:pool_wait"
+
+// tracing starts a client span around every statement, named after the
+// operation and table, e.g. "INSERT users". With fromCaller set the name is
+// prefixed with the function that issued the statement, e.g.
+// "CreateOrder -> INSERT users".
+//
+// Each span carries db.client.connection.wait_time, the seconds the pool spent
+// waiting for connections while the statement ran, so a slow span can be told
+// apart from one stuck behind an exhausted pool. It is taken from the pool's
+// cumulative sql.DBStats, so concurrent waits of other statements are counted
+// too. Statements in GORM's default transaction acquire their connection at
+// BEGIN, before the span starts, and report only later waits.
+type tracing struct {
+	tracer     trace.Tracer
+	system     string
+	fromCaller bool
+	pool       *sql.DB
+}
+
+// registerTracing registers the callbacks that start and end statement spans
+func registerTracing(db *gorm.DB, sqlDB *sql.DB, provider trace.TracerProvider, fromCaller bool) error {
+	t := &tracing{
+		tracer:     provider.Tracer(tracerName),
+		system:     dialect.Of(db),
+		fromCaller: fromCaller,
+		pool:       sqlDB,
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("db:trace_start", t.starter("INSERT")); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("db:trace_end", t.end); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("db:trace_start", t.starter("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("db:trace_end", t.end); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("db:trace_start", t.starter("UPDATE")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("db:trace_end", t.end); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("db:trace_start", t.starter("DELETE")); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("db:trace_end", t.end); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("db:trace_start", t.starter("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("db:trace_end", t.end); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("db:trace_start", t.starter("")); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("db:trace_end", t.end)
+}
+
+// starter returns the callback that opens the span of a statement. An empty
+// operation is taken from the first keyword of the already built SQL, as for
+// Exec and Raw.
+func (t *tracing) starter(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		t.start(db, operation)
+	}
+}
+
+// statementOperation returns operation, or for an empty operation the first
+// keyword of the already built SQL of stmt
+func statementOperation(stmt *gorm.Statement, operation string) string {
+	if operation != "" {
+		return operation
+	}
+	if fields := strings.Fields(stmt.SQL.String()); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "RAW"
+}
+
+// start opens the span of the statement
+func (t *tracing) start(db *gorm.DB, operation string) {
+	stmt := db.Statement
+	operation = statementOperation(stmt, operation)
+
+	name := operation
+	if stmt.Table != "" {
+		name += " " + stmt.Table
+	}
+	if t.fromCaller {
+		if caller := callerName(); caller != "" {
+			name = caller + " -> " + name
+		}
+	}
+
+	ctx, span := t.tracer.Start(stmt.Context, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", t.system)),
+	)
+	stmt.Context = ctx
+	db.InstanceSet(spanInstanceKey, span)
+	db.InstanceSet(waitInstanceKey, t.pool.Stats().WaitDuration)
+}
+
+// end records the outcome of the statement and ends its span
+func (t *tracing) end(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	stmt := db.Statement
+	span.SetAttributes(
+		attribute.String("db.statement", stmt.SQL.String()),
+		attribute.String("db.sql.table", stmt.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if waited, ok := db.InstanceGet(waitInstanceKey); ok {
+		wait := t.pool.Stats().WaitDuration - waited.(time.Duration)
+		span.SetAttributes(attribute.Float64("db.client.connection.wait_time", wait.Seconds()))
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
+
+// callerName returns the name of the first function on the stack outside
+// GORM and this module, such as "CreateOrder" or "OrderService.Create".
+// Walking the stack costs a few microseconds and allocations per statement,
+// which is why it is opt-in.
+func callerName() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !isInternalFrame(frame) {
+			return shortFuncName(frame.Function)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// isInternalFrame reports whether frame belongs to GORM, the runtime or this
+// module's own non-test code
+func isInternalFrame(frame runtime.Frame) bool {
+	fn := frame.Function
+	switch {
+	case strings.HasPrefix(fn, "gorm.io/"), strings.HasPrefix(fn, "runtime."):
+		return true
+	case strings.HasPrefix(fn, tracerName+".") || strings.HasPrefix(fn, tracerName+"/"):
+		return !strings.HasSuffix(frame.File, "_test.go")
+	}
+	return false
+}
+
+// shortFuncName strips the package path, pointer receivers and type
+// parameters from a runtime function name, so "example.com/app.(*Service[...]).Create"
+// becomes "Service.Create"
+func shortFuncName(fn string) string {
+	if i := strings.LastIndex(fn, "/"); i >= 0 {
+		fn = fn[i+1:]
+	}
+	if i := strings.Index(fn, "."); i >= 0 {
+		fn = fn[i+1:]
+	}
+	return strings.NewReplacer("(*", "", ")", "", "[...]", "").Replace(fn)
+}
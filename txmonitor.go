@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/modsynth/db-module/internal/dialect"
+)
+
+// LongTransaction is a transaction of this application that has been open
+// longer than the threshold given to StartTransactionMonitor
+type LongTransaction struct {
+	// PID is the backend process ID, as accepted by pg_terminate_backend
+	PID int
+	// State is the session state, e.g. "active" or "idle in transaction"
+	State string
+	// Query is the most recent statement of the session. For an idle session
+	// it is the statement that ran last, often where the code stopped.
+	Query string
+	// Started is when the transaction began
+	Started time.Time
+	// Duration is how long the transaction had been open when it was seen
+	Duration time.Duration
+}
+
+// longTransactionsQuery lists the transactions older than a number of seconds
+// on connections of the current database sharing this session's
+// application_name, except the session running the query
+const longTransactionsQuery = `SELECT pid, state, query, xact_start AS started,
+	EXTRACT(EPOCH FROM clock_timestamp() - xact_start) AS seconds
+FROM pg_stat_activity
+WHERE datname = current_database()
+	AND application_name = current_setting('application_name')
+	AND pid <> pg_backend_pid()
+	AND xact_start < clock_timestamp() - ? * INTERVAL '1 second'
+ORDER BY xact_start`
+
+// StartTransactionMonitor checks pg_stat_activity every interval, from a
+// background goroutine that stops when ctx is done, and calls report with
+// every transaction open for longer than threshold. Transactions left idle
+// hold their locks and keep vacuum from cleaning up, so a report usually
+// points at a code path that began a transaction and never finished it.
+//
+// It requires postgres. Sessions are attributed to this application by their
+// application_name, so set Config.ApplicationName to a name the service does
+// not share with others; every service connecting without one would be
+// reported otherwise. A transaction stays reported on every check until it
+// ends. Failed checks are skipped.
+func (db *DB) StartTransactionMonitor(ctx context.Context, interval, threshold time.Duration, report func(LongTransaction)) error {
+	if db.DB == nil {
+		return ErrNotConnected
+	}
+	if dialect.Of(db.DB) != dialect.Postgres {
+		return errors.New("transaction monitoring requires postgres")
+	}
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if threshold <= 0 {
+		return errors.New("threshold must be positive")
+	}
+	if report == nil {
+		return errors.New("report cannot be nil")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			transactions, err := db.longTransactions(ctx, threshold)
+			if err != nil {
+				continue
+			}
+			for _, tx := range transactions {
+				report(tx)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// longTransactions returns the transactions open for longer than threshold
+func (db *DB) longTransactions(ctx context.Context, threshold time.Duration) ([]LongTransaction, error) {
+	var rows []struct {
+		PID     int
+		State   string
+		Query   string
+		Started time.Time
+		Seconds float64
+	}
+	err := db.DB.WithContext(ctx).Raw(longTransactionsQuery, threshold.Seconds()).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]LongTransaction, len(rows))
+	for i, row := range rows {
+		transactions[i] = LongTransaction{
+			PID:      row.PID,
+			State:    row.State,
+			Query:    row.Query,
+			Started:  row.Started,
+			Duration: time.Duration(row.Seconds * float64(time.Second)),
+		}
+	}
+	return transactions, nil
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+
+	"github.com/modsynth/db-module/repository"
+	"gorm.io/gorm"
+)
+
+// UnitOfWork runs domain operations that touch several aggregates in one
+// transaction, handing out repositories bound to it:
+//
+//	err := db.NewUnitOfWork(database).Run(ctx, func(u *db.UnitOfWork) error {
+//		if err := db.Repo[Order](u).Create(ctx, order); err != nil {
+//			return err
+//		}
+//		return db.Repo[Product](u).Decrement(ctx, order.ProductID, "stock", order.Quantity)
+//	})
+type UnitOfWork struct {
+	db *DB
+	tx *gorm.DB
+}
+
+// NewUnitOfWork creates a unit of work on the database
+func NewUnitOfWork(d *DB) *UnitOfWork {
+	return &UnitOfWork{db: d}
+}
+
+// Run calls fn with a unit of work bound to a new transaction, which commits
+// when fn returns nil and rolls back when it returns an error or panics. Run
+// on a unit of work that is already bound nests a savepoint in its
+// transaction.
+func (u *UnitOfWork) Run(ctx context.Context, fn func(u *UnitOfWork) error) error {
+	return u.conn().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&UnitOfWork{db: u.db, tx: tx})
+	})
+}
+
+// Tx returns the transaction the unit of work is bound to, for statements
+// that do not go through a repository. Outside Run it returns the database
+// itself.
+func (u *UnitOfWork) Tx() *gorm.DB {
+	return u.conn()
+}
+
+// conn returns the transaction, or the database outside Run
+func (u *UnitOfWork) conn() *gorm.DB {
+	if u.tx != nil {
+		return u.tx
+	}
+	return u.db.DB
+}
+
+// Repo returns a repository for T bound to the transaction of u. Repositories
+// of a unit of work outside Run use the database directly. Go methods cannot
+// take type parameters, so it is a function rather than a method.
+func Repo[T any](u *UnitOfWork, opts ...repository.Option) *repository.Repository[T] {
+	return repository.New[T](u.conn(), opts...)
+}